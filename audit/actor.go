@@ -0,0 +1,26 @@
+package audit
+
+import "context"
+
+// Actor is whoever caused an event: a signed-in user, or a guest known only by their address.
+type Actor struct {
+	UserID    string
+	Username  string
+	IPAddress string
+	UserAgent string
+}
+
+type contextKeyActorType struct{}
+
+var contextKeyActor = contextKeyActorType{}
+
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+// ActorFromContext returns the actor of the request, which is empty for background work.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(contextKeyActor).(Actor)
+
+	return actor
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionLoginSucceeded  Action = "login.succeeded"
+	ActionLoginFailed     Action = "login.failed"
+	ActionPasswordChanged Action = "password.changed"
+	ActionPasswordReset   Action = "password.reset"
+	ActionUserRegistered  Action = "user.registered"
+	ActionProfileUpdated  Action = "profile.updated"
+	ActionRoleChanged     Action = "user.role_changed"
+	ActionUserDisabled    Action = "user.disabled"
+	ActionUserEnabled     Action = "user.enabled"
+	ActionUserBanned      Action = "user.banned"
+	ActionUserUnbanned    Action = "user.unbanned"
+	ActionUserDeleted     Action = "user.deleted"
+	ActionPostCreated     Action = "post.created"
+	ActionPostUpdated     Action = "post.updated"
+	ActionPostDeleted     Action = "post.deleted"
+	ActionPostRestored    Action = "post.restored"
+	ActionPostPurged      Action = "post.purged"
+	ActionCommentCreated  Action = "comment.created"
+	ActionCommentUpdated  Action = "comment.updated"
+	ActionCommentApproved Action = "comment.approved"
+	ActionCommentDeleted  Action = "comment.deleted"
+	ActionCommentRestored Action = "comment.restored"
+	ActionCommentPurged   Action = "comment.purged"
+)
+
+// Actions lists every action, in the order they are offered as filters.
+var Actions = []Action{
+	ActionLoginSucceeded,
+	ActionLoginFailed,
+	ActionPasswordChanged,
+	ActionPasswordReset,
+	ActionUserRegistered,
+	ActionProfileUpdated,
+	ActionRoleChanged,
+	ActionUserDisabled,
+	ActionUserEnabled,
+	ActionUserBanned,
+	ActionUserUnbanned,
+	ActionUserDeleted,
+	ActionPostCreated,
+	ActionPostUpdated,
+	ActionPostDeleted,
+	ActionPostRestored,
+	ActionPostPurged,
+	ActionCommentCreated,
+	ActionCommentUpdated,
+	ActionCommentApproved,
+	ActionCommentDeleted,
+	ActionCommentRestored,
+	ActionCommentPurged,
+}
+
+const (
+	TargetTypeUser    = "user"
+	TargetTypePost    = "post"
+	TargetTypeComment = "comment"
+)
+
+// Change is the value of a field before and after an event.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Changes maps field names to the changes of the fields that differ.
+type Changes map[string]Change
+
+// Entry is a single event of the audit log. Entries are never updated or deleted.
+type Entry struct {
+	ID            string
+	Action        Action
+	ActorID       string
+	ActorUsername string
+	TargetType    string
+	TargetID      string
+	IPAddress     string
+	UserAgent     string
+	Changes       Changes
+	CreatedAt     time.Time
+}
+
+type ListEntriesParams struct {
+	Action     Action
+	TargetType string
+	// Search matches the actor username, the target ID and the IP address.
+	Search string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type EntryRepository interface {
+	Append(ctx context.Context, entry *Entry) (err error)
+	List(ctx context.Context, params ListEntriesParams) (entries []*Entry, err error)
+	Count(ctx context.Context, params ListEntriesParams) (count int, err error)
+}
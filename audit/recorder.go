@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// Event is something a service reports to the audit log.
+type Event struct {
+	Action     Action
+	TargetType string
+	TargetID   string
+	// Before and After are snapshots of the fields of the target. Only the fields that differ are kept.
+	Before map[string]any
+	After  map[string]any
+}
+
+// Recorder is how services report events, without knowing where the audit log is kept.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+// Diff returns the fields of before and after that differ.
+func Diff(before, after map[string]any) Changes {
+	keys := make([]string, 0, len(before)+len(after))
+
+	for key := range before {
+		keys = append(keys, key)
+	}
+
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	changes := make(Changes)
+
+	for _, key := range keys {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changes[key] = Change{Before: before[key], After: after[key]}
+		}
+	}
+
+	return changes
+}
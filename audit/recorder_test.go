@@ -0,0 +1,60 @@
+package audit_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/audit"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+		want   audit.Changes
+	}{
+		{
+			name:   "created",
+			before: nil,
+			after:  map[string]any{"title": "Hello"},
+			want:   audit.Changes{"title": {Before: nil, After: "Hello"}},
+		},
+		{
+			name:   "deleted",
+			before: map[string]any{"title": "Hello"},
+			after:  nil,
+			want:   audit.Changes{"title": {Before: "Hello", After: nil}},
+		},
+		{
+			name:   "only changed fields",
+			before: map[string]any{"title": "Hello", "slug": "hello", "tags": []string{"a"}},
+			after:  map[string]any{"title": "Hello", "slug": "hello-world", "tags": []string{"a"}},
+			want:   audit.Changes{"slug": {Before: "hello", After: "hello-world"}},
+		},
+		{
+			name:   "added and removed fields",
+			before: map[string]any{"excerpt": "old"},
+			after:  map[string]any{"markdown": "new"},
+			want: audit.Changes{
+				"excerpt":  {Before: "old", After: nil},
+				"markdown": {Before: nil, After: "new"},
+			},
+		},
+		{
+			name:   "unchanged",
+			before: map[string]any{"title": "Hello", "count": 1},
+			after:  map[string]any{"title": "Hello", "count": 1},
+			want:   audit.Changes{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := audit.Diff(tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
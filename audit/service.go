@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Service keeps the audit log. It implements Recorder.
+type Service struct {
+	EntryRepo EntryRepository
+}
+
+// Record appends an event to the audit log on behalf of the actor in ctx. An event that cannot be written is logged
+// rather than failing the operation it describes, which has already happened.
+func (svc *Service) Record(ctx context.Context, event Event) {
+	actor := ActorFromContext(ctx)
+
+	entry := &Entry{
+		ID:            uuid.NewString(),
+		Action:        event.Action,
+		ActorID:       actor.UserID,
+		ActorUsername: actor.Username,
+		TargetType:    event.TargetType,
+		TargetID:      event.TargetID,
+		IPAddress:     actor.IPAddress,
+		UserAgent:     actor.UserAgent,
+		Changes:       Diff(event.Before, event.After),
+		CreatedAt:     time.Now(),
+	}
+
+	err := svc.EntryRepo.Append(ctx, entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to append audit entry", "error", err, "action", event.Action)
+	}
+}
+
+func (svc *Service) ListEntries(ctx context.Context, params ListEntriesParams) ([]*Entry, error) {
+	entries, err := svc.EntryRepo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (svc *Service) CountEntries(ctx context.Context, params ListEntriesParams) (int, error) {
+	count, err := svc.EntryRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	return count, nil
+}
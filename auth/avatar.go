@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register the GIF decoder for uploads.
+	_ "image/jpeg" // Register the JPEG decoder for uploads.
+	"image/png"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Avatar is an uploaded avatar of a user, cropped to a square and scaled to one of AvatarSizes. Image is PNG.
+type Avatar struct {
+	UserID    string
+	Size      int
+	Image     []byte
+	CreatedAt time.Time
+}
+
+type AvatarRepository interface {
+	// Replace sets the avatars of a user, one for every size.
+	Replace(ctx context.Context, userID string, avatars []*Avatar) (err error)
+	Get(ctx context.Context, userID string, size int) (avatar *Avatar, err error)
+	Delete(ctx context.Context, userID string) (err error)
+}
+
+const (
+	AvatarSizeSmall  = 64
+	AvatarSizeMedium = 128
+	AvatarSizeLarge  = 256
+)
+
+// AvatarSizes lists the sizes, in pixels, that uploaded avatars are stored in, smallest first.
+var AvatarSizes = []int{AvatarSizeSmall, AvatarSizeMedium, AvatarSizeLarge}
+
+const (
+	// MaxAvatarFileSize is the size of the largest image file accepted as an avatar, in bytes.
+	MaxAvatarFileSize = 5 << 20
+	// maxAvatarPixels limits the dimensions of uploaded images, so small files cannot decode to huge ones.
+	maxAvatarPixels = 16_000_000
+)
+
+var (
+	ErrUnsupportedAvatar = errors.New("avatar is not a PNG, JPEG or GIF image")
+	ErrAvatarTooLarge    = errors.New("avatar is too large")
+)
+
+type AvatarNotFoundError struct {
+	UserID string
+	Size   int
+}
+
+func (err AvatarNotFoundError) Error() string {
+	return fmt.Sprintf("avatar of user '%s' with size %d not found", err.UserID, err.Size)
+}
+
+// AvatarSize returns the smallest of AvatarSizes that is at least size, or the largest one.
+func AvatarSize(size int) int {
+	for _, s := range AvatarSizes {
+		if s >= size {
+			return s
+		}
+	}
+
+	return AvatarSizes[len(AvatarSizes)-1]
+}
+
+// GravatarURL returns the URL of the Gravatar of an email address. Gravatar shows a generated identicon for
+// addresses without one.
+func GravatarURL(emailAddress string, size int) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(emailAddress))))
+
+	query := url.Values{
+		"s": {strconv.Itoa(size)},
+		"d": {"identicon"},
+	}
+
+	return "https://www.gravatar.com/avatar/" + hex.EncodeToString(hash[:]) + "?" + query.Encode()
+}
+
+// ScaleAvatar crops the image read from r to a centered square and encodes it as PNG in every one of
+// AvatarSizes, keyed by size.
+func ScaleAvatar(r io.Reader) (map[int][]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAvatarFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %w", err)
+	}
+
+	if len(data) > MaxAvatarFileSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	if config.Width*config.Height > maxAvatarPixels {
+		return nil, ErrAvatarTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+
+	images := make(map[int][]byte, len(AvatarSizes))
+
+	// Each size is scaled from the next larger one, so the original image is only read once.
+	var scaled image.Image = img
+
+	for i := len(AvatarSizes) - 1; i >= 0; i-- {
+		size := AvatarSizes[i]
+
+		scaled = scaleSquare(scaled, crop, size)
+		crop = scaled.Bounds()
+
+		var buf bytes.Buffer
+
+		err = png.Encode(&buf, scaled)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode avatar: %w", err)
+		}
+
+		images[size] = buf.Bytes()
+	}
+
+	return images, nil
+}
+
+// scaleSquare scales the square crop of src to size. Every pixel of the result is the average of the pixels it
+// covers, which keeps downscaled photos smooth. Images smaller than size are enlarged by repeating pixels.
+func scaleSquare(src image.Image, crop image.Rectangle, size int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	side := crop.Dx()
+
+	for y := range size {
+		y0 := crop.Min.Y + y*side/size
+		y1 := max(crop.Min.Y+(y+1)*side/size, y0+1)
+
+		for x := range size {
+			x0 := crop.Min.X + x*side/size
+			x1 := max(crop.Min.X+(x+1)*side/size, x0+1)
+
+			var r, g, b, a, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), //nolint:gosec
+				G: uint16(g / n), //nolint:gosec
+				B: uint16(b / n), //nolint:gosec
+				A: uint16(a / n), //nolint:gosec
+			})
+		}
+	}
+
+	return dst
+}
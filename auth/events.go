@@ -0,0 +1,19 @@
+package auth
+
+// UserRegistered is published when a new account is created.
+type UserRegistered struct {
+	User User
+}
+
+func (UserRegistered) EventName() string {
+	return "user.registered"
+}
+
+// PasswordReset is published when a user sets a new password with a reset token.
+type PasswordReset struct {
+	User User
+}
+
+func (PasswordReset) EventName() string {
+	return "password.reset"
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Login is a successful sign-in, each of which starts a new session.
+type Login struct {
+	ID        string
+	UserID    string
+	IPAddress string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+type LoginRepository interface {
+	Create(ctx context.Context, login *Login) (err error)
+	ListByUserID(ctx context.Context, userID string) (logins []*Login, err error)
+}
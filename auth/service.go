@@ -2,12 +2,45 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Service struct {
 	UserRepo               UserRepository
 	PasswordResetTokenRepo PasswordResetTokenRepository
+	LoginRepo              LoginRepository
+	AvatarRepo             AvatarRepository
+	Auditor                audit.Recorder
+	Events                 eventbus.Publisher
+}
+
+// profileAuditFields is the part of a user that is recorded in the audit log. The password hash is left out.
+func profileAuditFields(user *User) map[string]any {
+	return map[string]any{
+		"username":     user.Username,
+		"name":         user.Name,
+		"emailAddress": user.EmailAddress,
+		"avatarUrl":    user.AvatarURL,
+		"bio":          user.Bio,
+		"links":        user.Links,
+	}
+}
+
+// contextWithUserActor makes user the actor of ctx, for events that happen before the user is signed in.
+func contextWithUserActor(ctx context.Context, user *User) context.Context {
+	actor := audit.ActorFromContext(ctx)
+	actor.UserID = user.ID
+	actor.Username = user.Username
+
+	return audit.ContextWithActor(ctx, actor)
 }
 
 func (svc *Service) GetUserByUsername(ctx context.Context, username string) (*User, error) {
@@ -37,6 +70,42 @@ func (svc *Service) GetUserByID(ctx context.Context, id string) (*User, error) {
 	return user, nil
 }
 
+func (svc *Service) ListUsers(ctx context.Context, params ListUsersParams) ([]*User, error) {
+	users, err := svc.UserRepo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SuggestUsernames returns up to limit usernames that start with prefix, for completing mentions.
+func (svc *Service) SuggestUsernames(ctx context.Context, prefix string, limit int) ([]string, error) {
+	users, err := svc.UserRepo.List(ctx, ListUsersParams{UsernamePrefix: prefix, SortBy: "username", Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	usernames := make([]string, 0, len(users))
+
+	for _, user := range users {
+		if user.HasPublicProfile() {
+			usernames = append(usernames, user.Username)
+		}
+	}
+
+	return usernames, nil
+}
+
+func (svc *Service) CountUsers(ctx context.Context, params ListUsersParams) (int, error) {
+	count, err := svc.UserRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return count, nil
+}
+
 func (svc *Service) UserExistsByUsername(ctx context.Context, username string) (bool, error) {
 	exists, err := svc.UserRepo.ExistsByUsername(ctx, username)
 	if err != nil {
@@ -56,23 +125,360 @@ func (svc *Service) UserExistsByEmailAddress(ctx context.Context, emailAddress s
 }
 
 func (svc *Service) CreateUser(ctx context.Context, user *User) error {
-	err := svc.UserRepo.Create(ctx, user)
+	err := ValidateUsername(user.Username)
+	if err != nil {
+		return err
+	}
+
+	err = svc.UserRepo.Create(ctx, user)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
+	svc.Auditor.Record(contextWithUserActor(ctx, user), audit.Event{
+		Action:     audit.ActionUserRegistered,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   user.ID,
+		After:      profileAuditFields(user),
+	})
+	svc.Events.Publish(ctx, UserRegistered{User: *user})
+
 	return nil
 }
 
+// UpdateUser saves the profile of a user. Passwords are changed with ChangePassword and ResetPassword.
 func (svc *Service) UpdateUser(ctx context.Context, user *User) error {
+	err := validateProfile(user)
+	if err != nil {
+		return err
+	}
+
+	before, err := svc.UserRepo.GetByID(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	err = svc.UserRepo.Update(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionProfileUpdated,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   user.ID,
+		Before:     profileAuditFields(before),
+		After:      profileAuditFields(user),
+	})
+
+	return nil
+}
+
+// SetAvatar replaces the uploaded avatar of a user with the image read from r. It returns ErrUnsupportedAvatar and
+// ErrAvatarTooLarge for images that cannot be used.
+func (svc *Service) SetAvatar(ctx context.Context, userID string, r io.Reader) error {
+	images, err := ScaleAvatar(r)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	avatars := make([]*Avatar, 0, len(images))
+	for _, size := range AvatarSizes {
+		avatars = append(avatars, &Avatar{UserID: userID, Size: size, Image: images[size], CreatedAt: now})
+	}
+
+	err = svc.AvatarRepo.Replace(ctx, userID, avatars)
+	if err != nil {
+		return fmt.Errorf("failed to replace avatars: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveAvatar deletes the uploaded avatar of a user, so the fallback avatar is shown again.
+func (svc *Service) RemoveAvatar(ctx context.Context, userID string) error {
+	err := svc.AvatarRepo.Delete(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete avatars: %w", err)
+	}
+
+	return nil
+}
+
+// GetAvatar returns the uploaded avatar of a user in the smallest stored size that is at least size.
+func (svc *Service) GetAvatar(ctx context.Context, userID string, size int) (*Avatar, error) {
+	avatar, err := svc.AvatarRepo.Get(ctx, userID, AvatarSize(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get avatar: %w", err)
+	}
+
+	return avatar, nil
+}
+
+func (svc *Service) ChangePassword(ctx context.Context, user *User, passwordHash string) error {
+	user.PasswordHash = passwordHash
+
 	err := svc.UserRepo.Update(ctx, user)
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionPasswordChanged,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   user.ID,
+	})
+
+	return nil
+}
+
+// ResetPassword sets the password of the owner of a reset token and uses the token up.
+func (svc *Service) ResetPassword(ctx context.Context, token *PasswordResetToken, passwordHash string) error {
+	user, err := svc.UserRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = time.Now()
+
+	err = svc.UserRepo.Update(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	err = svc.PasswordResetTokenRepo.Delete(ctx, token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete password reset token: %w", err)
+	}
+
+	svc.Auditor.Record(contextWithUserActor(ctx, user), audit.Event{
+		Action:     audit.ActionPasswordReset,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   user.ID,
+	})
+	svc.Events.Publish(ctx, PasswordReset{User: *user})
+
+	return nil
+}
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Authenticate checks the credentials of a user and whether they may sign in, records the sign-in and reports every
+// attempt to the audit log.
+func (svc *Service) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, err := svc.UserRepo.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.As(err, &UserByUsernameNotFoundError{}) {
+			svc.recordLoginFailure(ctx, username, "unknown username")
+
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		svc.recordLoginFailure(ctx, user.ID, "wrong password")
+
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+
+	if user.IsDisabled() {
+		svc.recordLoginFailure(ctx, user.ID, "account disabled")
+
+		return nil, UserDisabledError{Username: user.Username}
+	}
+
+	if user.IsBanned(now) {
+		svc.recordLoginFailure(ctx, user.ID, "account banned")
+
+		return nil, UserBannedError{Username: user.Username, Until: *user.BannedUntil}
+	}
+
+	ctx = contextWithUserActor(ctx, user)
+	actor := audit.ActorFromContext(ctx)
+
+	err = svc.LoginRepo.Create(ctx, &Login{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create login: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionLoginSucceeded,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   user.ID,
+	})
+
+	return user, nil
+}
+
+// recordLoginFailure reports a failed sign-in. The target is the user ID, or the username when there is no such
+// user.
+func (svc *Service) recordLoginFailure(ctx context.Context, target, reason string) {
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionLoginFailed,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   target,
+		After:      map[string]any{"reason": reason},
+	})
+}
+
+// ErrDeletedUserPlaceholder is returned when an account change targets the placeholder that owns the content of
+// deleted users.
+var ErrDeletedUserPlaceholder = errors.New("the deleted user placeholder cannot be changed")
+
+// SetAdmin grants or revokes the administrator role.
+func (svc *Service) SetAdmin(ctx context.Context, id string, isAdmin bool) error {
+	if id == DeletedUserID {
+		return ErrDeletedUserPlaceholder
+	}
+
+	user, err := svc.UserRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	err = svc.UserRepo.SetAdmin(ctx, id, isAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to set admin: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionRoleChanged,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   id,
+		Before:     map[string]any{"isAdmin": user.IsAdmin},
+		After:      map[string]any{"isAdmin": isAdmin},
+	})
+
 	return nil
 }
 
+// DisableUser keeps a user from signing in until the user is enabled again.
+func (svc *Service) DisableUser(ctx context.Context, id string) error {
+	now := time.Now()
+
+	return svc.setDisabledAt(ctx, id, &now, audit.ActionUserDisabled)
+}
+
+func (svc *Service) EnableUser(ctx context.Context, id string) error {
+	return svc.setDisabledAt(ctx, id, nil, audit.ActionUserEnabled)
+}
+
+func (svc *Service) setDisabledAt(ctx context.Context, id string, disabledAt *time.Time, action audit.Action) error {
+	if id == DeletedUserID {
+		return ErrDeletedUserPlaceholder
+	}
+
+	user, err := svc.UserRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	err = svc.UserRepo.SetDisabledAt(ctx, id, disabledAt)
+	if err != nil {
+		return fmt.Errorf("failed to set disabled at: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     action,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   id,
+		Before:     map[string]any{"disabledAt": user.DisabledAt},
+		After:      map[string]any{"disabledAt": disabledAt},
+	})
+
+	return nil
+}
+
+// BanUser keeps a user from signing in until the given time.
+func (svc *Service) BanUser(ctx context.Context, id string, until time.Time) error {
+	return svc.setBannedUntil(ctx, id, &until, audit.ActionUserBanned)
+}
+
+func (svc *Service) UnbanUser(ctx context.Context, id string) error {
+	return svc.setBannedUntil(ctx, id, nil, audit.ActionUserUnbanned)
+}
+
+func (svc *Service) setBannedUntil(ctx context.Context, id string, bannedUntil *time.Time, action audit.Action) error {
+	if id == DeletedUserID {
+		return ErrDeletedUserPlaceholder
+	}
+
+	user, err := svc.UserRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	err = svc.UserRepo.SetBannedUntil(ctx, id, bannedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to set banned until: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     action,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   id,
+		Before:     map[string]any{"bannedUntil": user.BannedUntil},
+		After:      map[string]any{"bannedUntil": bannedUntil},
+	})
+
+	return nil
+}
+
+// DeleteUser removes a user account and either anonymizes or deletes the content they authored.
+func (svc *Service) DeleteUser(ctx context.Context, id string, content AuthoredContent) error {
+	if id == DeletedUserID {
+		return ErrDeletedUserPlaceholder
+	}
+
+	if !content.IsValid() {
+		return InvalidAuthoredContentError{Content: content}
+	}
+
+	user, err := svc.UserRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	err = svc.UserRepo.Delete(ctx, id, content)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	svc.Auditor.Record(ctx, audit.Event{
+		Action:     audit.ActionUserDeleted,
+		TargetType: audit.TargetTypeUser,
+		TargetID:   id,
+		// The audit log outlives the account, so only what identifies the entry is kept.
+		Before: map[string]any{"username": user.Username},
+		After:  map[string]any{"authoredContent": string(content)},
+	})
+
+	return nil
+}
+
+func (svc *Service) ListLogins(ctx context.Context, userID string) ([]*Login, error) {
+	logins, err := svc.LoginRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logins: %w", err)
+	}
+
+	return logins, nil
+}
+
 func (svc *Service) CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
 	err := svc.PasswordResetTokenRepo.Create(ctx, token)
 	if err != nil {
@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+)
+
+type recorder struct {
+	events []audit.Event
+}
+
+func (rec *recorder) Record(_ context.Context, event audit.Event) {
+	rec.events = append(rec.events, event)
+}
+
+func newService(t *testing.T) (*auth.Service, *recorder) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	rec := &recorder{}
+
+	return &auth.Service{UserRepo: &sqlite3.UserRepo{DB: db}, Auditor: rec}, rec
+}
+
+func TestDeleteUserKeepsProfileOutOfAuditLog(t *testing.T) {
+	svc, rec := newService(t)
+
+	now := time.Now()
+
+	user := &auth.User{
+		ID:           "leaver-id",
+		Username:     "leaver",
+		EmailAddress: "leaver@example.com",
+		Name:         "Lee Ver",
+		Bio:          "Private bio",
+		Links:        []string{"https://leaver.example.com"},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err := svc.UserRepo.Create(t.Context(), user)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	err = svc.DeleteUser(t.Context(), user.ID, auth.AuthoredContentAnonymize)
+	if err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+
+	if len(rec.events) != 1 || rec.events[0].Action != audit.ActionUserDeleted {
+		t.Fatalf("expected a user deleted event, got %+v", rec.events)
+	}
+
+	event := rec.events[0]
+
+	if event.TargetID != user.ID || len(event.Before) != 1 || event.Before["username"] != user.Username {
+		t.Errorf("expected only the id and username of the deleted user, got %s %v", event.TargetID, event.Before)
+	}
+}
+
+func TestDeletedUserPlaceholderCannotBeChanged(t *testing.T) {
+	svc, rec := newService(t)
+
+	for name, change := range map[string]func() error{
+		"enable":     func() error { return svc.EnableUser(t.Context(), auth.DeletedUserID) },
+		"disable":    func() error { return svc.DisableUser(t.Context(), auth.DeletedUserID) },
+		"ban":        func() error { return svc.BanUser(t.Context(), auth.DeletedUserID, time.Now().Add(time.Hour)) },
+		"unban":      func() error { return svc.UnbanUser(t.Context(), auth.DeletedUserID) },
+		"make admin": func() error { return svc.SetAdmin(t.Context(), auth.DeletedUserID, true) },
+		"delete": func() error {
+			return svc.DeleteUser(t.Context(), auth.DeletedUserID, auth.AuthoredContentAnonymize)
+		},
+	} {
+		err := change()
+		if !errors.Is(err, auth.ErrDeletedUserPlaceholder) {
+			t.Errorf("%s: expected ErrDeletedUserPlaceholder, got %v", name, err)
+		}
+	}
+
+	if len(rec.events) != 0 {
+		t.Errorf("expected no audit events, got %+v", rec.events)
+	}
+
+	placeholder, err := svc.GetUserByID(t.Context(), auth.DeletedUserID)
+	if err != nil {
+		t.Fatalf("failed to get the placeholder: %v", err)
+	}
+
+	if placeholder.IsAdmin || placeholder.DisabledAt == nil || placeholder.BannedUntil != nil {
+		t.Errorf("expected the placeholder to be left as it was, got %+v", placeholder)
+	}
+}
@@ -2,8 +2,13 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type User struct {
@@ -13,13 +18,92 @@ type User struct {
 	PasswordHash string
 	Name         string
 	AvatarURL    string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// Bio and Links are shown on the public profile of the user.
+	Bio         string
+	Links       []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	IsAdmin     bool
+	DisabledAt  *time.Time
+	BannedUntil *time.Time
+}
+
+const (
+	MaxBioLength = 500
+	MaxLinks     = 5
+)
+
+// ParseLinks parses the website links of a profile written one per line.
+func ParseLinks(s string) []string {
+	var links []string
+
+	for line := range strings.Lines(s) {
+		if link := strings.TrimSpace(line); link != "" {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+func (user *User) IsDisabled() bool {
+	return user.DisabledAt != nil
+}
+
+// IsBanned reports whether the user is banned at the given time.
+func (user *User) IsBanned(at time.Time) bool {
+	return user.BannedUntil != nil && at.Before(*user.BannedUntil)
+}
+
+// HasPublicProfile reports whether the user has a public profile page and can be mentioned.
+func (user *User) HasPublicProfile() bool {
+	return user.ID != DeletedUserID && !user.IsDisabled()
+}
+
+// usernameRegexp matches the usernames that can be registered. They are the same as the ones a mention can name, so
+// the deleted user placeholder, whose username has brackets, cannot be registered.
+var usernameRegexp = regexp.MustCompile(`^[\w.-]*\w$`)
+
+// ValidateUsername checks that a username can be registered.
+func ValidateUsername(username string) error {
+	if !usernameRegexp.MatchString(username) {
+		return ErrInvalidUsername
+	}
+
+	return nil
+}
+
+// DeletedUserID is the placeholder account that content of anonymized deleted users is reassigned to. It is
+// created by the migrations and can never sign in.
+const DeletedUserID = "00000000-0000-0000-0000-000000000000"
+
+// AuthoredContent tells what happens to the posts and comments of a deleted user.
+type AuthoredContent string
+
+const (
+	// AuthoredContentAnonymize reassigns the posts and comments to the deleted user placeholder.
+	AuthoredContentAnonymize AuthoredContent = "anonymize"
+	// AuthoredContentDelete deletes the posts, the comments on them and the comments of the user.
+	AuthoredContentDelete AuthoredContent = "delete"
+)
+
+func (c AuthoredContent) IsValid() bool {
+	return c == AuthoredContentAnonymize || c == AuthoredContentDelete
 }
 
 type ListUsersParams struct {
 	Username     string
 	EmailAddress string
+	// Search matches the username, name and email address.
+	Search         string
+	UsernamePrefix string
+	CreatedAfter   time.Time
+	// SortBy is one of "username", "name", "email_address" or "created_at". Users are listed newest first by
+	// default.
+	SortBy   string
+	SortDesc bool
+	Limit    int
+	Offset   int
 }
 
 type UserRepository interface {
@@ -27,10 +111,17 @@ type UserRepository interface {
 	GetByEmailAddress(ctx context.Context, emailAddress string) (user *User, err error)
 	GetByID(ctx context.Context, id string) (user *User, err error)
 	List(ctx context.Context, params ListUsersParams) (users []*User, err error)
+	Count(ctx context.Context, params ListUsersParams) (count int, err error)
 	ExistsByUsername(ctx context.Context, username string) (exists bool, err error)
 	ExistsByEmailAddress(ctx context.Context, emailAddress string) (exists bool, err error)
 	Create(ctx context.Context, user *User) (err error)
 	Update(ctx context.Context, user *User) (err error)
+	SetDisabledAt(ctx context.Context, id string, disabledAt *time.Time) (err error)
+	SetBannedUntil(ctx context.Context, id string, bannedUntil *time.Time) (err error)
+	SetAdmin(ctx context.Context, id string, isAdmin bool) (err error)
+	// Delete removes the user with their password reset tokens, login history, data exports and the mentions of them,
+	// and handles their posts and comments in the same transaction.
+	Delete(ctx context.Context, id string, content AuthoredContent) (err error)
 }
 
 type UserByUsernameNotFoundError struct {
@@ -56,3 +147,72 @@ type UserByIDNotFoundError struct {
 func (err UserByIDNotFoundError) Error() string {
 	return fmt.Sprintf("user by id '%s' not found", err.ID)
 }
+
+type InvalidAuthoredContentError struct {
+	Content AuthoredContent
+}
+
+func (err InvalidAuthoredContentError) Error() string {
+	return fmt.Sprintf("invalid authored content option '%s'", err.Content)
+}
+
+type UserDisabledError struct {
+	Username string
+}
+
+func (err UserDisabledError) Error() string {
+	return fmt.Sprintf("user '%s' is disabled", err.Username)
+}
+
+type UserBannedError struct {
+	Username string
+	Until    time.Time
+}
+
+func (err UserBannedError) Error() string {
+	return fmt.Sprintf("user '%s' is banned until %s", err.Username, err.Until.Format(time.RFC3339))
+}
+
+var (
+	ErrInvalidUsername  = errors.New("invalid username")
+	ErrBioTooLong       = errors.New("bio is too long")
+	ErrTooManyLinks     = errors.New("too many links")
+	ErrInvalidAvatarURL = errors.New("invalid avatar URL")
+)
+
+type InvalidLinkError struct {
+	Link string
+}
+
+func (err InvalidLinkError) Error() string {
+	return fmt.Sprintf("invalid link '%s'", err.Link)
+}
+
+// validateProfile checks the parts of a profile that are shown on the public profile page.
+func validateProfile(user *User) error {
+	if utf8.RuneCountInString(user.Bio) > MaxBioLength {
+		return ErrBioTooLong
+	}
+
+	if len(user.Links) > MaxLinks {
+		return ErrTooManyLinks
+	}
+
+	for _, link := range user.Links {
+		if !isWebURL(link) {
+			return InvalidLinkError{Link: link}
+		}
+	}
+
+	if user.AvatarURL != "" && !isWebURL(user.AvatarURL) {
+		return ErrInvalidAvatarURL
+	}
+
+	return nil
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -6,28 +6,61 @@ import (
 	"time"
 )
 
+type CommentStatus string
+
+const (
+	CommentStatusApproved CommentStatus = "approved"
+	CommentStatusPending  CommentStatus = "pending"
+)
+
 type Comment struct {
-	ID            string
-	PostID        string
+	ID     string
+	PostID string
+	// ParentID is the comment this one replies to, or empty.
+	ParentID      string
 	UserID        string
 	UserUsername  string
 	UserName      string
 	UserAvatarURL string
 	Content       string
+	Status        CommentStatus
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
+	DeletedAt     *time.Time
 }
 
+// ListCommentsParams lists approved comments unless Status or IncludePending asks for pending ones, so comments held
+// for moderation only show up where they are asked for.
 type ListCommentsParams struct {
 	PostID string
+	UserID string
+	Status CommentStatus
+	// IncludePending lists pending comments along with approved ones when Status is empty.
+	IncludePending bool
+	Trashed        bool
+	// PostNotTrashed leaves out comments on posts that are in trash.
+	PostNotTrashed bool
+	// Search matches the content.
+	Search string
+	// SortBy is "created_at". Comments are listed oldest first by default.
+	SortBy   string
+	SortDesc bool
+	Limit    int
+	Offset   int
 }
 
 type CommentRepository interface {
 	Create(ctx context.Context, comment *Comment) (err error)
 	List(ctx context.Context, params ListCommentsParams) (comments []*Comment, err error)
+	Count(ctx context.Context, params ListCommentsParams) (count int, err error)
 	GetByID(ctx context.Context, id string) (comment *Comment, err error)
+	GetTrashedByID(ctx context.Context, id string) (comment *Comment, err error)
 	Update(ctx context.Context, comment *Comment) (err error)
+	Approve(ctx context.Context, id string) (err error)
 	Delete(ctx context.Context, id string) (err error)
+	Restore(ctx context.Context, id string) (err error)
+	Purge(ctx context.Context, id string) (err error)
+	PurgeTrashed(ctx context.Context, deletedBefore time.Time) (count int, err error)
 }
 
 type CommentByIDNotFoundError struct {
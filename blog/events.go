@@ -0,0 +1,99 @@
+package blog
+
+import "time"
+
+// PostPublished is published when a new post goes live.
+type PostPublished struct {
+	Post Post
+}
+
+func (PostPublished) EventName() string {
+	return "post.published"
+}
+
+// PostUpdated is published when the content or slug of a post changes.
+type PostUpdated struct {
+	Post Post
+	// MentionsSavedAt is when the mentions of the post were saved. Mentions saved then are new in this update.
+	MentionsSavedAt time.Time
+}
+
+func (PostUpdated) EventName() string {
+	return "post.updated"
+}
+
+// PostAuthorChanged is published when a post is transferred to another author. The content is unchanged.
+type PostAuthorChanged struct {
+	Post           Post
+	FormerAuthorID string
+}
+
+func (PostAuthorChanged) EventName() string {
+	return "post.author_changed"
+}
+
+// PostDeleted is published when a post is moved to the trash.
+type PostDeleted struct {
+	Post Post
+}
+
+func (PostDeleted) EventName() string {
+	return "post.deleted"
+}
+
+// PostRestored is published when a post is restored from the trash.
+type PostRestored struct {
+	Post Post
+}
+
+func (PostRestored) EventName() string {
+	return "post.restored"
+}
+
+// PostPurged is published when a post is permanently deleted from the trash.
+type PostPurged struct {
+	Post Post
+}
+
+func (PostPurged) EventName() string {
+	return "post.purged"
+}
+
+// CommentCreated is published for every new comment, including the ones held for moderation.
+type CommentCreated struct {
+	Comment Comment
+}
+
+func (CommentCreated) EventName() string {
+	return "comment.created"
+}
+
+// CommentApproved is published when a comment held for moderation is approved.
+type CommentApproved struct {
+	Comment     Comment
+	ModeratorID string
+}
+
+func (CommentApproved) EventName() string {
+	return "comment.approved"
+}
+
+// CommentUpdated is published when the content of a comment is edited.
+type CommentUpdated struct {
+	Comment Comment
+}
+
+func (CommentUpdated) EventName() string {
+	return "comment.updated"
+}
+
+// CommentDeleted is published when a comment is moved to the trash. ModeratorID is the user who trashed it, which is
+// the author when they delete their own comment.
+type CommentDeleted struct {
+	Comment     Comment
+	ModeratorID string
+}
+
+func (CommentDeleted) EventName() string {
+	return "comment.deleted"
+}
@@ -0,0 +1,21 @@
+package blog
+
+import (
+	"golang.org/x/net/html/atom"
+)
+
+// FeaturedImageURL returns the source of the first image in content, or an empty string if it has none.
+func FeaturedImageURL(content string) string {
+	body, err := parseHTMLFragment(content)
+	if err != nil {
+		return ""
+	}
+
+	for node := range body.Descendants() {
+		if node.DataAtom == atom.Img && attr(node, "src") != "" {
+			return attr(node, "src")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,133 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	HighlightLightStyle = "github"
+	HighlightDarkStyle  = "github-dark"
+)
+
+// HighlightCodeBlocks replaces the content of every <pre><code class="language-x"> block with
+// class-based chroma spans. Blocks in unknown languages are left untouched.
+func HighlightCodeBlocks(content string) (string, error) {
+	if !strings.Contains(content, "language-") {
+		return content, nil
+	}
+
+	body, err := parseHTMLFragment(content)
+	if err != nil {
+		return "", err
+	}
+
+	for pre := range body.Descendants() {
+		if pre.DataAtom != atom.Pre || pre.FirstChild == nil || pre.FirstChild.DataAtom != atom.Code {
+			continue
+		}
+
+		err = highlightCodeBlock(pre, pre.FirstChild)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return renderHTMLFragment(body)
+}
+
+func highlightCodeBlock(pre, code *html.Node) error {
+	language := ""
+
+	for class := range strings.FieldsSeq(attr(code, "class")) {
+		if lang, ok := strings.CutPrefix(class, "language-"); ok {
+			language = lang
+		}
+	}
+
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		return nil
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, textContent(code))
+	if err != nil {
+		return fmt.Errorf("failed to tokenise %s code: %w", language, err)
+	}
+
+	for child := code.FirstChild; child != nil; child = code.FirstChild {
+		code.RemoveChild(child)
+	}
+
+	for _, token := range iterator.Tokens() {
+		text := &html.Node{Type: html.TextNode, Data: token.Value}
+
+		class := tokenClass(token.Type)
+		if class == "" {
+			code.AppendChild(text)
+
+			continue
+		}
+
+		span := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "span",
+			DataAtom: atom.Span,
+			Attr:     []html.Attribute{{Key: "class", Val: class}},
+		}
+		span.AppendChild(text)
+		code.AppendChild(span)
+	}
+
+	pre.Attr = []html.Attribute{{Key: "class", Val: "chroma"}}
+
+	return nil
+}
+
+func tokenClass(tokenType chroma.TokenType) string {
+	for _, tt := range []chroma.TokenType{tokenType, tokenType.SubCategory(), tokenType.Category()} {
+		if class := chroma.StandardTypes[tt]; class != "" {
+			return class
+		}
+	}
+
+	return ""
+}
+
+// HighlightCSS returns the stylesheet for highlighted code blocks, using the dark style when the
+// user prefers a dark color scheme.
+func HighlightCSS() ([]byte, error) {
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+
+	// The light style leaves the text color to the page, which is too pale inside prose code blocks.
+	light, err := styles.Get(HighlightLightStyle).Builder().Add(chroma.Background, "#1f2328 bg:#ffffff").Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build light style: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = formatter.WriteCSS(&buf, light)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write light style css: %w", err)
+	}
+
+	buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+
+	err = formatter.WriteCSS(&buf, styles.Get(HighlightDarkStyle))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write dark style css: %w", err)
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
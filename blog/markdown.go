@@ -0,0 +1,491 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type PostFormat string
+
+const (
+	PostFormatHTML     PostFormat = "html"
+	PostFormatMarkdown PostFormat = "markdown"
+)
+
+func (f PostFormat) IsValid() bool {
+	return f == PostFormatHTML || f == PostFormatMarkdown
+}
+
+// CommonMark with the GFM tables, task lists and strikethrough plus footnotes.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.TaskList,
+		extension.Strikethrough,
+		extension.Footnote,
+	),
+)
+
+// RenderMarkdown converts Markdown source to HTML. The result is not sanitized.
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+
+	err := markdown.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert markdown: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// HTMLToMarkdown converts the subset of HTML produced by the editor back to Markdown.
+// Elements without a Markdown equivalent are reduced to their text content.
+func HTMLToMarkdown(source string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(source), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	conv := &markdownConverter{}
+
+	for _, node := range nodes {
+		conv.block(node)
+	}
+
+	return strings.TrimSpace(conv.buf.String()) + "\n", nil
+}
+
+type markdownConverter struct {
+	buf strings.Builder
+}
+
+func (conv *markdownConverter) blockBreak() {
+	out := conv.buf.String()
+	if out == "" || strings.HasSuffix(out, "\n\n") {
+		return
+	}
+
+	if strings.HasSuffix(out, "\n") {
+		conv.buf.WriteString("\n")
+
+		return
+	}
+
+	conv.buf.WriteString("\n\n")
+}
+
+func (conv *markdownConverter) block(node *html.Node) {
+	if node.Type == html.TextNode {
+		if strings.TrimSpace(node.Data) != "" {
+			conv.writeInline(conv.inlineText(node))
+		}
+
+		return
+	}
+
+	if node.Type != html.ElementNode {
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(node.Data[1:])
+
+		conv.blockBreak()
+		conv.buf.WriteString(strings.Repeat("#", level) + " " + strings.TrimSpace(conv.inlineChildren(node)))
+		conv.blockBreak()
+	case atom.P:
+		conv.blockBreak()
+		conv.buf.WriteString(escapeLineStarts(strings.TrimSpace(conv.inlineChildren(node))))
+		conv.blockBreak()
+	case atom.Pre:
+		conv.blockBreak()
+		conv.codeBlock(node)
+		conv.blockBreak()
+	case atom.Blockquote:
+		inner := &markdownConverter{}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			inner.block(child)
+		}
+
+		conv.blockBreak()
+
+		for line := range strings.SplitSeq(strings.TrimSpace(inner.buf.String()), "\n") {
+			conv.buf.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+
+		conv.blockBreak()
+	case atom.Ul, atom.Ol:
+		conv.blockBreak()
+		conv.list(node)
+		conv.blockBreak()
+	case atom.Table:
+		conv.blockBreak()
+		conv.table(node)
+		conv.blockBreak()
+	case atom.Hr:
+		conv.blockBreak()
+		conv.buf.WriteString("---")
+		conv.blockBreak()
+	case atom.Br:
+		conv.buf.WriteString("  \n")
+	case atom.Div, atom.Section, atom.Article, atom.Body:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			conv.block(child)
+		}
+	default:
+		conv.writeInline(conv.inline(node))
+	}
+}
+
+// writeInline writes inline content outside a paragraph, escaping it when it starts a line.
+func (conv *markdownConverter) writeInline(content string) {
+	if out := conv.buf.String(); out == "" || strings.HasSuffix(out, "\n") {
+		content = escapeLineStarts(content)
+	}
+
+	conv.buf.WriteString(content)
+}
+
+func (conv *markdownConverter) codeBlock(node *html.Node) {
+	language := ""
+	code := textContent(node)
+
+	if child := node.FirstChild; child != nil && child.DataAtom == atom.Code {
+		for class := range strings.FieldsSeq(attr(child, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = lang
+			}
+		}
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	conv.buf.WriteString(fence + language + "\n" + strings.TrimSuffix(code, "\n") + "\n" + fence)
+}
+
+func (conv *markdownConverter) table(node *html.Node) {
+	var rows [][]string
+
+	var collect func(n *html.Node)
+
+	collect = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(child)
+			case atom.Tr:
+				var cells []string
+
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Th || cell.DataAtom == atom.Td {
+						text := strings.TrimSpace(conv.inlineChildren(cell))
+						cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+
+				rows = append(rows, cells)
+			default:
+			}
+		}
+	}
+
+	collect(node)
+
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	writeRow := func(cells []string) {
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+
+		conv.buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	separator := make([]string, columns)
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	writeRow(rows[0])
+	writeRow(separator)
+
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+}
+
+func (conv *markdownConverter) list(node *html.Node) {
+	index := 1
+
+	if start, err := strconv.Atoi(attr(node, "start")); err == nil {
+		index = start
+	}
+
+	for item := node.FirstChild; item != nil; item = item.NextSibling {
+		if item.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if node.DataAtom == atom.Ol {
+			marker = strconv.Itoa(index) + ". "
+			index++
+		}
+
+		inner := &markdownConverter{}
+		inner.listItem(item)
+
+		// Continuation lines are indented to the content of the item, so its blocks and nested lists stay in it.
+		indent := strings.Repeat(" ", len(marker))
+
+		for i, line := range strings.Split(strings.TrimSpace(inner.buf.String()), "\n") {
+			switch {
+			case i == 0:
+				conv.buf.WriteString(marker + line)
+			case line != "":
+				conv.buf.WriteString(indent + line)
+			}
+
+			conv.buf.WriteString("\n")
+		}
+	}
+}
+
+// listItem writes the content of a list item. Its text and nested lists are written without blank lines between
+// them, which keeps the list tight, and its other blocks are written as blocks.
+func (conv *markdownConverter) listItem(item *html.Node) {
+	var text strings.Builder
+
+	flush := func() {
+		if trimmed := strings.TrimSpace(text.String()); trimmed != "" {
+			conv.blockBreak()
+			conv.buf.WriteString(escapeLineStarts(trimmed))
+		}
+
+		text.Reset()
+	}
+
+	for child := item.FirstChild; child != nil; child = child.NextSibling {
+		switch child.DataAtom {
+		case atom.P:
+			flush()
+			text.WriteString(conv.inlineChildren(child))
+			flush()
+		case atom.Ul, atom.Ol:
+			flush()
+
+			if out := conv.buf.String(); out != "" && !strings.HasSuffix(out, "\n") {
+				conv.buf.WriteString("\n")
+			}
+
+			conv.list(child)
+		case atom.Pre, atom.Blockquote, atom.Table, atom.Hr, atom.Div,
+			atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			flush()
+			conv.block(child)
+		default:
+			text.WriteString(conv.inline(child))
+		}
+	}
+
+	flush()
+}
+
+func (conv *markdownConverter) inlineChildren(node *html.Node) string {
+	var sb strings.Builder
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(conv.inline(child))
+	}
+
+	return sb.String()
+}
+
+func (conv *markdownConverter) inline(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return conv.inlineText(node)
+	}
+
+	if node.Type != html.ElementNode {
+		return ""
+	}
+
+	content := conv.inlineChildren(node)
+
+	switch node.DataAtom {
+	case atom.Strong, atom.B:
+		return wrapInline(content, "**")
+	case atom.Em, atom.I:
+		return wrapInline(content, "_")
+	case atom.S, atom.Del, atom.Strike:
+		return wrapInline(content, "~~")
+	case atom.Code:
+		// Line endings in code spans render as spaces, and keeping them would let a line of code start a block.
+		code := strings.ReplaceAll(textContent(node), "\n", " ")
+
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+
+		return fence + code + fence
+	case atom.A:
+		if isHeadingAnchor(node) {
+			return ""
+		}
+
+		return "[" + content + "](" + attr(node, "href") + ")"
+	case atom.Img:
+		return "![" + attr(node, "alt") + "](" + attr(node, "src") + ")"
+	case atom.Input:
+		if attr(node, "type") != "checkbox" {
+			return ""
+		}
+
+		if hasAttr(node, "checked") {
+			return "[x]"
+		}
+
+		return "[ ]"
+	case atom.Br:
+		return "  \n"
+	default:
+		return content
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// blockMarkerRegexp matches the start of a line that Markdown would read as a heading, a block quote, a list item,
+// a thematic break, a setext heading underline or a code fence.
+var blockMarkerRegexp = regexp.MustCompile(`(?m)^([ \t]*)([#>+=~-]|\d+[.)])`)
+
+// escapeLineStarts escapes the block markers at the start of every line of text, so it stays plain text.
+func escapeLineStarts(text string) string {
+	return blockMarkerRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		i := len(match) - 1
+
+		return match[:i] + `\` + match[i:]
+	})
+}
+
+func (conv *markdownConverter) inlineText(node *html.Node) string {
+	text := strings.Join(strings.Fields(node.Data), " ")
+
+	if strings.HasPrefix(node.Data, " ") || strings.HasPrefix(node.Data, "\n") {
+		text = " " + text
+	}
+
+	if text != " " && (strings.HasSuffix(node.Data, " ") || strings.HasSuffix(node.Data, "\n")) {
+		text += " "
+	}
+
+	return markdownEscaper.Replace(text)
+}
+
+// wrapInline keeps surrounding whitespace outside the emphasis markers, as Markdown requires.
+func wrapInline(content, marker string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+
+	leading := content[:strings.Index(content, trimmed)]
+	trailing := content[len(leading)+len(trimmed):]
+
+	return leading + marker + trimmed + marker + trailing
+}
+
+func textContent(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var sb strings.Builder
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+
+	return sb.String()
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func hasAttr(node *html.Node, key string) bool {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseHTMLFragment parses content into the children of a detached body element.
+func parseHTMLFragment(content string) (*html.Node, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+
+	return body, nil
+}
+
+func renderHTMLFragment(body *html.Node) (string, error) {
+	var buf bytes.Buffer
+
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		err := html.Render(&buf, child)
+		if err != nil {
+			return "", fmt.Errorf("failed to render html: %w", err)
+		}
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,141 @@
+package blog_test
+
+import (
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "heading marker in paragraph",
+			html: `<p># not a heading</p>`,
+			want: `\# not a heading`,
+		},
+		{
+			name: "ordered list marker in paragraph",
+			html: `<p>1. not a list</p>`,
+			want: `1\. not a list`,
+		},
+		{
+			name: "bullet list markers in paragraph",
+			html: `<p>- dash</p><p>+ plus</p>`,
+			want: "\\- dash\n\n\\+ plus",
+		},
+		{
+			name: "block quote marker in paragraph",
+			html: `<p>&gt; not a quote</p>`,
+			want: `\> not a quote`,
+		},
+		{
+			name: "marker after a line break",
+			html: `<p>first<br># second</p>`,
+			want: "first  \n\\# second",
+		},
+		{
+			name: "markers in the middle of a line",
+			html: `<p>a # b - c 1. d</p>`,
+			want: `a # b - c 1. d`,
+		},
+		{
+			name: "inline formatting",
+			html: `<p><strong>bold</strong> <em>it</em> <code>x*y</code> <a href="/a">link</a> 2*3</p>`,
+			want: "**bold** _it_ `x*y` [link](/a) 2\\*3",
+		},
+		{
+			name: "heading",
+			html: `<h2>Title</h2><p>text</p>`,
+			want: "## Title\n\ntext",
+		},
+		{
+			name: "code block",
+			html: "<pre><code class=\"language-go\">x := 1\n# y\n</code></pre>",
+			want: "```go\nx := 1\n# y\n```",
+		},
+		{
+			name: "tight list",
+			html: `<ul><li><p>a</p></li><li><p>b</p></li></ul>`,
+			want: "- a\n- b",
+		},
+		{
+			name: "list item with a marker",
+			html: `<ul><li><p>- a</p></li></ul>`,
+			want: `- \- a`,
+		},
+		{
+			name: "nested list",
+			html: `<ol><li><p>a</p><ul><li><p>b</p></li></ul></li><li><p>c</p></li></ol>`,
+			want: "1. a\n   - b\n2. c",
+		},
+		{
+			name: "list item with a code block",
+			html: "<ul><li><p>a</p><pre><code>x\ny</code></pre></li></ul>",
+			want: "- a\n\n  ```\n  x\n  y\n  ```",
+		},
+		{
+			name: "list item with paragraphs",
+			html: `<ul><li><p>a</p><p>b</p></li></ul>`,
+			want: "- a\n\n  b",
+		},
+		{
+			name: "block quote",
+			html: `<blockquote><p>a</p><p># b</p></blockquote>`,
+			want: "> a\n>\n> \\# b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := blog.HTMLToMarkdown(tt.html)
+			if err != nil {
+				t.Fatalf("failed to convert html: %v", err)
+			}
+
+			if got != tt.want+"\n" {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+// Converting rendered Markdown back to Markdown and rendering it again gives the same HTML.
+func TestHTMLToMarkdownRoundTrip(t *testing.T) {
+	sources := []string{
+		"# Title\n\nSome **bold**, _italic_ and `code` with a [link](https://example.com).\n",
+		"\\# not a heading\n\n1\\. not a list\n\n\\- not a bullet\n\n\\> not a quote\n",
+		"- a\n- b\n  - c\n  - d\n- e\n",
+		"1. one\n2. two\n   1. nested\n",
+		"- item\n\n  ```go\n  x := 1\n  y := 2\n  ```\n\n- next\n",
+		"> quote\n>\n> - in a list\n",
+		"```\n# a comment\n- not a list\n```\n",
+		"| a | b |\n| --- | --- |\n| 1 | 2 |\n",
+		"- [x] done\n- [ ] todo\n",
+		"a  \nb\n\n---\n\nc ~~struck~~\n",
+	}
+
+	for _, source := range sources {
+		want, err := blog.RenderMarkdown(source)
+		if err != nil {
+			t.Fatalf("failed to render markdown: %v", err)
+		}
+
+		converted, err := blog.HTMLToMarkdown(want)
+		if err != nil {
+			t.Fatalf("failed to convert html: %v", err)
+		}
+
+		got, err := blog.RenderMarkdown(converted)
+		if err != nil {
+			t.Fatalf("failed to render markdown: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("round trip of\n%s\nconverted to\n%s\nrendered\n%s\ninstead of\n%s", source, converted, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package blog
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Mention is a user mentioned with @username in a post or a comment.
+type Mention struct {
+	PostID string
+	// CommentID is empty for mentions in the content of the post.
+	CommentID string
+	Username  string
+	CreatedAt time.Time
+}
+
+type MentionRepository interface {
+	// Replace sets the mentions of a post, or of one of its comments when commentID is not empty, to usernames.
+	// Mentions that already exist keep their creation time.
+	Replace(ctx context.Context, postID, commentID string, usernames []string, createdAt time.Time) (err error)
+	List(ctx context.Context, postID, commentID string) (mentions []*Mention, err error)
+}
+
+// UserChecker tells whether a username belongs to a user, as auth.Service does.
+type UserChecker interface {
+	UserExistsByUsername(ctx context.Context, username string) (bool, error)
+}
+
+const mentionClass = "mention"
+
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\w@.])@([\w.-]*\w)`)
+
+// MentionPath is the path of the profile a mention links to.
+func MentionPath(username string) string {
+	return "/users/" + url.PathEscape(username)
+}
+
+// LinkMentions links the @username mentions of existing users in sanitized content to their profiles and returns
+// the processed content along with the mentioned usernames. Mentions in code and in other links are left alone.
+// Mentions linked by a previous run are kept, so processing the output again yields the same result.
+func LinkMentions(ctx context.Context, content string, users UserChecker) (string, []string, error) {
+	if !strings.Contains(content, "@") {
+		return content, nil, nil
+	}
+
+	body, err := parseHTMLFragment(content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		usernames []string
+		texts     []*html.Node
+	)
+
+	exists := make(map[string]bool)
+
+	for node := range body.Descendants() {
+		switch {
+		case node.Type == html.ElementNode && node.DataAtom == atom.A:
+			username, ok := strings.CutPrefix(textContent(node), "@")
+			if ok && attr(node, "href") == MentionPath(username) && !exists[username] {
+				exists[username] = true
+				usernames = append(usernames, username)
+			}
+		case node.Type == html.TextNode && strings.Contains(node.Data, "@") && !insideCodeOrLink(node):
+			texts = append(texts, node)
+		}
+	}
+
+	for _, text := range texts {
+		var (
+			nodes []*html.Node
+			last  int
+		)
+
+		for _, match := range mentionRegexp.FindAllStringSubmatchIndex(text.Data, -1) {
+			// The match starts with the character before the @.
+			start, end := match[2]-1, match[3]
+			username := text.Data[match[2]:match[3]]
+
+			ok, known := exists[username]
+			if !known {
+				ok, err = users.UserExistsByUsername(ctx, username)
+				if err != nil {
+					return "", nil, fmt.Errorf("failed to check mentioned user: %w", err)
+				}
+
+				exists[username] = ok
+
+				if ok {
+					usernames = append(usernames, username)
+				}
+			}
+
+			if !ok {
+				continue
+			}
+
+			link := &html.Node{
+				Type:     html.ElementNode,
+				Data:     "a",
+				DataAtom: atom.A,
+				Attr: []html.Attribute{
+					{Key: "href", Val: MentionPath(username)},
+					{Key: "class", Val: mentionClass},
+				},
+			}
+			link.AppendChild(&html.Node{Type: html.TextNode, Data: "@" + username})
+
+			nodes = append(nodes, &html.Node{Type: html.TextNode, Data: text.Data[last:start]}, link)
+			last = end
+		}
+
+		if nodes == nil {
+			continue
+		}
+
+		nodes = append(nodes, &html.Node{Type: html.TextNode, Data: text.Data[last:]})
+
+		for _, node := range nodes {
+			if node.Type != html.TextNode || node.Data != "" {
+				text.Parent.InsertBefore(node, text)
+			}
+		}
+
+		text.Parent.RemoveChild(text)
+	}
+
+	processed, err := renderHTMLFragment(body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return processed, usernames, nil
+}
+
+func insideCodeOrLink(node *html.Node) bool {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		switch parent.DataAtom {
+		case atom.Code, atom.Pre, atom.A:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package blog_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+type users []string
+
+func (u users) UserExistsByUsername(_ context.Context, username string) (bool, error) {
+	return slices.Contains(u, username), nil
+}
+
+func TestLinkMentions(t *testing.T) {
+	checker := users{"alice", "bob.smith", "carol_1"}
+
+	content := `<p>@alice, hi @bob.smith. Mail bob@example.com or @alice again (@carol_1) and @nobody</p>` +
+		`<pre><code>@alice</code></pre><p><code>@bob.smith</code> <a href="https://example.com">@carol_1</a></p>`
+
+	got, usernames, err := blog.LinkMentions(t.Context(), content, checker)
+	if err != nil {
+		t.Fatalf("failed to link mentions: %v", err)
+	}
+
+	if want := []string{"alice", "bob.smith", "carol_1"}; !slices.Equal(usernames, want) {
+		t.Errorf("expected %q, got %q", want, usernames)
+	}
+
+	want := `<p><a href="/users/alice" class="mention">@alice</a>, hi ` +
+		`<a href="/users/bob.smith" class="mention">@bob.smith</a>. Mail bob@example.com or ` +
+		`<a href="/users/alice" class="mention">@alice</a> again ` +
+		`(<a href="/users/carol_1" class="mention">@carol_1</a>) and @nobody</p>` +
+		`<pre><code>@alice</code></pre><p><code>@bob.smith</code> <a href="https://example.com">@carol_1</a></p>`
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	// Linked mentions are kept and still reported.
+	again, usernames, err := blog.LinkMentions(t.Context(), got, checker)
+	if err != nil {
+		t.Fatalf("failed to link mentions: %v", err)
+	}
+
+	if again != got || !slices.Equal(usernames, []string{"alice", "bob.smith", "carol_1"}) {
+		t.Errorf("expected the same result, got %q %q", again, usernames)
+	}
+
+	sanitized := blog.NewHTMLPolicy().Sanitize(got)
+	if !strings.Contains(sanitized, `class="mention"`) {
+		t.Errorf("expected the sanitizer to keep mention links, got %s", sanitized)
+	}
+}
@@ -0,0 +1,49 @@
+package blog
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// NewHTMLPolicy returns the policy used to sanitize post and comment content.
+// It extends the UGC policy with code block languages, the classes of highlighted code blocks,
+// heading ids and anchors, mention links, and what the Markdown renderer emits for task lists.
+func NewHTMLPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^chroma$`)).OnElements("pre")
+	policy.AllowAttrs("class").Matching(highlightClassRegexp()).OnElements("span")
+
+	policy.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)).
+		OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("class").
+		Matching(regexp.MustCompile(`^(` + headingAnchorClass + `|` + mentionClass + `)$`)).
+		OnElements("a")
+	policy.AllowAttrs("aria-hidden").Matching(regexp.MustCompile(`^true$`)).OnElements("a")
+
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return policy
+}
+
+// highlightClassRegexp matches the classes of the spans in highlighted code blocks and nothing else, so content
+// cannot borrow the classes of the site to move or hide parts of the page.
+func highlightClassRegexp() *regexp.Regexp {
+	classes := make([]string, 0, len(chroma.StandardTypes))
+
+	for _, class := range chroma.StandardTypes {
+		if class != "" {
+			classes = append(classes, regexp.QuoteMeta(class))
+		}
+	}
+
+	slices.Sort(classes)
+
+	return regexp.MustCompile(`^(` + strings.Join(slices.Compact(classes), "|") + `)$`)
+}
@@ -0,0 +1,28 @@
+package blog_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+func TestHTMLPolicySpanClasses(t *testing.T) {
+	policy := blog.NewHTMLPolicy()
+
+	for _, content := range []string{
+		`<p><span class="fixed">overlay</span></p>`,
+		`<p><span class="hidden">hidden</span></p>`,
+		`<p><span class="absolute inset-0">cover</span></p>`,
+	} {
+		if got := policy.Sanitize(content); strings.Contains(got, "class=") {
+			t.Errorf("expected the class to be removed from %s, got %s", content, got)
+		}
+	}
+
+	content := `<pre class="chroma"><code class="language-go"><span class="kd">func</span> <span class="nf">main</span>` +
+		`</code></pre>`
+	if got := policy.Sanitize(content); got != content {
+		t.Errorf("expected highlighted code to be kept, got %s", got)
+	}
+}
@@ -12,25 +12,47 @@ type Post struct {
 	Slug      string
 	Excerpt   string
 	Content   string
+	Format    PostFormat
+	Markdown  string
 	AuthorID  string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt *time.Time
+
+	// TOC is extracted from Content when the post is read for display and is not stored.
+	TOC TOC
 }
 
 type ListPostsParams struct {
-	Limit  int
-	Offset int
+	Limit    int
+	Offset   int
+	AuthorID string
+	Trashed  bool
+	// Search matches the title and slug.
+	Search       string
+	CreatedAfter time.Time
+	// SortBy is one of "title", "created_at" or "updated_at". Posts are listed newest first by default.
+	SortBy   string
+	SortDesc bool
 }
 
 type PostRepository interface {
 	List(ctx context.Context, params ListPostsParams) (posts []*Post, err error)
-	Count(ctx context.Context) (count int, err error)
+	Count(ctx context.Context, params ListPostsParams) (count int, err error)
 	GetBySlug(ctx context.Context, slug string) (post *Post, err error)
 	GetByID(ctx context.Context, id string) (post *Post, err error)
+	GetTrashedByID(ctx context.Context, id string) (post *Post, err error)
 	SlugExists(ctx context.Context, slug string) (exists bool, err error)
+	SlugReserved(ctx context.Context, slug string) (reserved bool, err error)
 	Create(ctx context.Context, post *Post) (err error)
 	Update(ctx context.Context, post *Post) (err error)
+	// Rename updates a post whose slug changed from formerSlug. In the same transaction it records formerSlug in the
+	// slug history and drops the history entry of the new slug when the post is taking back one of its own.
+	Rename(ctx context.Context, post *Post, formerSlug string) (err error)
 	Delete(ctx context.Context, id string) (err error)
+	Restore(ctx context.Context, id string) (err error)
+	Purge(ctx context.Context, id string) (err error)
+	PurgeTrashed(ctx context.Context, deletedBefore time.Time) (count int, err error)
 }
 
 type PostBySlugNotFoundError struct {
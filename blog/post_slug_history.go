@@ -0,0 +1,27 @@
+package blog
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PostSlugHistory records a slug that a post used before it was renamed.
+type PostSlugHistory struct {
+	Slug      string
+	PostID    string
+	CreatedAt time.Time
+}
+
+type PostSlugHistoryRepository interface {
+	GetBySlug(ctx context.Context, slug string) (history *PostSlugHistory, err error)
+	SlugExists(ctx context.Context, slug string) (exists bool, err error)
+}
+
+type PostSlugHistoryBySlugNotFoundError struct {
+	Slug string
+}
+
+func (err PostSlugHistoryBySlugNotFoundError) Error() string {
+	return fmt.Sprintf("post slug history with slug %q not found", err.Slug)
+}
@@ -1,9 +1,12 @@
 package blog
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"html"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -11,13 +14,73 @@ import (
 	"github.com/google/uuid"
 	slugify "github.com/gosimple/slug"
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/settings"
 )
 
 type Service struct {
-	PostRepo    PostRepository
-	CommentRepo CommentRepository
-	HTMLPolicy  *bluemonday.Policy
-	TextPolicy  *bluemonday.Policy
+	PostRepo            PostRepository
+	PostSlugHistoryRepo PostSlugHistoryRepository
+	CommentRepo         CommentRepository
+	MentionRepo         MentionRepository
+	UserChecker         UserChecker
+	HTMLPolicy          *bluemonday.Policy
+	TextPolicy          *bluemonday.Policy
+	TrashRetention      time.Duration
+	SettingsSvc         *settings.Service
+	Auditor             audit.Recorder
+	Events              eventbus.Publisher
+}
+
+// postAuditFields is the part of a post that is recorded in the audit log.
+func postAuditFields(post *Post) map[string]any {
+	return map[string]any{
+		"title":    post.Title,
+		"slug":     post.Slug,
+		"excerpt":  post.Excerpt,
+		"format":   string(post.Format),
+		"content":  post.Content,
+		"authorId": post.AuthorID,
+	}
+}
+
+// commentAuditFields is the part of a comment that is recorded in the audit log.
+func commentAuditFields(comment *Comment) map[string]any {
+	return map[string]any{
+		"postId":   comment.PostID,
+		"parentId": comment.ParentID,
+		"content":  comment.Content,
+		"status":   string(comment.Status),
+	}
+}
+
+func (svc *Service) recordPostEvent(ctx context.Context, action audit.Action, id string, before, after *Post) {
+	event := audit.Event{Action: action, TargetType: audit.TargetTypePost, TargetID: id}
+
+	if before != nil {
+		event.Before = postAuditFields(before)
+	}
+
+	if after != nil {
+		event.After = postAuditFields(after)
+	}
+
+	svc.Auditor.Record(ctx, event)
+}
+
+func (svc *Service) recordCommentEvent(ctx context.Context, action audit.Action, id string, before, after *Comment) {
+	event := audit.Event{Action: action, TargetType: audit.TargetTypeComment, TargetID: id}
+
+	if before != nil {
+		event.Before = commentAuditFields(before)
+	}
+
+	if after != nil {
+		event.After = commentAuditFields(after)
+	}
+
+	svc.Auditor.Record(ctx, event)
 }
 
 func (svc *Service) GetPostBySlug(ctx context.Context, slug string) (*Post, error) {
@@ -26,6 +89,12 @@ func (svc *Service) GetPostBySlug(ctx context.Context, slug string) (*Post, erro
 		return nil, fmt.Errorf("failed to get post by slug: %w", err)
 	}
 
+	// Posts saved before headings were processed get their anchors here as well.
+	post.Content, post.TOC, err = ProcessHeadings(post.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process headings: %w", err)
+	}
+
 	return post, nil
 }
 
@@ -38,6 +107,41 @@ func (svc *Service) PostBySlugExists(ctx context.Context, slug string) (bool, er
 	return exists, nil
 }
 
+// PostSlugReserved reports whether the slug is taken by any post, including trashed ones that are not purged yet
+// and former slugs of renamed posts.
+func (svc *Service) PostSlugReserved(ctx context.Context, slug string) (bool, error) {
+	reserved, err := svc.PostRepo.SlugReserved(ctx, slug)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if post slug is reserved: %w", err)
+	}
+
+	if reserved {
+		return true, nil
+	}
+
+	reserved, err = svc.PostSlugHistoryRepo.SlugExists(ctx, slug)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if post slug exists in history: %w", err)
+	}
+
+	return reserved, nil
+}
+
+// GetPostByFormerSlug returns the post that used the slug before it was renamed.
+func (svc *Service) GetPostByFormerSlug(ctx context.Context, slug string) (*Post, error) {
+	history, err := svc.PostSlugHistoryRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post slug history by slug: %w", err)
+	}
+
+	post, err := svc.PostRepo.GetByID(ctx, history.PostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post by ID: %w", err)
+	}
+
+	return post, nil
+}
+
 func (svc *Service) GetPostByID(ctx context.Context, id string) (*Post, error) {
 	post, err := svc.PostRepo.GetByID(ctx, id)
 	if err != nil {
@@ -47,6 +151,16 @@ func (svc *Service) GetPostByID(ctx context.Context, id string) (*Post, error) {
 	return post, nil
 }
 
+// ListMentions lists the mentions in a post, or in one of its comments when commentID is not empty.
+func (svc *Service) ListMentions(ctx context.Context, postID, commentID string) ([]*Mention, error) {
+	mentions, err := svc.MentionRepo.List(ctx, postID, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+
+	return mentions, nil
+}
+
 func (svc *Service) ListPosts(ctx context.Context, params ListPostsParams) ([]*Post, error) {
 	posts, err := svc.PostRepo.List(ctx, params)
 	if err != nil {
@@ -56,8 +170,8 @@ func (svc *Service) ListPosts(ctx context.Context, params ListPostsParams) ([]*P
 	return posts, nil
 }
 
-func (svc *Service) CountPosts(ctx context.Context) (int, error) {
-	count, err := svc.PostRepo.Count(ctx)
+func (svc *Service) CountPosts(ctx context.Context, params ListPostsParams) (int, error) {
+	count, err := svc.PostRepo.Count(ctx, params)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count posts: %w", err)
 	}
@@ -65,11 +179,33 @@ func (svc *Service) CountPosts(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// ChangePostAuthor transfers a post to another author without touching its content.
+func (svc *Service) ChangePostAuthor(ctx context.Context, id, authorID string) error {
+	post, err := svc.PostRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get post by ID: %w", err)
+	}
+
+	before := *post
+	post.AuthorID = authorID
+
+	err = svc.PostRepo.Update(ctx, post)
+	if err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+
+	svc.recordPostEvent(ctx, audit.ActionPostUpdated, post.ID, &before, post)
+	svc.Events.Publish(ctx, PostAuthorChanged{Post: *post, FormerAuthorID: before.AuthorID})
+
+	return nil
+}
+
 type CreatePostRequest struct {
 	Title    string
 	Slug     string
 	Excerpt  string
 	Content  string
+	Format   PostFormat
 	AuthorID string
 }
 
@@ -85,13 +221,28 @@ func (svc *Service) CreatePost(ctx context.Context, req *CreatePostRequest) (*Po
 		return nil, fmt.Errorf("failed to generate unique slug: %w", err)
 	}
 
+	req.Format = cmp.Or(req.Format, PostFormatHTML)
+
+	content, markdownContent, err := svc.convertPostContent(req.Content, req.Format, req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post content: %w", err)
+	}
+
+	content, mentioned, err := LinkMentions(ctx, content, svc.UserChecker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link mentions: %w", err)
+	}
+
 	if req.Excerpt == "" {
-		req.Excerpt = svc.TextPolicy.Sanitize(req.Content)
+		req.Excerpt = svc.TextPolicy.Sanitize(content)
 	}
 
-	req.Excerpt = svc.generateExcerpt(req.Excerpt, 160)
+	excerptLength, err := svc.SettingsSvc.GetInt(ctx, settings.KeyExcerptLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get excerpt length: %w", err)
+	}
 
-	req.Content = svc.HTMLPolicy.Sanitize(req.Content)
+	req.Excerpt = svc.generateExcerpt(req.Excerpt, excerptLength)
 
 	timeNow := time.Now()
 
@@ -100,7 +251,9 @@ func (svc *Service) CreatePost(ctx context.Context, req *CreatePostRequest) (*Po
 		Title:     req.Title,
 		Slug:      uniqueSlug,
 		Excerpt:   req.Excerpt,
-		Content:   req.Content,
+		Content:   content,
+		Format:    req.Format,
+		Markdown:  markdownContent,
 		AuthorID:  req.AuthorID,
 		CreatedAt: timeNow,
 		UpdatedAt: timeNow,
@@ -111,14 +264,25 @@ func (svc *Service) CreatePost(ctx context.Context, req *CreatePostRequest) (*Po
 		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
 
+	err = svc.MentionRepo.Replace(ctx, post.ID, "", mentioned, post.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mentions: %w", err)
+	}
+
+	svc.recordPostEvent(ctx, audit.ActionPostCreated, post.ID, nil, post)
+	svc.Events.Publish(ctx, PostPublished{Post: *post})
+
 	return post, nil
 }
 
+// UpdatePostRequest carries Content in the post's current format.
+// When Format differs from it, the content is converted to the new format.
 type UpdatePostRequest struct {
 	Title   string
 	Slug    string
 	Excerpt string
 	Content string
+	Format  PostFormat
 }
 
 func (svc *Service) UpdatePost(ctx context.Context, id string, req *UpdatePostRequest) (*Post, error) {
@@ -135,37 +299,169 @@ func (svc *Service) UpdatePost(ctx context.Context, id string, req *UpdatePostRe
 		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
 
-	if uniqueSlug != post.Slug {
-		var err error
+	before := *post
+	formerSlug := post.Slug
 
-		uniqueSlug, err = svc.generateUniqueSlug(ctx, req.Slug)
+	if uniqueSlug != formerSlug {
+		uniqueSlug, err = svc.generateUniqueSlugForPost(ctx, post.ID, req.Slug)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate unique slug: %w", err)
 		}
 	}
 
+	sourceFormat := cmp.Or(post.Format, PostFormatHTML)
+	req.Format = cmp.Or(req.Format, sourceFormat)
+
+	content, markdownContent, err := svc.convertPostContent(req.Content, sourceFormat, req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post content: %w", err)
+	}
+
+	content, mentioned, err := LinkMentions(ctx, content, svc.UserChecker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link mentions: %w", err)
+	}
+
 	if req.Excerpt == "" {
-		req.Excerpt = svc.TextPolicy.Sanitize(req.Content)
+		req.Excerpt = svc.TextPolicy.Sanitize(content)
 	}
 
-	req.Excerpt = svc.generateExcerpt(req.Excerpt, 160)
+	excerptLength, err := svc.SettingsSvc.GetInt(ctx, settings.KeyExcerptLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get excerpt length: %w", err)
+	}
 
-	req.Content = svc.HTMLPolicy.Sanitize(req.Content)
+	req.Excerpt = svc.generateExcerpt(req.Excerpt, excerptLength)
 
 	post.Title = req.Title
 	post.Slug = uniqueSlug
 	post.Excerpt = req.Excerpt
-	post.Content = req.Content
+	post.Content = content
+	post.Format = req.Format
+	post.Markdown = markdownContent
 	post.UpdatedAt = time.Now()
 
-	err = svc.PostRepo.Update(ctx, post)
+	if post.Slug != formerSlug {
+		err = svc.PostRepo.Rename(ctx, post, formerSlug)
+	} else {
+		err = svc.PostRepo.Update(ctx, post)
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
 
+	err = svc.MentionRepo.Replace(ctx, post.ID, "", mentioned, post.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mentions: %w", err)
+	}
+
+	svc.recordPostEvent(ctx, audit.ActionPostUpdated, post.ID, &before, post)
+	svc.Events.Publish(ctx, PostUpdated{Post: *post, MentionsSavedAt: post.UpdatedAt})
+
 	return post, nil
 }
 
+// generateUniqueSlugForPost lets a post take back one of its own former slugs before falling back to
+// generateUniqueSlug. PostRepo.Rename drops the history entry of a slug that is taken back.
+func (svc *Service) generateUniqueSlugForPost(ctx context.Context, postID, baseSlug string) (string, error) {
+	history, err := svc.PostSlugHistoryRepo.GetBySlug(ctx, baseSlug)
+	if err != nil && !errors.As(err, &PostSlugHistoryBySlugNotFoundError{}) {
+		return "", fmt.Errorf("error getting post slug history: %w", err)
+	}
+
+	if history != nil && history.PostID == postID {
+		return baseSlug, nil
+	}
+
+	return svc.generateUniqueSlug(ctx, baseSlug)
+}
+
+var ErrInvalidPostFormat = errors.New("invalid post format")
+
+// convertPostContent turns content written in sourceFormat into sanitized HTML and,
+// for Markdown posts, the Markdown source to keep alongside it.
+func (svc *Service) convertPostContent(
+	content string,
+	sourceFormat, targetFormat PostFormat,
+) (string, string, error) {
+	if !sourceFormat.IsValid() || !targetFormat.IsValid() {
+		return "", "", ErrInvalidPostFormat
+	}
+
+	markdownContent := content
+
+	if sourceFormat == PostFormatHTML {
+		if targetFormat == PostFormatHTML {
+			sanitized, err := svc.sanitizePostContent(content)
+			if err != nil {
+				return "", "", err
+			}
+
+			return sanitized, "", nil
+		}
+
+		var err error
+
+		markdownContent, err = HTMLToMarkdown(svc.HTMLPolicy.Sanitize(content))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to convert html to markdown: %w", err)
+		}
+	}
+
+	rendered, err := RenderMarkdown(markdownContent)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render markdown: %w", err)
+	}
+
+	if targetFormat == PostFormatHTML {
+		markdownContent = ""
+	}
+
+	sanitized, err := svc.sanitizePostContent(rendered)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sanitized, markdownContent, nil
+}
+
+// sanitizePostContent sanitizes rendered post HTML and then highlights its code blocks and anchors its headings.
+// Post-processing runs last so its markup never depends on what the author submitted.
+func (svc *Service) sanitizePostContent(content string) (string, error) {
+	highlighted, err := HighlightCodeBlocks(svc.HTMLPolicy.Sanitize(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to highlight code blocks: %w", err)
+	}
+
+	processed, _, err := ProcessHeadings(highlighted)
+	if err != nil {
+		return "", fmt.Errorf("failed to process headings: %w", err)
+	}
+
+	return processed, nil
+}
+
+// PreviewPostContent renders content written in format the same way CreatePost would store it.
+func (svc *Service) PreviewPostContent(ctx context.Context, content string, format PostFormat) (string, error) {
+	rendered, _, err := svc.convertPostContent(content, format, format)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert post content: %w", err)
+	}
+
+	rendered, _, err = LinkMentions(ctx, rendered, svc.UserChecker)
+	if err != nil {
+		return "", fmt.Errorf("failed to link mentions: %w", err)
+	}
+
+	return rendered, nil
+}
+
+// PlainText strips the markup of post or comment content, for excerpts that are shown as text.
+func (svc *Service) PlainText(content string) string {
+	return html.UnescapeString(svc.TextPolicy.Sanitize(content))
+}
+
 func (svc *Service) generateExcerpt(content string, maxLength int) string {
 	if len(content) <= maxLength {
 		return content
@@ -185,7 +481,7 @@ func (svc *Service) generateExcerpt(content string, maxLength int) string {
 var ErrUnableToGenerateUniqueSlug = errors.New("unable to generate unique slug after 1000 attempts")
 
 func (svc *Service) generateUniqueSlug(ctx context.Context, baseSlug string) (string, error) {
-	exists, err := svc.PostBySlugExists(ctx, baseSlug)
+	exists, err := svc.PostSlugReserved(ctx, baseSlug)
 	if err != nil {
 		return "", fmt.Errorf("error checking slug existence: %w", err)
 	}
@@ -211,7 +507,7 @@ func (svc *Service) generateUniqueSlug(ctx context.Context, baseSlug string) (st
 	for {
 		candidateSlug := basePart + "-" + strconv.Itoa(counter)
 
-		exists, err := svc.PostBySlugExists(ctx, candidateSlug)
+		exists, err := svc.PostSlugReserved(ctx, candidateSlug)
 		if err != nil {
 			return "", fmt.Errorf("error checking slug existence: %w", err)
 		}
@@ -229,11 +525,62 @@ func (svc *Service) generateUniqueSlug(ctx context.Context, baseSlug string) (st
 }
 
 func (svc *Service) DeletePost(ctx context.Context, id string) error {
-	err := svc.PostRepo.Delete(ctx, id)
+	post, err := svc.PostRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get post by ID: %w", err)
+	}
+
+	err = svc.PostRepo.Delete(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete post: %w", err)
 	}
 
+	svc.recordPostEvent(ctx, audit.ActionPostDeleted, id, post, nil)
+	svc.Events.Publish(ctx, PostDeleted{Post: *post})
+
+	return nil
+}
+
+func (svc *Service) GetTrashedPostByID(ctx context.Context, id string) (*Post, error) {
+	post, err := svc.PostRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed post by ID: %w", err)
+	}
+
+	return post, nil
+}
+
+func (svc *Service) RestorePost(ctx context.Context, id string) error {
+	post, err := svc.PostRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get trashed post by ID: %w", err)
+	}
+
+	err = svc.PostRepo.Restore(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore post: %w", err)
+	}
+
+	svc.recordPostEvent(ctx, audit.ActionPostRestored, id, nil, post)
+	svc.Events.Publish(ctx, PostRestored{Post: *post})
+
+	return nil
+}
+
+func (svc *Service) PurgePost(ctx context.Context, id string) error {
+	post, err := svc.PostRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get trashed post by ID: %w", err)
+	}
+
+	err = svc.PostRepo.Purge(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge post: %w", err)
+	}
+
+	svc.recordPostEvent(ctx, audit.ActionPostPurged, id, post, nil)
+	svc.Events.Publish(ctx, PostPurged{Post: *post})
+
 	return nil
 }
 
@@ -246,34 +593,117 @@ func (svc *Service) ListComments(ctx context.Context, params ListCommentsParams)
 	return comments, nil
 }
 
+func (svc *Service) CountComments(ctx context.Context, params ListCommentsParams) (int, error) {
+	count, err := svc.CommentRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	return count, nil
+}
+
+var (
+	ErrCommentsClosed       = errors.New("comments are closed")
+	ErrInvalidParentComment = errors.New("invalid parent comment")
+)
+
 type CreateCommentRequest struct {
-	PostID  string
-	UserID  string
-	Content string
+	PostID string
+	// ParentID is the comment to reply to, or empty.
+	ParentID string
+	UserID   string
+	Content  string
 }
 
+// CreateComment follows the comment policy of the site: comments are refused while comments are closed
+// and held as pending while they are moderated. A reply must be to an approved comment on the same post.
 func (svc *Service) CreateComment(ctx context.Context, req *CreateCommentRequest) (*Comment, error) {
+	policy, err := svc.SettingsSvc.GetCommentPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment policy: %w", err)
+	}
+
+	status := CommentStatusApproved
+
+	switch policy {
+	case settings.CommentPolicyClosed:
+		return nil, ErrCommentsClosed
+	case settings.CommentPolicyModerated:
+		status = CommentStatusPending
+	case settings.CommentPolicyOpen:
+	}
+
+	if req.ParentID != "" {
+		parent, err := svc.CommentRepo.GetByID(ctx, req.ParentID)
+		if err != nil {
+			if errors.As(err, &CommentByIDNotFoundError{}) {
+				return nil, ErrInvalidParentComment
+			}
+
+			return nil, fmt.Errorf("failed to get parent comment: %w", err)
+		}
+
+		if parent.PostID != req.PostID || parent.Status != CommentStatusApproved {
+			return nil, ErrInvalidParentComment
+		}
+	}
+
 	timeNow := time.Now()
 
-	req.Content = svc.HTMLPolicy.Sanitize(req.Content)
+	content, mentioned, err := LinkMentions(ctx, svc.HTMLPolicy.Sanitize(req.Content), svc.UserChecker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link mentions: %w", err)
+	}
+
+	req.Content = content
 
 	comment := &Comment{
 		ID:        uuid.NewString(),
 		PostID:    req.PostID,
+		ParentID:  req.ParentID,
 		UserID:    req.UserID,
 		Content:   req.Content,
+		Status:    status,
 		CreatedAt: timeNow,
 		UpdatedAt: timeNow,
 	}
 
-	err := svc.CommentRepo.Create(ctx, comment)
+	err = svc.CommentRepo.Create(ctx, comment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
 
+	err = svc.MentionRepo.Replace(ctx, comment.PostID, comment.ID, mentioned, comment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mentions: %w", err)
+	}
+
+	svc.recordCommentEvent(ctx, audit.ActionCommentCreated, comment.ID, nil, comment)
+	svc.Events.Publish(ctx, CommentCreated{Comment: *comment})
+
 	return comment, nil
 }
 
+func (svc *Service) ApproveComment(ctx context.Context, id, moderatorID string) error {
+	comment, err := svc.CommentRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get comment by ID: %w", err)
+	}
+
+	err = svc.CommentRepo.Approve(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to approve comment: %w", err)
+	}
+
+	after := *comment
+	after.Status = CommentStatusApproved
+
+	svc.recordCommentEvent(ctx, audit.ActionCommentApproved, id, comment, &after)
+	svc.Events.Publish(ctx, CommentApproved{Comment: after, ModeratorID: moderatorID})
+
+	return nil
+}
+
 func (svc *Service) GetCommentByID(ctx context.Context, id string) (*Comment, error) {
 	comment, err := svc.CommentRepo.GetByID(ctx, id)
 	if err != nil {
@@ -283,15 +713,108 @@ func (svc *Service) GetCommentByID(ctx context.Context, id string) (*Comment, er
 	return comment, nil
 }
 
-func (svc *Service) DeleteComment(ctx context.Context, id string) error {
-	err := svc.CommentRepo.Delete(ctx, id)
+func (svc *Service) DeleteComment(ctx context.Context, id, moderatorID string) error {
+	comment, err := svc.CommentRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get comment by ID: %w", err)
+	}
+
+	err = svc.CommentRepo.Delete(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete comment: %w", err)
 	}
 
+	svc.recordCommentEvent(ctx, audit.ActionCommentDeleted, id, comment, nil)
+	svc.Events.Publish(ctx, CommentDeleted{Comment: *comment, ModeratorID: moderatorID})
+
 	return nil
 }
 
+func (svc *Service) GetTrashedCommentByID(ctx context.Context, id string) (*Comment, error) {
+	comment, err := svc.CommentRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed comment by ID: %w", err)
+	}
+
+	return comment, nil
+}
+
+func (svc *Service) RestoreComment(ctx context.Context, id string) error {
+	comment, err := svc.CommentRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get trashed comment by ID: %w", err)
+	}
+
+	err = svc.CommentRepo.Restore(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore comment: %w", err)
+	}
+
+	svc.recordCommentEvent(ctx, audit.ActionCommentRestored, id, nil, comment)
+
+	return nil
+}
+
+func (svc *Service) PurgeComment(ctx context.Context, id string) error {
+	comment, err := svc.CommentRepo.GetTrashedByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get trashed comment by ID: %w", err)
+	}
+
+	err = svc.CommentRepo.Purge(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge comment: %w", err)
+	}
+
+	svc.recordCommentEvent(ctx, audit.ActionCommentPurged, id, comment, nil)
+
+	return nil
+}
+
+// PurgeTrash permanently deletes posts and comments that have been in trash longer than TrashRetention.
+func (svc *Service) PurgeTrash(ctx context.Context) error {
+	if svc.TrashRetention <= 0 {
+		return nil
+	}
+
+	deletedBefore := time.Now().Add(-svc.TrashRetention)
+
+	purgedComments, err := svc.CommentRepo.PurgeTrashed(ctx, deletedBefore)
+	if err != nil {
+		return fmt.Errorf("failed to purge trashed comments: %w", err)
+	}
+
+	purgedPosts, err := svc.PostRepo.PurgeTrashed(ctx, deletedBefore)
+	if err != nil {
+		return fmt.Errorf("failed to purge trashed posts: %w", err)
+	}
+
+	if purgedPosts > 0 || purgedComments > 0 {
+		slog.InfoContext(ctx, "trash purged", "posts", purgedPosts, "comments", purgedComments)
+	}
+
+	return nil
+}
+
+// RunTrashPurger calls PurgeTrash on every tick until ctx is done.
+func (svc *Service) RunTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := svc.PurgeTrash(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge trash", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 type UpdateCommentRequest struct {
 	Content string
 }
@@ -302,8 +825,14 @@ func (svc *Service) UpdateComment(ctx context.Context, id string, req *UpdateCom
 		return fmt.Errorf("failed to get comment by ID: %w", err)
 	}
 
-	req.Content = svc.HTMLPolicy.Sanitize(req.Content)
+	content, mentioned, err := LinkMentions(ctx, svc.HTMLPolicy.Sanitize(req.Content), svc.UserChecker)
+	if err != nil {
+		return fmt.Errorf("failed to link mentions: %w", err)
+	}
+
+	req.Content = content
 
+	before := *comment
 	comment.Content = req.Content
 
 	err = svc.CommentRepo.Update(ctx, comment)
@@ -311,5 +840,13 @@ func (svc *Service) UpdateComment(ctx context.Context, id string, req *UpdateCom
 		return fmt.Errorf("failed to update comment: %w", err)
 	}
 
+	err = svc.MentionRepo.Replace(ctx, comment.PostID, comment.ID, mentioned, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save mentions: %w", err)
+	}
+
+	svc.recordCommentEvent(ctx, audit.ActionCommentUpdated, comment.ID, &before, comment)
+	svc.Events.Publish(ctx, CommentUpdated{Comment: *comment})
+
 	return nil
 }
@@ -0,0 +1,446 @@
+package blog_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+// adminID is the user created by the migrations.
+const adminID = "9ebccc6b-a40b-4cdd-b0db-5781e14a47bb"
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(context.Context, audit.Event) {}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(context.Context, eventbus.Event) {}
+
+func newService(t *testing.T) *blog.Service {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return &blog.Service{
+		PostRepo:            &sqlite3.PostRepo{DB: db},
+		PostSlugHistoryRepo: &sqlite3.PostSlugHistoryRepo{DB: db},
+		CommentRepo:         &sqlite3.CommentRepo{DB: db},
+		MentionRepo:         &sqlite3.MentionRepo{DB: db},
+		UserChecker:         users{},
+		HTMLPolicy:          blog.NewHTMLPolicy(),
+		TextPolicy:          bluemonday.StrictPolicy(),
+		SettingsSvc:         &settings.Service{SettingRepo: &sqlite3.SettingRepo{DB: db}},
+		Auditor:             nopRecorder{},
+		Events:              nopPublisher{},
+	}
+}
+
+func createPost(t *testing.T, svc *blog.Service, title string) *blog.Post {
+	t.Helper()
+
+	post, err := svc.CreatePost(t.Context(), &blog.CreatePostRequest{
+		Title:    title,
+		Content:  "<p>Content</p>",
+		Format:   blog.PostFormatHTML,
+		AuthorID: adminID,
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	return post
+}
+
+func setCommentPolicy(t *testing.T, svc *blog.Service, policy settings.CommentPolicy) {
+	t.Helper()
+
+	err := svc.SettingsSvc.UpdateSettings(t.Context(), &settings.UpdateSettingsRequest{
+		SiteTitle:      "Blog",
+		Language:       "en",
+		PostsPerPage:   10,
+		ExcerptLength:  160,
+		CommentPolicy:  policy,
+		AvatarFallback: settings.AvatarFallbackInitials,
+	})
+	if err != nil {
+		t.Fatalf("failed to update settings: %v", err)
+	}
+}
+
+func commentIDs(t *testing.T, svc *blog.Service, params blog.ListCommentsParams) []string {
+	t.Helper()
+
+	comments, err := svc.ListComments(t.Context(), params)
+	if err != nil {
+		t.Fatalf("failed to list comments: %v", err)
+	}
+
+	count, err := svc.CountComments(t.Context(), params)
+	if err != nil {
+		t.Fatalf("failed to count comments: %v", err)
+	}
+
+	ids := make([]string, 0, len(comments))
+
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+
+	if count != len(ids) {
+		t.Errorf("expected the count %d to match the %d listed comments", count, len(ids))
+	}
+
+	return ids
+}
+
+func TestCommentModeration(t *testing.T) {
+	svc := newService(t)
+	post := createPost(t, svc, "Moderated")
+
+	open, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Before moderation</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	if open.Status != blog.CommentStatusApproved {
+		t.Errorf("expected comments to be approved while comments are open, got %q", open.Status)
+	}
+
+	setCommentPolicy(t, svc, settings.CommentPolicyModerated)
+
+	held, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Held</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	if held.Status != blog.CommentStatusPending {
+		t.Errorf("expected comments to be pending while comments are moderated, got %q", held.Status)
+	}
+
+	// Only approved comments are listed unless pending ones are asked for.
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID}); len(ids) != 1 || ids[0] != open.ID {
+		t.Errorf("expected only the approved comment, got %v", ids)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID, IncludePending: true}); len(ids) != 2 {
+		t.Errorf("expected both comments, got %v", ids)
+	}
+
+	pending := blog.ListCommentsParams{Status: blog.CommentStatusPending}
+	if ids := commentIDs(t, svc, pending); len(ids) != 1 || ids[0] != held.ID {
+		t.Errorf("expected only the pending comment, got %v", ids)
+	}
+
+	err = svc.ApproveComment(t.Context(), held.ID, adminID)
+	if err != nil {
+		t.Fatalf("failed to approve comment: %v", err)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID}); len(ids) != 2 {
+		t.Errorf("expected the approved comment to be listed, got %v", ids)
+	}
+
+	if ids := commentIDs(t, svc, pending); len(ids) != 0 {
+		t.Errorf("expected no pending comments, got %v", ids)
+	}
+
+	setCommentPolicy(t, svc, settings.CommentPolicyClosed)
+
+	_, err = svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Too late</p>",
+	})
+	if !errors.Is(err, blog.ErrCommentsClosed) {
+		t.Errorf("expected comments to be closed, got %v", err)
+	}
+}
+
+func TestCommentReplies(t *testing.T) {
+	svc := newService(t)
+	post := createPost(t, svc, "Replies")
+	other := createPost(t, svc, "Other")
+
+	parent, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Parent</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	reply, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:   post.ID,
+		ParentID: parent.ID,
+		UserID:   adminID,
+		Content:  "<p>Reply</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create reply: %v", err)
+	}
+
+	got, err := svc.GetCommentByID(t.Context(), reply.ID)
+	if err != nil {
+		t.Fatalf("failed to get reply: %v", err)
+	}
+
+	if got.ParentID != parent.ID {
+		t.Errorf("expected the reply to be to %s, got %q", parent.ID, got.ParentID)
+	}
+
+	setCommentPolicy(t, svc, settings.CommentPolicyModerated)
+
+	held, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Held</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	for name, req := range map[string]*blog.CreateCommentRequest{
+		"unknown parent":         {PostID: post.ID, ParentID: "unknown"},
+		"parent on another post": {PostID: other.ID, ParentID: parent.ID},
+		"pending parent":         {PostID: post.ID, ParentID: held.ID},
+	} {
+		req.UserID = adminID
+		req.Content = "<p>Reply</p>"
+
+		_, err = svc.CreateComment(t.Context(), req)
+		if !errors.Is(err, blog.ErrInvalidParentComment) {
+			t.Errorf("%s: expected ErrInvalidParentComment, got %v", name, err)
+		}
+	}
+}
+
+func TestTrashPost(t *testing.T) {
+	svc := newService(t)
+	post := createPost(t, svc, "Trashed")
+
+	comment, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Comment</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	err = svc.DeletePost(t.Context(), post.ID)
+	if err != nil {
+		t.Fatalf("failed to delete post: %v", err)
+	}
+
+	_, err = svc.GetPostBySlug(t.Context(), post.Slug)
+	if !errors.As(err, &blog.PostBySlugNotFoundError{}) {
+		t.Errorf("expected a trashed post not to be found, got %v", err)
+	}
+
+	trashed, err := svc.GetTrashedPostByID(t.Context(), post.ID)
+	if err != nil || trashed.DeletedAt == nil {
+		t.Fatalf("expected the post in trash, got %+v %v", trashed, err)
+	}
+
+	// The slug stays reserved while the post is in trash, so restoring it cannot clash.
+	other := createPost(t, svc, "Trashed")
+	if other.Slug == post.Slug {
+		t.Errorf("expected the slug of a trashed post to stay reserved")
+	}
+
+	reserved, err := svc.PostSlugReserved(t.Context(), post.Slug)
+	if err != nil || !reserved {
+		t.Errorf("expected the slug to be reserved, got %v %v", reserved, err)
+	}
+
+	err = svc.RestorePost(t.Context(), post.ID)
+	if err != nil {
+		t.Fatalf("failed to restore post: %v", err)
+	}
+
+	restored, err := svc.GetPostBySlug(t.Context(), post.Slug)
+	if err != nil || restored.ID != post.ID || restored.DeletedAt != nil {
+		t.Fatalf("expected the restored post, got %+v %v", restored, err)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID}); len(ids) != 1 || ids[0] != comment.ID {
+		t.Errorf("expected the comments to come back with the post, got %v", ids)
+	}
+}
+
+func TestTrashComment(t *testing.T) {
+	svc := newService(t)
+	post := createPost(t, svc, "Commented")
+
+	comment, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Comment</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	err = svc.DeleteComment(t.Context(), comment.ID, adminID)
+	if err != nil {
+		t.Fatalf("failed to delete comment: %v", err)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID}); len(ids) != 0 {
+		t.Errorf("expected no comments, got %v", ids)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{UserID: adminID, Trashed: true}); len(ids) != 1 {
+		t.Errorf("expected the comment in trash, got %v", ids)
+	}
+
+	err = svc.RestoreComment(t.Context(), comment.ID)
+	if err != nil {
+		t.Fatalf("failed to restore comment: %v", err)
+	}
+
+	if ids := commentIDs(t, svc, blog.ListCommentsParams{PostID: post.ID}); len(ids) != 1 {
+		t.Errorf("expected the restored comment, got %v", ids)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	svc := newService(t)
+	svc.TrashRetention = time.Hour
+
+	post := createPost(t, svc, "Purged")
+	kept := createPost(t, svc, "Kept")
+
+	comment, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  kept.ID,
+		UserID:  adminID,
+		Content: "<p>Comment</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	for _, err := range []error{svc.DeletePost(t.Context(), post.ID), svc.DeleteComment(t.Context(), comment.ID, adminID)} {
+		if err != nil {
+			t.Fatalf("failed to move to trash: %v", err)
+		}
+	}
+
+	// Nothing has been in trash for the retention period yet.
+	err = svc.PurgeTrash(t.Context())
+	if err != nil {
+		t.Fatalf("failed to purge trash: %v", err)
+	}
+
+	_, err = svc.GetTrashedPostByID(t.Context(), post.ID)
+	if err != nil {
+		t.Fatalf("expected the post to be kept in trash: %v", err)
+	}
+
+	svc.TrashRetention = time.Nanosecond
+
+	err = svc.PurgeTrash(t.Context())
+	if err != nil {
+		t.Fatalf("failed to purge trash: %v", err)
+	}
+
+	_, err = svc.GetTrashedPostByID(t.Context(), post.ID)
+	if !errors.As(err, &blog.PostByIDNotFoundError{}) {
+		t.Errorf("expected the post to be purged, got %v", err)
+	}
+
+	_, err = svc.GetTrashedCommentByID(t.Context(), comment.ID)
+	if !errors.As(err, &blog.CommentByIDNotFoundError{}) {
+		t.Errorf("expected the comment to be purged, got %v", err)
+	}
+
+	// A purged slug can be used again.
+	reserved, err := svc.PostSlugReserved(t.Context(), post.Slug)
+	if err != nil || reserved {
+		t.Errorf("expected the slug to be free, got %v %v", reserved, err)
+	}
+
+	if again := createPost(t, svc, "Purged"); again.Slug != post.Slug {
+		t.Errorf("expected the purged slug %q to be reused, got %q", post.Slug, again.Slug)
+	}
+}
+
+type eventRecorder struct {
+	names []string
+}
+
+func (rec *eventRecorder) Publish(_ context.Context, event eventbus.Event) {
+	rec.names = append(rec.names, event.EventName())
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	svc := newService(t)
+	post := createPost(t, svc, "Events")
+
+	comment, err := svc.CreateComment(t.Context(), &blog.CreateCommentRequest{
+		PostID:  post.ID,
+		UserID:  adminID,
+		Content: "<p>Hello</p>",
+	})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	rec := &eventRecorder{}
+	svc.Events = rec
+
+	steps := []func() error{
+		func() error {
+			return svc.UpdateComment(t.Context(), comment.ID, &blog.UpdateCommentRequest{Content: "<p>Edited</p>"})
+		},
+		func() error { return svc.DeletePost(t.Context(), post.ID) },
+		func() error { return svc.RestorePost(t.Context(), post.ID) },
+		func() error { return svc.DeletePost(t.Context(), post.ID) },
+		func() error { return svc.PurgePost(t.Context(), post.ID) },
+	}
+
+	for i, step := range steps {
+		err = step()
+		if err != nil {
+			t.Fatalf("failed step %d: %v", i, err)
+		}
+	}
+
+	want := []string{"comment.updated", "post.deleted", "post.restored", "post.deleted", "post.purged"}
+	if !slices.Equal(rec.names, want) {
+		t.Errorf("expected %v, got %v", want, rec.names)
+	}
+}
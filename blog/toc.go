@@ -0,0 +1,195 @@
+package blog
+
+import (
+	"strconv"
+	"strings"
+
+	slugify "github.com/gosimple/slug"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MinTOCHeadings is the number of headings a post must exceed before its table of contents is shown.
+const MinTOCHeadings = 3
+
+const headingAnchorClass = "heading-anchor"
+
+// reservedHeadingIDs are element ids used around the post content on the single post page.
+// Headings never take them, so that in-page targets keep pointing at the page elements.
+var reservedHeadingIDs = []string{"comments", "comments-list", "comment-form", "notifications", "post-preview"}
+
+type TOCEntry struct {
+	ID       string
+	Title    string
+	Level    int
+	Children TOC
+}
+
+type TOC []*TOCEntry
+
+// Len returns the number of entries in toc, including nested ones.
+func (toc TOC) Len() int {
+	n := len(toc)
+
+	for _, entry := range toc {
+		n += entry.Children.Len()
+	}
+
+	return n
+}
+
+// ProcessHeadings assigns an id slugified from its text to every heading in content, appends a permalink
+// anchor to it and returns the processed content along with its table of contents.
+// Anchors added by a previous run are replaced, so processing the output again yields the same result.
+func ProcessHeadings(content string) (string, TOC, error) {
+	body, err := parseHTMLFragment(content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, id := range reservedHeadingIDs {
+		used[id] = true
+	}
+
+	var headings []*html.Node
+
+	for node := range body.Descendants() {
+		if headingLevel(node) > 0 {
+			headings = append(headings, node)
+		} else if id := attr(node, "id"); id != "" {
+			used[id] = true
+		}
+	}
+
+	var (
+		toc   TOC
+		stack []*TOCEntry
+	)
+
+	for _, heading := range headings {
+		removeHeadingAnchors(heading)
+
+		title := strings.Join(strings.Fields(textContent(heading)), " ")
+		if title == "" {
+			continue
+		}
+
+		id := uniqueHeadingID(title, used)
+
+		setAttr(heading, "id", id)
+		heading.AppendChild(&html.Node{Type: html.TextNode, Data: " "})
+		heading.AppendChild(newHeadingAnchor(id))
+
+		entry := &TOCEntry{ID: id, Title: title, Level: headingLevel(heading)}
+
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			toc = append(toc, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+
+		stack = append(stack, entry)
+	}
+
+	processed, err := renderHTMLFragment(body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return processed, toc, nil
+}
+
+func headingLevel(node *html.Node) int {
+	switch node.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(node.Data[1:])
+
+		return level
+	default:
+		return 0
+	}
+}
+
+func uniqueHeadingID(title string, used map[string]bool) string {
+	base := slugify.Make(title)
+	if base == "" {
+		base = "section"
+	}
+
+	id := base
+
+	for i := 2; used[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+
+	used[id] = true
+
+	return id
+}
+
+func newHeadingAnchor(id string) *html.Node {
+	anchor := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "a",
+		DataAtom: atom.A,
+		Attr: []html.Attribute{
+			{Key: "href", Val: "#" + id},
+			{Key: "class", Val: headingAnchorClass},
+			{Key: "aria-hidden", Val: "true"},
+		},
+	}
+	anchor.AppendChild(&html.Node{Type: html.TextNode, Data: "#"})
+
+	return anchor
+}
+
+// isHeadingAnchor reports whether node is a permalink anchor added by ProcessHeadings.
+// Editors may drop the class, so a bare "#" link to a fragment counts as well.
+func isHeadingAnchor(node *html.Node) bool {
+	if node.DataAtom != atom.A {
+		return false
+	}
+
+	if attr(node, "class") == headingAnchorClass {
+		return true
+	}
+
+	return strings.HasPrefix(attr(node, "href"), "#") && strings.TrimSpace(textContent(node)) == "#"
+}
+
+func removeHeadingAnchors(heading *html.Node) {
+	for child := heading.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if isHeadingAnchor(child) {
+			heading.RemoveChild(child)
+		}
+
+		child = next
+	}
+
+	if last := heading.LastChild; last != nil && last.Type == html.TextNode {
+		last.Data = strings.TrimRight(last.Data, " \t\r\n")
+		if last.Data == "" {
+			heading.RemoveChild(last)
+		}
+	}
+}
+
+func setAttr(node *html.Node, key, val string) {
+	for i := range node.Attr {
+		if node.Attr[i].Key == key {
+			node.Attr[i].Val = val
+
+			return
+		}
+	}
+
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: val})
+}
@@ -0,0 +1,261 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+const archiveReadme = `This archive contains the personal data we store about you.
+
+profile.json   your account
+posts.json     your posts, including the ones in trash
+posts/         the content of each post as HTML
+comments.json  your comments, including the ones in trash
+logins.json    your sign-ins
+avatar.png     your uploaded avatar, if you have one
+
+Sessions are kept in a signed cookie in your browser rather than on our servers.
+Each sign-in in logins.json started one.
+`
+
+type profileRecord struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	EmailAddress string     `json:"emailAddress"`
+	Name         string     `json:"name"`
+	AvatarURL    string     `json:"avatarUrl"`
+	Bio          string     `json:"bio"`
+	Links        []string   `json:"links"`
+	IsAdmin      bool       `json:"isAdmin"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	DisabledAt   *time.Time `json:"disabledAt,omitempty"`
+	BannedUntil  *time.Time `json:"bannedUntil,omitempty"`
+}
+
+type postRecord struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Slug      string     `json:"slug"`
+	Excerpt   string     `json:"excerpt"`
+	Format    string     `json:"format"`
+	Content   string     `json:"content"`
+	Markdown  string     `json:"markdown,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type commentRecord struct {
+	ID        string     `json:"id"`
+	PostID    string     `json:"postId"`
+	ParentID  string     `json:"parentId,omitempty"`
+	Content   string     `json:"content"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type loginRecord struct {
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (svc *Service) buildArchive(ctx context.Context, user *auth.User) ([]byte, error) {
+	posts, err := svc.listPosts(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := svc.listComments(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logins, err := svc.AuthSvc.ListLogins(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logins: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	err = writeArchiveFile(zw, "README.txt", []byte(archiveReadme))
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeArchiveJSON(zw, "profile.json", profileRecord{
+		ID:           user.ID,
+		Username:     user.Username,
+		EmailAddress: user.EmailAddress,
+		Name:         user.Name,
+		AvatarURL:    user.AvatarURL,
+		Bio:          user.Bio,
+		Links:        user.Links,
+		IsAdmin:      user.IsAdmin,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		DisabledAt:   user.DisabledAt,
+		BannedUntil:  user.BannedUntil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	postRecords := make([]postRecord, 0, len(posts))
+
+	for _, post := range posts {
+		postRecords = append(postRecords, postRecord{
+			ID:        post.ID,
+			Title:     post.Title,
+			Slug:      post.Slug,
+			Excerpt:   post.Excerpt,
+			Format:    string(post.Format),
+			Content:   post.Content,
+			Markdown:  post.Markdown,
+			CreatedAt: post.CreatedAt,
+			UpdatedAt: post.UpdatedAt,
+			DeletedAt: post.DeletedAt,
+		})
+
+		err = writeArchiveFile(zw, "posts/"+post.Slug+".html", []byte(post.Content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = writeArchiveJSON(zw, "posts.json", postRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	commentRecords := make([]commentRecord, 0, len(comments))
+
+	for _, comment := range comments {
+		commentRecords = append(commentRecords, commentRecord{
+			ID:        comment.ID,
+			PostID:    comment.PostID,
+			ParentID:  comment.ParentID,
+			Content:   comment.Content,
+			Status:    string(comment.Status),
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+			DeletedAt: comment.DeletedAt,
+		})
+	}
+
+	err = writeArchiveJSON(zw, "comments.json", commentRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	loginRecords := make([]loginRecord, 0, len(logins))
+
+	for _, login := range logins {
+		loginRecords = append(loginRecords, loginRecord{
+			IPAddress: login.IPAddress,
+			UserAgent: login.UserAgent,
+			CreatedAt: login.CreatedAt,
+		})
+	}
+
+	err = writeArchiveJSON(zw, "logins.json", loginRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar, err := svc.AuthSvc.GetAvatar(ctx, user.ID, auth.AvatarSizeLarge)
+	if err != nil && !errors.As(err, &auth.AvatarNotFoundError{}) {
+		return nil, fmt.Errorf("failed to get avatar: %w", err)
+	}
+
+	if avatar != nil {
+		err = writeArchiveFile(zw, "avatar.png", avatar.Image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close zip writer: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// listPosts returns the published and trashed posts of a user.
+func (svc *Service) listPosts(ctx context.Context, userID string) ([]*blog.Post, error) {
+	posts, err := svc.BlogSvc.ListPosts(ctx, blog.ListPostsParams{AuthorID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts: %w", err)
+	}
+
+	trashed, err := svc.BlogSvc.ListPosts(ctx, blog.ListPostsParams{AuthorID: userID, Trashed: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed posts: %w", err)
+	}
+
+	return append(posts, trashed...), nil
+}
+
+// listComments returns the published, pending and trashed comments of a user.
+func (svc *Service) listComments(ctx context.Context, userID string) ([]*blog.Comment, error) {
+	comments, err := svc.BlogSvc.ListComments(ctx, blog.ListCommentsParams{UserID: userID, IncludePending: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	trashed, err := svc.BlogSvc.ListComments(ctx, blog.ListCommentsParams{
+		UserID:         userID,
+		IncludePending: true,
+		Trashed:        true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed comments: %w", err)
+	}
+
+	return append(comments, trashed...), nil
+}
+
+func writeArchiveFile(zw *zip.Writer, name string, content []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in archive: %w", name, err)
+	}
+
+	_, err = w.Write(content)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeArchiveJSON(zw *zip.Writer, name string, v any) error {
+	var content bytes.Buffer
+
+	enc := json.NewEncoder(&content)
+	enc.SetIndent("", "  ")
+	// Post content is HTML, which should stay readable.
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	return writeArchiveFile(zw, name, content.Bytes())
+}
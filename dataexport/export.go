@@ -0,0 +1,47 @@
+package dataexport
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusReady   Status = "ready"
+	StatusFailed  Status = "failed"
+)
+
+// Export is an archive of the personal data of a user.
+type Export struct {
+	ID           string
+	UserID       string
+	Status       Status
+	Archive      []byte
+	CreatedAt    time.Time
+	ExpiresAt    *time.Time
+	DownloadedAt *time.Time
+}
+
+type ExportRepository interface {
+	Create(ctx context.Context, export *Export) (err error)
+	Get(ctx context.Context, id string) (export *Export, err error)
+	// HasRecent tells whether the user has a pending export or one that did not fail created after createdAfter.
+	HasRecent(ctx context.Context, userID string, createdAfter time.Time) (recent bool, err error)
+	Update(ctx context.Context, export *Export) (err error)
+	// Claim returns the archive of a ready export that expires after now and marks it as downloaded, so every
+	// archive is handed out at most once.
+	Claim(ctx context.Context, id string, now time.Time) (archive []byte, err error)
+	// PurgeExpired deletes exports created before the given time and the archives of exports that have expired.
+	PurgeExpired(ctx context.Context, now, createdBefore time.Time) (count int, err error)
+}
+
+type ExportByIDNotFoundError struct {
+	ID string
+}
+
+func (err ExportByIDNotFoundError) Error() string {
+	return fmt.Sprintf("data export with ID %q not found", err.ID)
+}
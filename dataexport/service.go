@@ -0,0 +1,263 @@
+package dataexport
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+// BuildJobType is the job that builds a data export. Its payload is a BuildJobPayload.
+const BuildJobType = "dataexport.build"
+
+// DefaultRequestInterval is how long a user waits between data exports.
+const DefaultRequestInterval = 24 * time.Hour
+
+// Service builds personal data archives in the background and mails a signed link to download them.
+type Service struct {
+	ExportRepo ExportRepository
+	AuthSvc    *auth.Service
+	BlogSvc    *blog.Service
+	Mailer     mailer.Mailer
+	// JobQueue builds the archives, so only as many are built at once as it has workers.
+	JobQueue *jobqueue.Queue
+	// SigningKey signs download links.
+	SigningKey []byte
+	// LinkTTL is how long a download link stays valid after the archive is ready.
+	LinkTTL time.Duration
+	// RequestInterval is how long a user waits after requesting an export before requesting another one. Exports
+	// that failed do not count.
+	RequestInterval time.Duration
+}
+
+var (
+	ErrInvalidSignature  = errors.New("invalid download link signature")
+	ErrLinkExpired       = errors.New("download link has expired")
+	ErrRequestedRecently = errors.New("a data export was requested recently")
+)
+
+type BuildJobPayload struct {
+	ExportID string `json:"exportId"`
+	BaseURL  string `json:"baseUrl"`
+}
+
+// RequestExport records an export for the user and queues a job that builds it. The download link is mailed to the
+// user and points at baseURL. It returns ErrRequestedRecently while another export of the user is pending or was
+// requested less than RequestInterval ago.
+func (svc *Service) RequestExport(ctx context.Context, user *auth.User, baseURL string) error {
+	requestInterval := svc.RequestInterval
+	if requestInterval <= 0 {
+		requestInterval = DefaultRequestInterval
+	}
+
+	recent, err := svc.ExportRepo.HasRecent(ctx, user.ID, time.Now().Add(-requestInterval))
+	if err != nil {
+		return fmt.Errorf("failed to check recent data exports: %w", err)
+	}
+
+	if recent {
+		return ErrRequestedRecently
+	}
+
+	export := &Export{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	err = svc.ExportRepo.Create(ctx, export)
+	if err != nil {
+		return fmt.Errorf("failed to create data export: %w", err)
+	}
+
+	_, err = svc.JobQueue.Enqueue(ctx, BuildJobType, BuildJobPayload{ExportID: export.ID, BaseURL: baseURL})
+	if err != nil {
+		svc.fail(ctx, export)
+
+		return fmt.Errorf("failed to enqueue data export: %w", err)
+	}
+
+	return nil
+}
+
+// BuildJobHandler builds the export of a job. Exports that fail to build are marked as failed rather than retried,
+// so the user can request a new one.
+func BuildJobHandler(svc *Service) jobqueue.Handler {
+	return func(ctx context.Context, job *jobqueue.Job) error {
+		var payload BuildJobPayload
+
+		err := job.DecodePayload(&payload)
+		if err != nil {
+			return err
+		}
+
+		export, err := svc.ExportRepo.Get(ctx, payload.ExportID)
+		if err != nil {
+			if errors.As(err, &ExportByIDNotFoundError{}) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to get data export: %w", err)
+		}
+
+		if export.Status != StatusPending {
+			return nil
+		}
+
+		user, err := svc.AuthSvc.GetUserByID(ctx, export.UserID)
+		if err != nil {
+			if errors.As(err, &auth.UserByIDNotFoundError{}) {
+				svc.fail(ctx, export)
+
+				return nil
+			}
+
+			return fmt.Errorf("failed to get user by id: %w", err)
+		}
+
+		svc.build(ctx, export, user, payload.BaseURL)
+
+		return nil
+	}
+}
+
+func (svc *Service) build(ctx context.Context, export *Export, user *auth.User, baseURL string) {
+	archive, err := svc.buildArchive(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to build data export", "error", err, "exportId", export.ID)
+		svc.fail(ctx, export)
+
+		return
+	}
+
+	expiresAt := time.Now().Add(svc.LinkTTL)
+
+	export.Status = StatusReady
+	export.Archive = archive
+	export.ExpiresAt = &expiresAt
+
+	err = svc.ExportRepo.Update(ctx, export)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to save data export", "error", err, "exportId", export.ID)
+		svc.fail(ctx, export)
+
+		return
+	}
+
+	msg, err := mailer.Render("data-export-ready", map[string]any{
+		"ExpiresAt":    expiresAt.UTC().Format("Jan _2, 2006 15:04 MST"),
+		"DownloadLink": baseURL + svc.DownloadPath(export.ID, expiresAt),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to render data export email", "error", err, "exportId", export.ID)
+
+		return
+	}
+
+	msg.To = []string{user.EmailAddress}
+
+	err = svc.Mailer.SendEmail(ctx, msg)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send data export email", "error", err, "exportId", export.ID)
+	}
+}
+
+func (svc *Service) fail(ctx context.Context, export *Export) {
+	export.Status = StatusFailed
+	export.Archive = nil
+
+	err := svc.ExportRepo.Update(ctx, export)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to mark data export as failed", "error", err, "exportId", export.ID)
+	}
+}
+
+// DownloadPath returns the signed path an export can be downloaded from until expiresAt.
+func (svc *Service) DownloadPath(id string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", svc.sign(id, expires))
+
+	return "/data-exports/" + url.PathEscape(id) + "?" + query.Encode()
+}
+
+func (svc *Service) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, svc.SigningKey)
+	mac.Write([]byte(id + "\n" + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Download checks the signature of a download link and hands out the archive once.
+func (svc *Service) Download(ctx context.Context, id, expires, signature string) ([]byte, error) {
+	if !hmac.Equal([]byte(signature), []byte(svc.sign(id, expires))) {
+		return nil, ErrInvalidSignature
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	now := time.Now()
+
+	if !now.Before(time.Unix(expiresUnix, 0)) {
+		return nil, ErrLinkExpired
+	}
+
+	archive, err := svc.ExportRepo.Claim(ctx, id, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim data export: %w", err)
+	}
+
+	return archive, nil
+}
+
+// PurgeExpired drops the archives of expired exports and deletes exports older than a week.
+func (svc *Service) PurgeExpired(ctx context.Context) error {
+	now := time.Now()
+
+	count, err := svc.ExportRepo.PurgeExpired(ctx, now, now.Add(-7*24*time.Hour))
+	if err != nil {
+		return fmt.Errorf("failed to purge expired data exports: %w", err)
+	}
+
+	if count > 0 {
+		slog.InfoContext(ctx, "expired data exports purged", "count", count)
+	}
+
+	return nil
+}
+
+func (svc *Service) RunPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := svc.PurgeExpired(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge expired data exports", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
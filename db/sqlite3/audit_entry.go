@@ -0,0 +1,187 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+)
+
+type AuditEntryRepo struct {
+	DB *sql.DB
+}
+
+func scanAuditEntry(rs squirrel.RowScanner) (*audit.Entry, error) {
+	var (
+		entry   audit.Entry
+		changes string
+	)
+
+	err := rs.Scan(
+		&entry.ID,
+		&entry.Action,
+		&entry.ActorID,
+		&entry.ActorUsername,
+		&entry.TargetType,
+		&entry.TargetID,
+		&entry.IPAddress,
+		&entry.UserAgent,
+		&changes,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	err = json.Unmarshal([]byte(changes), &entry.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("error on unmarshal changes: %w", err)
+	}
+
+	return &entry, nil
+}
+
+func (repo *AuditEntryRepo) Append(ctx context.Context, entry *audit.Entry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("error on marshal changes: %w", err)
+	}
+
+	q := squirrel.Insert("audit_entries").
+		Columns(
+			"id",
+			"action",
+			"actor_id",
+			"actor_username",
+			"target_type",
+			"target_id",
+			"ip_address",
+			"user_agent",
+			"changes",
+			"created_at",
+		).
+		Values(
+			entry.ID,
+			entry.Action,
+			entry.ActorID,
+			entry.ActorUsername,
+			entry.TargetType,
+			entry.TargetID,
+			entry.IPAddress,
+			entry.UserAgent,
+			string(changes),
+			entry.CreatedAt,
+		).
+		RunWith(repo.DB)
+
+	_, err = q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on append audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func filterAuditEntries(q squirrel.SelectBuilder, params audit.ListEntriesParams) squirrel.SelectBuilder {
+	if params.Action != "" {
+		q = q.Where(squirrel.Eq{"action": params.Action})
+	}
+
+	if params.TargetType != "" {
+		q = q.Where(squirrel.Eq{"target_type": params.TargetType})
+	}
+
+	if params.Search != "" {
+		pattern := "%" + params.Search + "%"
+		q = q.Where(squirrel.Or{
+			squirrel.Like{"actor_username": pattern},
+			squirrel.Like{"target_id": pattern},
+			squirrel.Like{"ip_address": pattern},
+		})
+	}
+
+	if !params.From.IsZero() {
+		q = q.Where(squirrel.GtOrEq{"created_at": params.From})
+	}
+
+	if !params.To.IsZero() {
+		q = q.Where(squirrel.Lt{"created_at": params.To})
+	}
+
+	return q
+}
+
+func (repo *AuditEntryRepo) List(ctx context.Context, params audit.ListEntriesParams) ([]*audit.Entry, error) {
+	q := squirrel.Select(
+		"id",
+		"action",
+		"actor_id",
+		"actor_username",
+		"target_type",
+		"target_id",
+		"ip_address",
+		"user_agent",
+		"changes",
+		"created_at",
+	).From("audit_entries")
+
+	q = filterAuditEntries(q, params).OrderBy("created_at DESC")
+
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
+	}
+
+	q = q.RunWith(repo.DB)
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var entries []*audit.Entry
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan audit entry: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (repo *AuditEntryRepo) Count(ctx context.Context, params audit.ListEntriesParams) (int, error) {
+	q := filterAuditEntries(squirrel.Select("COUNT(*)").From("audit_entries"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count audit entries: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,79 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+)
+
+type AvatarRepo struct {
+	DB *sql.DB
+}
+
+func (repo *AvatarRepo) Replace(ctx context.Context, userID string, avatars []*auth.Avatar) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("avatars").Where(squirrel.Eq{"user_id": userID}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete avatars: %w", err)
+	}
+
+	for _, avatar := range avatars {
+		_, err = squirrel.Insert("avatars").
+			Columns("user_id", "size", "image", "created_at").
+			Values(userID, avatar.Size, avatar.Image, avatar.CreatedAt).
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error on exec insert avatar: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *AvatarRepo) Get(ctx context.Context, userID string, size int) (*auth.Avatar, error) {
+	q := squirrel.Select("user_id", "size", "image", "created_at").
+		From("avatars").
+		Where(squirrel.Eq{"user_id": userID, "size": size})
+
+	var avatar auth.Avatar
+
+	err := q.RunWith(repo.DB).
+		QueryRowContext(ctx).
+		Scan(&avatar.UserID, &avatar.Size, &avatar.Image, &avatar.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auth.AvatarNotFoundError{UserID: userID, Size: size}
+		}
+
+		return nil, fmt.Errorf("error on scan avatar: %w", err)
+	}
+
+	return &avatar, nil
+}
+
+func (repo *AvatarRepo) Delete(ctx context.Context, userID string) error {
+	_, err := squirrel.Delete("avatars").Where(squirrel.Eq{"user_id": userID}).RunWith(repo.DB).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete avatars: %w", err)
+	}
+
+	return nil
+}
@@ -3,8 +3,10 @@ package sqlite3
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/nasermirzaei89/fullstackgo/blog"
@@ -16,8 +18,17 @@ type CommentRepo struct {
 
 func (repo *CommentRepo) Create(ctx context.Context, comment *blog.Comment) error {
 	q := squirrel.Insert("comments").
-		Columns("id", "post_id", "user_id", "content", "created_at", "updated_at").
-		Values(comment.ID, comment.PostID, comment.UserID, comment.Content, comment.CreatedAt, comment.UpdatedAt)
+		Columns("id", "post_id", "parent_id", "user_id", "content", "status", "created_at", "updated_at").
+		Values(
+			comment.ID,
+			comment.PostID,
+			comment.ParentID,
+			comment.UserID,
+			comment.Content,
+			comment.Status,
+			comment.CreatedAt,
+			comment.UpdatedAt,
+		)
 
 	q = q.RunWith(repo.DB)
 
@@ -35,6 +46,7 @@ func scanComment(rs squirrel.RowScanner) (*blog.Comment, error) {
 	err := rs.Scan(
 		&comment.ID,
 		&comment.PostID,
+		&comment.ParentID,
 		&comment.UserID,
 		&comment.UserUsername,
 		&comment.UserName,
@@ -42,6 +54,8 @@ func scanComment(rs squirrel.RowScanner) (*blog.Comment, error) {
 		&comment.Content,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
+		&comment.DeletedAt,
+		&comment.Status,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error on scan row: %w", err)
@@ -50,6 +64,43 @@ func scanComment(rs squirrel.RowScanner) (*blog.Comment, error) {
 	return &comment, nil
 }
 
+var commentSortColumns = map[string]string{
+	"created_at": "c.created_at",
+}
+
+func filterComments(q squirrel.SelectBuilder, params blog.ListCommentsParams) squirrel.SelectBuilder {
+	if params.Trashed {
+		q = q.Where(squirrel.NotEq{"c.deleted_at": nil})
+	} else {
+		q = q.Where(squirrel.Eq{"c.deleted_at": nil})
+	}
+
+	if params.PostID != "" {
+		q = q.Where(squirrel.Eq{"c.post_id": params.PostID})
+	}
+
+	if params.UserID != "" {
+		q = q.Where(squirrel.Eq{"c.user_id": params.UserID})
+	}
+
+	if params.PostNotTrashed {
+		q = q.Join("posts p ON c.post_id = p.id").Where(squirrel.Eq{"p.deleted_at": nil})
+	}
+
+	switch {
+	case params.Status != "":
+		q = q.Where(squirrel.Eq{"c.status": params.Status})
+	case !params.IncludePending:
+		q = q.Where(squirrel.Eq{"c.status": blog.CommentStatusApproved})
+	}
+
+	if params.Search != "" {
+		q = q.Where(squirrel.Like{"c.content": "%" + params.Search + "%"})
+	}
+
+	return q
+}
+
 func (repo *CommentRepo) List(
 	ctx context.Context,
 	params blog.ListCommentsParams,
@@ -57,6 +108,7 @@ func (repo *CommentRepo) List(
 	q := squirrel.Select(
 		"c.id",
 		"c.post_id",
+		"c.parent_id",
 		"c.user_id",
 		"u.username",
 		"u.name",
@@ -64,10 +116,27 @@ func (repo *CommentRepo) List(
 		"c.content",
 		"c.created_at",
 		"c.updated_at",
-	).From("comments c").Join("users u ON c.user_id = u.id").OrderBy("c.created_at ASC")
+		"c.deleted_at",
+		"c.status",
+	).From("comments c").Join("users u ON c.user_id = u.id")
+
+	q = filterComments(q, params)
+
+	switch column, ok := commentSortColumns[params.SortBy]; {
+	case ok:
+		q = q.OrderBy(column + sortDirection(params.SortDesc))
+	case params.Trashed:
+		q = q.OrderBy("c.deleted_at DESC")
+	default:
+		q = q.OrderBy("c.created_at ASC")
+	}
 
-	if params.PostID != "" {
-		q = q.Where(squirrel.Eq{"c.post_id": params.PostID})
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
 	}
 
 	q = q.RunWith(repo.DB)
@@ -103,10 +172,25 @@ func (repo *CommentRepo) List(
 	return comments, nil
 }
 
+func (repo *CommentRepo) Count(ctx context.Context, params blog.ListCommentsParams) (int, error) {
+	q := filterComments(squirrel.Select("COUNT(*)").From("comments c"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count comments: %w", err)
+	}
+
+	return count, nil
+}
+
 func (repo *CommentRepo) GetByID(ctx context.Context, id string) (*blog.Comment, error) {
 	q := squirrel.Select(
 		"c.id",
 		"c.post_id",
+		"c.parent_id",
 		"c.user_id",
 		"u.username",
 		"u.name",
@@ -114,12 +198,51 @@ func (repo *CommentRepo) GetByID(ctx context.Context, id string) (*blog.Comment,
 		"c.content",
 		"c.created_at",
 		"c.updated_at",
-	).From("comments c").Join("users u ON c.user_id = u.id").Where(squirrel.Eq{"c.id": id})
+		"c.deleted_at",
+		"c.status",
+	).From("comments c").Join("users u ON c.user_id = u.id").Where(squirrel.Eq{"c.id": id, "c.deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
 	comment, err := scanComment(q.QueryRowContext(ctx))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, blog.CommentByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+func (repo *CommentRepo) GetTrashedByID(ctx context.Context, id string) (*blog.Comment, error) {
+	q := squirrel.Select(
+		"c.id",
+		"c.post_id",
+		"c.parent_id",
+		"c.user_id",
+		"u.username",
+		"u.name",
+		"u.avatar_url",
+		"c.content",
+		"c.created_at",
+		"c.updated_at",
+		"c.deleted_at",
+		"c.status",
+	).From("comments c").
+		Join("users u ON c.user_id = u.id").
+		Where(squirrel.Eq{"c.id": id}).
+		Where(squirrel.NotEq{"c.deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	comment, err := scanComment(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, blog.CommentByIDNotFoundError{ID: id}
+		}
+
 		return nil, fmt.Errorf("error on scan comment: %w", err)
 	}
 
@@ -132,7 +255,7 @@ func (repo *CommentRepo) Update(ctx context.Context, comment *blog.Comment) erro
 		"user_id":    comment.UserID,
 		"content":    comment.Content,
 		"updated_at": comment.UpdatedAt,
-	}).Where(squirrel.Eq{"id": comment.ID})
+	}).Where(squirrel.Eq{"id": comment.ID, "deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
@@ -153,8 +276,34 @@ func (repo *CommentRepo) Update(ctx context.Context, comment *blog.Comment) erro
 	return nil
 }
 
+func (repo *CommentRepo) Approve(ctx context.Context, id string) error {
+	q := squirrel.Update("comments").
+		Set("status", blog.CommentStatusApproved).
+		Where(squirrel.Eq{"id": id, "deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return blog.CommentByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
 func (repo *CommentRepo) Delete(ctx context.Context, id string) error {
-	q := squirrel.Delete("comments").Where(squirrel.Eq{"id": id})
+	q := squirrel.Update("comments").
+		Set("deleted_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
@@ -165,3 +314,111 @@ func (repo *CommentRepo) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+func (repo *CommentRepo) Restore(ctx context.Context, id string) error {
+	q := squirrel.Update("comments").
+		Set("deleted_at", nil).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return blog.CommentByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *CommentRepo) Purge(ctx context.Context, id string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := squirrel.Delete("comments").
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return blog.CommentByIDNotFoundError{ID: id}
+	}
+
+	_, err = squirrel.Delete("mentions").Where(squirrel.Eq{"comment_id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *CommentRepo) PurgeTrashed(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("mentions").
+		Where(squirrel.Expr(
+			"comment_id IN (?)",
+			squirrel.Select("id").From("comments").Where(squirrel.Lt{"deleted_at": deletedBefore}),
+		)).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	result, err := squirrel.Delete("comments").
+		Where(squirrel.Lt{"deleted_at": deletedBefore}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,84 @@
+package sqlite3_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+)
+
+func TestListCommentsOnPostsNotTrashed(t *testing.T) {
+	db := newDB(t)
+	userRepo := &sqlite3.UserRepo{DB: db}
+	postRepo := &sqlite3.PostRepo{DB: db}
+	commentRepo := &sqlite3.CommentRepo{DB: db}
+
+	commenter := createUser(t, userRepo, "commenter")
+
+	now := time.Now()
+
+	trashed := &blog.Post{
+		ID:        "trashed-id",
+		Title:     "Trashed",
+		Slug:      "trashed",
+		Content:   "<p>Trashed</p>",
+		Format:    blog.PostFormatHTML,
+		AuthorID:  commenter.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := postRepo.Create(t.Context(), trashed)
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	// The comments on the trashed post are newer than the ones that should be listed.
+	for i, postID := range []string{helloWorldPostID, helloWorldPostID, trashed.ID, trashed.ID, trashed.ID} {
+		err = commentRepo.Create(t.Context(), &blog.Comment{
+			ID:        "comment-" + strconv.Itoa(i),
+			PostID:    postID,
+			UserID:    commenter.ID,
+			Content:   "<p>Comment</p>",
+			Status:    blog.CommentStatusApproved,
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+			UpdatedAt: now,
+		})
+		if err != nil {
+			t.Fatalf("failed to create comment: %v", err)
+		}
+	}
+
+	err = postRepo.Delete(t.Context(), trashed.ID)
+	if err != nil {
+		t.Fatalf("failed to trash post: %v", err)
+	}
+
+	params := blog.ListCommentsParams{
+		UserID:         commenter.ID,
+		PostNotTrashed: true,
+		SortBy:         "created_at",
+		SortDesc:       true,
+		Limit:          2,
+	}
+
+	comments, err := commentRepo.List(t.Context(), params)
+	if err != nil {
+		t.Fatalf("failed to list comments: %v", err)
+	}
+
+	if len(comments) != 2 || comments[0].ID != "comment-1" || comments[1].ID != "comment-0" {
+		t.Errorf("expected the comments on the post that is not trashed, got %v", comments)
+	}
+
+	count, err := commentRepo.Count(t.Context(), params)
+	if err != nil {
+		t.Fatalf("failed to count comments: %v", err)
+	}
+
+	if count != 2 {
+		t.Errorf("expected 2 comments, got %d", count)
+	}
+}
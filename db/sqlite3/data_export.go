@@ -0,0 +1,211 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/dataexport"
+)
+
+type DataExportRepo struct {
+	DB *sql.DB
+}
+
+func (repo *DataExportRepo) Create(ctx context.Context, export *dataexport.Export) error {
+	q := squirrel.Insert("data_exports").
+		Columns("id", "user_id", "status", "archive", "created_at", "expires_at", "downloaded_at").
+		Values(
+			export.ID,
+			export.UserID,
+			export.Status,
+			export.Archive,
+			export.CreatedAt,
+			export.ExpiresAt,
+			export.DownloadedAt,
+		).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create data export: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *DataExportRepo) Get(ctx context.Context, id string) (*dataexport.Export, error) {
+	q := squirrel.Select("id", "user_id", "status", "archive", "created_at", "expires_at", "downloaded_at").
+		From("data_exports").
+		Where(squirrel.Eq{"id": id}).
+		RunWith(repo.DB)
+
+	var export dataexport.Export
+
+	err := q.QueryRowContext(ctx).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.Archive,
+		&export.CreatedAt,
+		&export.ExpiresAt,
+		&export.DownloadedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dataexport.ExportByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan data export: %w", err)
+	}
+
+	return &export, nil
+}
+
+func (repo *DataExportRepo) HasRecent(ctx context.Context, userID string, createdAfter time.Time) (bool, error) {
+	q := squirrel.Select("COUNT(*)").
+		From("data_exports").
+		Where(squirrel.Eq{"user_id": userID}).
+		Where(squirrel.Or{
+			squirrel.Eq{"status": dataexport.StatusPending},
+			squirrel.And{
+				squirrel.NotEq{"status": dataexport.StatusFailed},
+				squirrel.Gt{"created_at": createdAfter},
+			},
+		}).
+		RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error on scan count: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (repo *DataExportRepo) Update(ctx context.Context, export *dataexport.Export) error {
+	q := squirrel.Update("data_exports").
+		Set("status", export.Status).
+		Set("archive", export.Archive).
+		Set("expires_at", export.ExpiresAt).
+		Set("downloaded_at", export.DownloadedAt).
+		Where(squirrel.Eq{"id": export.ID})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return dataexport.ExportByIDNotFoundError{ID: export.ID}
+	}
+
+	return nil
+}
+
+func (repo *DataExportRepo) Claim(ctx context.Context, id string, now time.Time) ([]byte, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	claimable := squirrel.And{
+		squirrel.Eq{"id": id, "status": dataexport.StatusReady, "downloaded_at": nil},
+		squirrel.Gt{"expires_at": now},
+	}
+
+	var archive []byte
+
+	err = squirrel.Select("archive").
+		From("data_exports").
+		Where(claimable).
+		RunWith(tx).
+		QueryRowContext(ctx).
+		Scan(&archive)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dataexport.ExportByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan archive: %w", err)
+	}
+
+	_, err = squirrel.Update("data_exports").
+		Set("archive", nil).
+		Set("downloaded_at", now).
+		Where(squirrel.Eq{"id": id}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on exec claim: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return archive, nil
+}
+
+func (repo *DataExportRepo) PurgeExpired(ctx context.Context, now, createdBefore time.Time) (int, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := squirrel.Update("data_exports").
+		Set("archive", nil).
+		Where(squirrel.NotEq{"archive": nil}).
+		Where(squirrel.LtOrEq{"expires_at": now}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec clear archives: %w", err)
+	}
+
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	result, err = squirrel.Delete("data_exports").
+		Where(squirrel.Lt{"created_at": createdBefore}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return int(cleared + deleted), nil
+}
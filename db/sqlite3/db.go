@@ -46,3 +46,11 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 
 	return nil
 }
+
+func sortDirection(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+
+	return " ASC"
+}
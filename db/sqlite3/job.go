@@ -0,0 +1,195 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
+)
+
+type JobRepo struct {
+	DB *sql.DB
+}
+
+var jobColumns = []string{
+	"id",
+	"type",
+	"payload",
+	"status",
+	"attempts",
+	"last_error",
+	"run_at",
+	"created_at",
+	"updated_at",
+	"completed_at",
+}
+
+func scanJob(rs squirrel.RowScanner) (*jobqueue.Job, error) {
+	var (
+		job     jobqueue.Job
+		payload string
+	)
+
+	err := rs.Scan(
+		&job.ID,
+		&job.Type,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.CompletedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	job.Payload = []byte(payload)
+
+	return &job, nil
+}
+
+func (repo *JobRepo) Create(ctx context.Context, job *jobqueue.Job) error {
+	q := squirrel.Insert("jobs").
+		Columns(jobColumns...).
+		Values(
+			job.ID,
+			job.Type,
+			string(job.Payload),
+			job.Status,
+			job.Attempts,
+			job.LastError,
+			job.RunAt,
+			job.CreatedAt,
+			job.UpdatedAt,
+			job.CompletedAt,
+		).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create job: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *JobRepo) Claim(ctx context.Context, now time.Time) (*jobqueue.Job, error) {
+	// A single statement picks and marks the job, so two workers never claim the same one.
+	due := squirrel.Select("id").
+		From("jobs").
+		Where(squirrel.Eq{"status": jobqueue.StatusPending}).
+		Where(squirrel.LtOrEq{"run_at": now}).
+		OrderBy("run_at").
+		Limit(1)
+
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error on build due job query: %w", err)
+	}
+
+	q := squirrel.Update("jobs").
+		Set("status", jobqueue.StatusRunning).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("updated_at", now).
+		Where("id = ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING " + strings.Join(jobColumns, ", ")).
+		RunWith(repo.DB)
+
+	job, err := scanJob(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jobqueue.ErrNoJobDue
+		}
+
+		return nil, fmt.Errorf("error on scan job: %w", err)
+	}
+
+	return job, nil
+}
+
+func (repo *JobRepo) update(ctx context.Context, id string, q squirrel.UpdateBuilder) error {
+	result, err := q.Where(squirrel.Eq{"id": id}).RunWith(repo.DB).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return jobqueue.JobByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *JobRepo) Complete(ctx context.Context, id string, completedAt time.Time) error {
+	return repo.update(ctx, id, squirrel.Update("jobs").
+		Set("status", jobqueue.StatusSucceeded).
+		Set("payload", "").
+		Set("last_error", "").
+		Set("completed_at", completedAt).
+		Set("updated_at", completedAt))
+}
+
+func (repo *JobRepo) Reschedule(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	return repo.update(ctx, id, squirrel.Update("jobs").
+		Set("status", jobqueue.StatusPending).
+		Set("last_error", lastError).
+		Set("run_at", runAt).
+		Set("updated_at", time.Now()))
+}
+
+func (repo *JobRepo) Bury(ctx context.Context, id string, lastError string) error {
+	return repo.update(ctx, id, squirrel.Update("jobs").
+		Set("status", jobqueue.StatusDead).
+		Set("last_error", lastError).
+		Set("updated_at", time.Now()))
+}
+
+func (repo *JobRepo) RequeueRunning(ctx context.Context) (int, error) {
+	result, err := squirrel.Update("jobs").
+		Set("status", jobqueue.StatusPending).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"status": jobqueue.StatusRunning}).
+		RunWith(repo.DB).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+func (repo *JobRepo) Purge(ctx context.Context, finishedBefore time.Time) (int, error) {
+	result, err := squirrel.Delete("jobs").
+		Where(squirrel.Eq{"status": []jobqueue.Status{jobqueue.StatusSucceeded, jobqueue.StatusDead}}).
+		Where(squirrel.Lt{"updated_at": finishedBefore}).
+		RunWith(repo.DB).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,79 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+)
+
+type LoginRepo struct {
+	DB *sql.DB
+}
+
+func scanLogin(rs squirrel.RowScanner) (*auth.Login, error) {
+	var login auth.Login
+
+	err := rs.Scan(&login.ID, &login.UserID, &login.IPAddress, &login.UserAgent, &login.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &login, nil
+}
+
+func (repo *LoginRepo) Create(ctx context.Context, login *auth.Login) error {
+	q := squirrel.Insert("user_logins").
+		Columns("id", "user_id", "ip_address", "user_agent", "created_at").
+		Values(login.ID, login.UserID, login.IPAddress, login.UserAgent, login.CreatedAt).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create login: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *LoginRepo) ListByUserID(ctx context.Context, userID string) ([]*auth.Login, error) {
+	q := squirrel.Select("id", "user_id", "ip_address", "user_agent", "created_at").
+		From("user_logins").
+		Where(squirrel.Eq{"user_id": userID}).
+		OrderBy("created_at DESC")
+
+	q = q.RunWith(repo.DB)
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var logins []*auth.Login
+
+	for rows.Next() {
+		login, err := scanLogin(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan login: %w", err)
+		}
+
+		logins = append(logins, login)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return logins, nil
+}
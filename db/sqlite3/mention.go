@@ -0,0 +1,99 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+type MentionRepo struct {
+	DB *sql.DB
+}
+
+func (repo *MentionRepo) Replace(
+	ctx context.Context,
+	postID, commentID string,
+	usernames []string,
+	createdAt time.Time,
+) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("mentions").
+		Where(squirrel.Eq{"post_id": postID, "comment_id": commentID}).
+		Where(squirrel.NotEq{"username": usernames}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	for _, username := range usernames {
+		_, err = squirrel.Insert("mentions").
+			Columns("post_id", "comment_id", "username", "created_at").
+			Values(postID, commentID, username, createdAt).
+			Suffix("ON CONFLICT (post_id, comment_id, username) DO NOTHING").
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error on exec insert mention: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *MentionRepo) List(ctx context.Context, postID, commentID string) ([]*blog.Mention, error) {
+	q := squirrel.Select("post_id", "comment_id", "username", "created_at").
+		From("mentions").
+		Where(squirrel.Eq{"post_id": postID, "comment_id": commentID}).
+		OrderBy("created_at", "username")
+
+	rows, err := q.RunWith(repo.DB).QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var mentions []*blog.Mention
+
+	for rows.Next() {
+		var m blog.Mention
+
+		err = rows.Scan(&m.PostID, &m.CommentID, &m.Username, &m.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan mention: %w", err)
+		}
+
+		mentions = append(mentions, &m)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return mentions, nil
+}
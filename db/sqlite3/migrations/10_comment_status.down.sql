@@ -0,0 +1,3 @@
+DROP INDEX comments_status_idx;
+
+ALTER TABLE comments DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE comments ADD COLUMN status TEXT NOT NULL DEFAULT 'approved';
+
+CREATE INDEX comments_status_idx ON comments (status);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN disabled_at DATETIME;
\ No newline at end of file
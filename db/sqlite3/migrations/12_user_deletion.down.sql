@@ -0,0 +1,3 @@
+DELETE FROM users WHERE id = '00000000-0000-0000-0000-000000000000';
+
+ALTER TABLE users DROP COLUMN banned_until;
\ No newline at end of file
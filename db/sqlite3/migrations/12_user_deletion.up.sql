@@ -0,0 +1,40 @@
+ALTER TABLE users ADD COLUMN banned_until DATETIME;
+
+-- Registration did not check usernames before, so make room for the placeholder if someone took its username or email
+-- address.
+UPDATE users
+SET
+    username = username || '-' || id
+WHERE
+    username = '[deleted]';
+
+UPDATE users
+SET
+    email_address = email_address || '-' || id
+WHERE
+    email_address = '[deleted]';
+
+INSERT INTO
+    users (
+        id,
+        username,
+        email_address,
+        password_hash,
+        name,
+        avatar_url,
+        created_at,
+        updated_at,
+        disabled_at
+    )
+VALUES
+    (
+        '00000000-0000-0000-0000-000000000000',
+        '[deleted]',
+        '[deleted]',
+        '',
+        'Deleted user',
+        '',
+        '2025-01-01T00:00:00Z',
+        '2025-01-01T00:00:00Z',
+        '2025-01-01T00:00:00Z'
+    );
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE user_logins;
\ No newline at end of file
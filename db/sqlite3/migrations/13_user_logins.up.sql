@@ -0,0 +1,11 @@
+CREATE TABLE
+    user_logins (
+        id TEXT NOT NULL PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        ip_address TEXT NOT NULL,
+        user_agent TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+CREATE INDEX user_logins_user_id_idx ON user_logins (user_id);
\ No newline at end of file
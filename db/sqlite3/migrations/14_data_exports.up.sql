@@ -0,0 +1,13 @@
+CREATE TABLE
+    data_exports (
+        id TEXT NOT NULL PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        status TEXT NOT NULL,
+        archive BLOB,
+        created_at DATETIME NOT NULL,
+        expires_at DATETIME,
+        downloaded_at DATETIME,
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id);
\ No newline at end of file
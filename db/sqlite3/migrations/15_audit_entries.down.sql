@@ -0,0 +1 @@
+DROP TABLE audit_entries;
\ No newline at end of file
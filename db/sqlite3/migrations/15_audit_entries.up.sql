@@ -0,0 +1,27 @@
+CREATE TABLE
+    audit_entries (
+        id TEXT NOT NULL PRIMARY KEY,
+        action TEXT NOT NULL,
+        actor_id TEXT NOT NULL,
+        actor_username TEXT NOT NULL,
+        target_type TEXT NOT NULL,
+        target_id TEXT NOT NULL,
+        ip_address TEXT NOT NULL,
+        user_agent TEXT NOT NULL,
+        changes TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+
+CREATE INDEX audit_entries_created_at_idx ON audit_entries (created_at);
+
+CREATE INDEX audit_entries_action_idx ON audit_entries (action);
+
+CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit entries are append-only');
+END;
+
+CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit entries are append-only');
+END;
\ No newline at end of file
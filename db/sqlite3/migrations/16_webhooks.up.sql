@@ -0,0 +1,30 @@
+CREATE TABLE
+    webhook_subscriptions (
+        id TEXT NOT NULL PRIMARY KEY,
+        url TEXT NOT NULL,
+        secret TEXT NOT NULL,
+        event_types TEXT NOT NULL,
+        active BOOLEAN NOT NULL DEFAULT TRUE,
+        created_at DATETIME NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
+
+CREATE TABLE
+    webhook_deliveries (
+        id TEXT NOT NULL PRIMARY KEY,
+        subscription_id TEXT NOT NULL,
+        event_type TEXT NOT NULL,
+        payload TEXT NOT NULL,
+        status TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_status_code INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        next_attempt_at DATETIME,
+        created_at DATETIME NOT NULL,
+        delivered_at DATETIME,
+        FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions (id)
+    );
+
+CREATE INDEX webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, created_at);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE
+    jobs (
+        id TEXT NOT NULL PRIMARY KEY,
+        type TEXT NOT NULL,
+        payload TEXT NOT NULL,
+        status TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        run_at DATETIME NOT NULL,
+        created_at DATETIME NOT NULL,
+        updated_at DATETIME NOT NULL,
+        completed_at DATETIME
+    );
+
+CREATE INDEX jobs_due_idx ON jobs (status, run_at);
\ No newline at end of file
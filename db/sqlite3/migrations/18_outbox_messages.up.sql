@@ -0,0 +1,18 @@
+CREATE TABLE
+    outbox_messages (
+        id TEXT NOT NULL PRIMARY KEY,
+        to_address TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        body TEXT NOT NULL,
+        status TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        next_attempt_at DATETIME,
+        created_at DATETIME NOT NULL,
+        updated_at DATETIME NOT NULL,
+        sent_at DATETIME
+    );
+
+CREATE INDEX outbox_messages_created_at_idx ON outbox_messages (created_at);
+
+CREATE INDEX outbox_messages_due_idx ON outbox_messages (status, next_attempt_at);
\ No newline at end of file
@@ -0,0 +1,13 @@
+ALTER TABLE outbox_messages ADD COLUMN to_address TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE outbox_messages ADD COLUMN subject TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE outbox_messages ADD COLUMN body TEXT NOT NULL DEFAULT '';
+
+UPDATE outbox_messages
+SET
+    to_address = COALESCE(json_extract(message, '$.to[0]'), ''),
+    subject = COALESCE(json_extract(message, '$.subject'), ''),
+    body = COALESCE(json_extract(message, '$.text'), '');
+
+ALTER TABLE outbox_messages DROP COLUMN message;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE outbox_messages ADD COLUMN message TEXT NOT NULL DEFAULT '{}';
+
+UPDATE outbox_messages
+SET
+    message = json_object('to', json_array(to_address), 'subject', subject, 'text', body);
+
+ALTER TABLE outbox_messages DROP COLUMN to_address;
+
+ALTER TABLE outbox_messages DROP COLUMN subject;
+
+ALTER TABLE outbox_messages DROP COLUMN body;
\ No newline at end of file
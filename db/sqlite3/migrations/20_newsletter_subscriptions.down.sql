@@ -0,0 +1 @@
+DROP TABLE newsletter_subscriptions;
\ No newline at end of file
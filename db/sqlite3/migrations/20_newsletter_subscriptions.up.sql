@@ -0,0 +1,15 @@
+CREATE TABLE
+    newsletter_subscriptions (
+        id TEXT NOT NULL PRIMARY KEY,
+        email_address TEXT NOT NULL UNIQUE,
+        user_id TEXT NOT NULL DEFAULT '',
+        frequency TEXT NOT NULL,
+        status TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        confirmed_at DATETIME,
+        last_sent_at DATETIME
+    );
+
+CREATE INDEX newsletter_subscriptions_user_id_idx ON newsletter_subscriptions (user_id);
+
+CREATE INDEX newsletter_subscriptions_due_idx ON newsletter_subscriptions (status, last_sent_at);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE notification_preferences;
\ No newline at end of file
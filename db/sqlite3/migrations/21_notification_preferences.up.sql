@@ -0,0 +1,8 @@
+CREATE TABLE
+    notification_preferences (
+        user_id TEXT NOT NULL PRIMARY KEY,
+        email_comments BOOLEAN NOT NULL,
+        email_replies BOOLEAN NOT NULL,
+        email_mentions BOOLEAN NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
\ No newline at end of file
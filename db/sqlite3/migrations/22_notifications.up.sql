@@ -0,0 +1,13 @@
+CREATE TABLE
+    notifications (
+        id TEXT NOT NULL PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        type TEXT NOT NULL,
+        actor_id TEXT NOT NULL,
+        text TEXT NOT NULL,
+        url TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        read_at DATETIME
+    );
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at);
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE
+    mentions (
+        post_id TEXT NOT NULL,
+        comment_id TEXT NOT NULL,
+        username TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (post_id, comment_id, username)
+    );
+
+CREATE INDEX mentions_username_idx ON mentions (username);
\ No newline at end of file
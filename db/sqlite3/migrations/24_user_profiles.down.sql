@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN links;
+
+ALTER TABLE users DROP COLUMN bio;
\ No newline at end of file
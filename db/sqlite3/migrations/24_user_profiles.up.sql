@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN links TEXT NOT NULL DEFAULT '';
\ No newline at end of file
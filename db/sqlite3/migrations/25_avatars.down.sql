@@ -0,0 +1 @@
+DROP TABLE avatars;
\ No newline at end of file
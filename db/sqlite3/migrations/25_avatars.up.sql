@@ -0,0 +1,8 @@
+CREATE TABLE
+    avatars (
+        user_id TEXT NOT NULL,
+        size INTEGER NOT NULL,
+        image BLOB NOT NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (user_id, size)
+    );
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE comments ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
\ No newline at end of file
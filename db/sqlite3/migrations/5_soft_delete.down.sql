@@ -0,0 +1,7 @@
+DROP INDEX comments_deleted_at_idx;
+
+DROP INDEX posts_deleted_at_idx;
+
+ALTER TABLE comments DROP COLUMN deleted_at;
+
+ALTER TABLE posts DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME;
+
+ALTER TABLE comments ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX posts_deleted_at_idx ON posts (deleted_at);
+
+CREATE INDEX comments_deleted_at_idx ON comments (deleted_at);
\ No newline at end of file
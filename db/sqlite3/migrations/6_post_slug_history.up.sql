@@ -0,0 +1,9 @@
+CREATE TABLE
+    post_slug_history (
+        slug TEXT NOT NULL PRIMARY KEY,
+        post_id TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        FOREIGN KEY (post_id) REFERENCES posts (id)
+    );
+
+CREATE INDEX post_slug_history_post_id_idx ON post_slug_history (post_id);
\ No newline at end of file
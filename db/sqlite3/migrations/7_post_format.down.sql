@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP COLUMN markdown;
+
+ALTER TABLE posts DROP COLUMN format;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE posts ADD COLUMN format TEXT NOT NULL DEFAULT 'html';
+
+ALTER TABLE posts ADD COLUMN markdown TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE settings;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE
+    settings (
+        key TEXT NOT NULL PRIMARY KEY,
+        value TEXT NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
\ No newline at end of file
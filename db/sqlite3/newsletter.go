@@ -0,0 +1,237 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+)
+
+type NewsletterSubscriptionRepo struct {
+	DB *sql.DB
+}
+
+var newsletterSubscriptionColumns = []string{
+	"id",
+	"email_address",
+	"user_id",
+	"frequency",
+	"status",
+	"created_at",
+	"confirmed_at",
+	"last_sent_at",
+}
+
+func scanNewsletterSubscription(rs squirrel.RowScanner) (*newsletter.Subscription, error) {
+	var sub newsletter.Subscription
+
+	err := rs.Scan(
+		&sub.ID,
+		&sub.EmailAddress,
+		&sub.UserID,
+		&sub.Frequency,
+		&sub.Status,
+		&sub.CreatedAt,
+		&sub.ConfirmedAt,
+		&sub.LastSentAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &sub, nil
+}
+
+func (repo *NewsletterSubscriptionRepo) Create(ctx context.Context, sub *newsletter.Subscription) error {
+	q := squirrel.Insert("newsletter_subscriptions").
+		Columns(newsletterSubscriptionColumns...).
+		Values(
+			sub.ID,
+			sub.EmailAddress,
+			sub.UserID,
+			sub.Frequency,
+			sub.Status,
+			sub.CreatedAt,
+			sub.ConfirmedAt,
+			sub.LastSentAt,
+		).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create newsletter subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *NewsletterSubscriptionRepo) Update(ctx context.Context, sub *newsletter.Subscription) error {
+	q := squirrel.Update("newsletter_subscriptions").
+		Set("user_id", sub.UserID).
+		Set("frequency", sub.Frequency).
+		Set("status", sub.Status).
+		Set("created_at", sub.CreatedAt).
+		Set("confirmed_at", sub.ConfirmedAt).
+		Set("last_sent_at", sub.LastSentAt).
+		Where(squirrel.Eq{"id": sub.ID})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return newsletter.SubscriptionByIDNotFoundError{ID: sub.ID}
+	}
+
+	return nil
+}
+
+func (repo *NewsletterSubscriptionRepo) Delete(ctx context.Context, id string) error {
+	q := squirrel.Delete("newsletter_subscriptions").Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return newsletter.SubscriptionByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *NewsletterSubscriptionRepo) get(
+	ctx context.Context,
+	where squirrel.Eq,
+	notFound error,
+) (*newsletter.Subscription, error) {
+	q := squirrel.Select(newsletterSubscriptionColumns...).
+		From("newsletter_subscriptions").
+		Where(where)
+
+	q = q.RunWith(repo.DB)
+
+	sub, err := scanNewsletterSubscription(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound
+		}
+
+		return nil, fmt.Errorf("error on scan newsletter subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (repo *NewsletterSubscriptionRepo) GetByID(ctx context.Context, id string) (*newsletter.Subscription, error) {
+	return repo.get(ctx, squirrel.Eq{"id": id}, newsletter.SubscriptionByIDNotFoundError{ID: id})
+}
+
+func (repo *NewsletterSubscriptionRepo) GetByEmailAddress(
+	ctx context.Context,
+	emailAddress string,
+) (*newsletter.Subscription, error) {
+	return repo.get(
+		ctx,
+		squirrel.Eq{"email_address": emailAddress},
+		newsletter.SubscriptionByEmailNotFoundError{EmailAddress: emailAddress},
+	)
+}
+
+func (repo *NewsletterSubscriptionRepo) GetByUserID(
+	ctx context.Context,
+	userID string,
+) (*newsletter.Subscription, error) {
+	return repo.get(ctx, squirrel.Eq{"user_id": userID}, newsletter.SubscriptionByUserIDNotFoundError{UserID: userID})
+}
+
+func (repo *NewsletterSubscriptionRepo) ListDue(
+	ctx context.Context,
+	postedAt, now time.Time,
+	limit int,
+) ([]*newsletter.Subscription, error) {
+	frequencies := squirrel.Or{}
+	for _, frequency := range newsletter.Frequencies {
+		frequencies = append(frequencies, squirrel.And{
+			squirrel.Eq{"frequency": frequency},
+			squirrel.LtOrEq{"last_sent_at": now.Add(-frequency.Period())},
+		})
+	}
+
+	q := squirrel.Select(newsletterSubscriptionColumns...).
+		From("newsletter_subscriptions").
+		Where(squirrel.Eq{"status": newsletter.StatusConfirmed}).
+		Where(squirrel.Lt{"last_sent_at": postedAt}).
+		Where(frequencies).
+		OrderBy("last_sent_at").
+		Limit(uint64(limit))
+
+	rows, err := q.RunWith(repo.DB).QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var subs []*newsletter.Subscription
+
+	for rows.Next() {
+		sub, err := scanNewsletterSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan newsletter subscription: %w", err)
+		}
+
+		subs = append(subs, sub)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (repo *NewsletterSubscriptionRepo) PurgePending(ctx context.Context, createdBefore time.Time) (int, error) {
+	q := squirrel.Delete("newsletter_subscriptions").
+		Where(squirrel.Eq{"status": newsletter.StatusPending}).
+		Where(squirrel.Lt{"created_at": createdBefore})
+
+	result, err := q.RunWith(repo.DB).ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec query: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	return int(count), nil
+}
@@ -0,0 +1,240 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+)
+
+type NotificationPreferencesRepo struct {
+	DB *sql.DB
+}
+
+func (repo *NotificationPreferencesRepo) Get(ctx context.Context, userID string) (*notification.Preferences, error) {
+	q := squirrel.Select("user_id", "email_comments", "email_replies", "email_mentions", "updated_at").
+		From("notification_preferences").
+		Where(squirrel.Eq{"user_id": userID})
+
+	q = q.RunWith(repo.DB)
+
+	var prefs notification.Preferences
+
+	err := q.QueryRowContext(ctx).Scan(
+		&prefs.UserID,
+		&prefs.EmailComments,
+		&prefs.EmailReplies,
+		&prefs.EmailMentions,
+		&prefs.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notification.PreferencesByUserIDNotFoundError{UserID: userID}
+		}
+
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &prefs, nil
+}
+
+func (repo *NotificationPreferencesRepo) Save(ctx context.Context, prefs *notification.Preferences) error {
+	q := squirrel.Insert("notification_preferences").
+		Columns("user_id", "email_comments", "email_replies", "email_mentions", "updated_at").
+		Values(prefs.UserID, prefs.EmailComments, prefs.EmailReplies, prefs.EmailMentions, prefs.UpdatedAt).
+		Suffix(
+			"ON CONFLICT (user_id) DO UPDATE SET email_comments = excluded.email_comments, " +
+				"email_replies = excluded.email_replies, email_mentions = excluded.email_mentions, " +
+				"updated_at = excluded.updated_at",
+		)
+
+	q = q.RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	return nil
+}
+
+type NotificationRepo struct {
+	DB *sql.DB
+}
+
+var notificationColumns = []string{
+	"id",
+	"user_id",
+	"type",
+	"actor_id",
+	"text",
+	"url",
+	"created_at",
+	"read_at",
+}
+
+func scanNotification(rs squirrel.RowScanner) (*notification.Notification, error) {
+	var n notification.Notification
+
+	err := rs.Scan(
+		&n.ID,
+		&n.UserID,
+		&n.Type,
+		&n.ActorID,
+		&n.Text,
+		&n.URL,
+		&n.CreatedAt,
+		&n.ReadAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &n, nil
+}
+
+func (repo *NotificationRepo) Create(ctx context.Context, n *notification.Notification) error {
+	q := squirrel.Insert("notifications").
+		Columns(notificationColumns...).
+		Values(n.ID, n.UserID, n.Type, n.ActorID, n.Text, n.URL, n.CreatedAt, n.ReadAt).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create notification: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *NotificationRepo) GetByID(ctx context.Context, id string) (*notification.Notification, error) {
+	q := squirrel.Select(notificationColumns...).
+		From("notifications").
+		Where(squirrel.Eq{"id": id}).
+		RunWith(repo.DB)
+
+	n, err := scanNotification(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notification.NotificationByIDNotFoundError{ID: id}
+		}
+
+		return nil, err
+	}
+
+	return n, nil
+}
+
+func filterNotifications(
+	q squirrel.SelectBuilder,
+	params notification.ListNotificationsParams,
+) squirrel.SelectBuilder {
+	q = q.Where(squirrel.Eq{"user_id": params.UserID})
+
+	if params.Unread {
+		q = q.Where(squirrel.Eq{"read_at": nil})
+	}
+
+	return q
+}
+
+func (repo *NotificationRepo) List(
+	ctx context.Context,
+	params notification.ListNotificationsParams,
+) ([]*notification.Notification, error) {
+	q := squirrel.Select(notificationColumns...).From("notifications")
+
+	q = filterNotifications(q, params).OrderBy("created_at DESC")
+
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
+	}
+
+	rows, err := q.RunWith(repo.DB).QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var notifications []*notification.Notification
+
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan notification: %w", err)
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (repo *NotificationRepo) Count(ctx context.Context, params notification.ListNotificationsParams) (int, error) {
+	q := filterNotifications(squirrel.Select("COUNT(*)").From("notifications"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+func (repo *NotificationRepo) MarkRead(ctx context.Context, userID, id string, readAt time.Time) error {
+	q := squirrel.Update("notifications").
+		Set("read_at", squirrel.Expr("COALESCE(read_at, ?)", readAt)).
+		Where(squirrel.Eq{"id": id, "user_id": userID})
+
+	result, err := q.RunWith(repo.DB).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return notification.NotificationByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *NotificationRepo) MarkAllRead(ctx context.Context, userID string, readAt time.Time) error {
+	q := squirrel.Update("notifications").
+		Set("read_at", readAt).
+		Where(squirrel.Eq{"user_id": userID, "read_at": nil})
+
+	_, err := q.RunWith(repo.DB).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	return nil
+}
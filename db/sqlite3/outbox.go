@@ -0,0 +1,220 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+type OutboxMessageRepo struct {
+	DB *sql.DB
+}
+
+var outboxMessageColumns = []string{
+	"id",
+	"message",
+	"status",
+	"attempts",
+	"last_error",
+	"next_attempt_at",
+	"created_at",
+	"updated_at",
+	"sent_at",
+}
+
+func scanOutboxMessage(rs squirrel.RowScanner) (*mailer.OutboxMessage, error) {
+	var (
+		message mailer.OutboxMessage
+		msg     string
+	)
+
+	err := rs.Scan(
+		&message.ID,
+		&msg,
+		&message.Status,
+		&message.Attempts,
+		&message.LastError,
+		&message.NextAttemptAt,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+		&message.SentAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	err = json.Unmarshal([]byte(msg), &message.Message)
+	if err != nil {
+		return nil, fmt.Errorf("error on unmarshal message: %w", err)
+	}
+
+	return &message, nil
+}
+
+func (repo *OutboxMessageRepo) Create(ctx context.Context, message *mailer.OutboxMessage) error {
+	msg, err := json.Marshal(message.Message)
+	if err != nil {
+		return fmt.Errorf("error on marshal message: %w", err)
+	}
+
+	q := squirrel.Insert("outbox_messages").
+		Columns(outboxMessageColumns...).
+		Values(
+			message.ID,
+			string(msg),
+			message.Status,
+			message.Attempts,
+			message.LastError,
+			message.NextAttemptAt,
+			message.CreatedAt,
+			message.UpdatedAt,
+			message.SentAt,
+		).
+		RunWith(repo.DB)
+
+	_, err = q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *OutboxMessageRepo) Update(ctx context.Context, message *mailer.OutboxMessage) error {
+	q := squirrel.Update("outbox_messages").
+		Set("status", message.Status).
+		Set("attempts", message.Attempts).
+		Set("last_error", message.LastError).
+		Set("next_attempt_at", message.NextAttemptAt).
+		Set("updated_at", message.UpdatedAt).
+		Set("sent_at", message.SentAt).
+		Where(squirrel.Eq{"id": message.ID})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return mailer.OutboxMessageByIDNotFoundError{ID: message.ID}
+	}
+
+	return nil
+}
+
+func (repo *OutboxMessageRepo) GetByID(ctx context.Context, id string) (*mailer.OutboxMessage, error) {
+	q := squirrel.Select(outboxMessageColumns...).
+		From("outbox_messages").
+		Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	message, err := scanOutboxMessage(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, mailer.OutboxMessageByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan outbox message: %w", err)
+	}
+
+	return message, nil
+}
+
+func filterOutboxMessages(q squirrel.SelectBuilder, params mailer.ListOutboxMessagesParams) squirrel.SelectBuilder {
+	if params.Status != "" {
+		q = q.Where(squirrel.Eq{"status": params.Status})
+	}
+
+	return q
+}
+
+func (repo *OutboxMessageRepo) List(
+	ctx context.Context,
+	params mailer.ListOutboxMessagesParams,
+) ([]*mailer.OutboxMessage, error) {
+	q := squirrel.Select(outboxMessageColumns...).From("outbox_messages")
+
+	q = filterOutboxMessages(q, params).OrderBy("created_at DESC")
+
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
+	}
+
+	return repo.query(ctx, q)
+}
+
+func (repo *OutboxMessageRepo) Count(ctx context.Context, params mailer.ListOutboxMessagesParams) (int, error) {
+	q := filterOutboxMessages(squirrel.Select("COUNT(*)").From("outbox_messages"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count outbox messages: %w", err)
+	}
+
+	return count, nil
+}
+
+func (repo *OutboxMessageRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*mailer.OutboxMessage, error) {
+	q := squirrel.Select(outboxMessageColumns...).
+		From("outbox_messages").
+		Where(squirrel.Eq{"status": mailer.OutboxStatusQueued}).
+		Where(squirrel.LtOrEq{"next_attempt_at": now}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit))
+
+	return repo.query(ctx, q)
+}
+
+func (repo *OutboxMessageRepo) query(ctx context.Context, q squirrel.SelectBuilder) ([]*mailer.OutboxMessage, error) {
+	rows, err := q.RunWith(repo.DB).QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var messages []*mailer.OutboxMessage
+
+	for rows.Next() {
+		message, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan outbox message: %w", err)
+		}
+
+		messages = append(messages, message)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return messages, nil
+}
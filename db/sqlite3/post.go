@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/nasermirzaei89/fullstackgo/blog"
@@ -15,8 +16,46 @@ type PostRepo struct {
 	DB *sql.DB
 }
 
+var postSortColumns = map[string]string{
+	"title":      "title",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func filterPosts(q squirrel.SelectBuilder, params blog.ListPostsParams) squirrel.SelectBuilder {
+	if params.Trashed {
+		q = q.Where(squirrel.NotEq{"deleted_at": nil})
+	} else {
+		q = q.Where(squirrel.Eq{"deleted_at": nil})
+	}
+
+	if params.AuthorID != "" {
+		q = q.Where(squirrel.Eq{"author_id": params.AuthorID})
+	}
+
+	if params.Search != "" {
+		pattern := "%" + params.Search + "%"
+		q = q.Where(squirrel.Or{squirrel.Like{"title": pattern}, squirrel.Like{"slug": pattern}})
+	}
+
+	if !params.CreatedAfter.IsZero() {
+		q = q.Where(squirrel.GtOrEq{"created_at": params.CreatedAfter})
+	}
+
+	return q
+}
+
 func (repo *PostRepo) List(ctx context.Context, params blog.ListPostsParams) ([]*blog.Post, error) {
-	q := squirrel.Select("*").From("posts").OrderBy("created_at DESC")
+	q := filterPosts(squirrel.Select("*").From("posts"), params)
+
+	switch column, ok := postSortColumns[params.SortBy]; {
+	case ok:
+		q = q.OrderBy(column + sortDirection(params.SortDesc))
+	case params.Trashed:
+		q = q.OrderBy("deleted_at DESC")
+	default:
+		q = q.OrderBy("created_at DESC")
+	}
 
 	if params.Limit > 0 {
 		q = q.Limit(uint64(params.Limit))
@@ -59,8 +98,8 @@ func (repo *PostRepo) List(ctx context.Context, params blog.ListPostsParams) ([]
 	return posts, nil
 }
 
-func (repo *PostRepo) Count(ctx context.Context) (int, error) {
-	q := squirrel.Select("COUNT(*)").From("posts")
+func (repo *PostRepo) Count(ctx context.Context, params blog.ListPostsParams) (int, error) {
+	q := filterPosts(squirrel.Select("COUNT(*)").From("posts"), params)
 	q = q.RunWith(repo.DB)
 
 	var count int
@@ -74,7 +113,7 @@ func (repo *PostRepo) Count(ctx context.Context) (int, error) {
 }
 
 func (repo *PostRepo) GetBySlug(ctx context.Context, slug string) (*blog.Post, error) {
-	q := squirrel.Select("*").From("posts").Where(squirrel.Eq{"slug": slug})
+	q := squirrel.Select("*").From("posts").Where(squirrel.Eq{"slug": slug, "deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
@@ -91,7 +130,24 @@ func (repo *PostRepo) GetBySlug(ctx context.Context, slug string) (*blog.Post, e
 }
 
 func (repo *PostRepo) GetByID(ctx context.Context, id string) (*blog.Post, error) {
-	q := squirrel.Select("*").From("posts").Where(squirrel.Eq{"id": id})
+	q := squirrel.Select("*").From("posts").Where(squirrel.Eq{"id": id, "deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	post, err := scanPost(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, blog.PostByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan post: %w", err)
+	}
+
+	return post, nil
+}
+
+func (repo *PostRepo) GetTrashedByID(ctx context.Context, id string) (*blog.Post, error) {
+	q := squirrel.Select("*").From("posts").Where(squirrel.Eq{"id": id}).Where(squirrel.NotEq{"deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
@@ -108,6 +164,21 @@ func (repo *PostRepo) GetByID(ctx context.Context, id string) (*blog.Post, error
 }
 
 func (repo *PostRepo) SlugExists(ctx context.Context, slug string) (bool, error) {
+	q := squirrel.Select("COUNT(*)").From("posts").Where(squirrel.Eq{"slug": slug, "deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error on query slug existence: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (repo *PostRepo) SlugReserved(ctx context.Context, slug string) (bool, error) {
 	q := squirrel.Select("COUNT(*)").From("posts").Where(squirrel.Eq{"slug": slug})
 
 	q = q.RunWith(repo.DB)
@@ -134,6 +205,9 @@ func scanPost(rs squirrel.RowScanner) (*blog.Post, error) {
 		&post.AuthorID,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.DeletedAt,
+		&post.Format,
+		&post.Markdown,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error on scan row: %w", err)
@@ -144,8 +218,30 @@ func scanPost(rs squirrel.RowScanner) (*blog.Post, error) {
 
 func (repo *PostRepo) Create(ctx context.Context, post *blog.Post) error {
 	q := squirrel.Insert("posts").
-		Columns("id", "title", "slug", "excerpt", "content", "author_id", "created_at", "updated_at").
-		Values(post.ID, post.Title, post.Slug, post.Excerpt, post.Content, post.AuthorID, post.CreatedAt, post.UpdatedAt)
+		Columns(
+			"id",
+			"title",
+			"slug",
+			"excerpt",
+			"content",
+			"format",
+			"markdown",
+			"author_id",
+			"created_at",
+			"updated_at",
+		).
+		Values(
+			post.ID,
+			post.Title,
+			post.Slug,
+			post.Excerpt,
+			post.Content,
+			post.Format,
+			post.Markdown,
+			post.AuthorID,
+			post.CreatedAt,
+			post.UpdatedAt,
+		)
 
 	q = q.RunWith(repo.DB)
 
@@ -158,16 +254,62 @@ func (repo *PostRepo) Create(ctx context.Context, post *blog.Post) error {
 }
 
 func (repo *PostRepo) Update(ctx context.Context, post *blog.Post) error {
+	return updatePost(ctx, repo.DB, post)
+}
+
+func (repo *PostRepo) Rename(ctx context.Context, post *blog.Post, formerSlug string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("post_slug_history").
+		Where(squirrel.Eq{"slug": post.Slug, "post_id": post.ID}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete slug history: %w", err)
+	}
+
+	err = updatePost(ctx, tx, post)
+	if err != nil {
+		return err
+	}
+
+	_, err = squirrel.Insert("post_slug_history").
+		Columns("slug", "post_id", "created_at").
+		Values(formerSlug, post.ID, post.UpdatedAt).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec insert slug history: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func updatePost(ctx context.Context, runner squirrel.BaseRunner, post *blog.Post) error {
 	q := squirrel.Update("posts").
 		Set("title", post.Title).
 		Set("slug", post.Slug).
 		Set("excerpt", post.Excerpt).
 		Set("content", post.Content).
+		Set("format", post.Format).
+		Set("markdown", post.Markdown).
 		Set("author_id", post.AuthorID).
 		Set("updated_at", post.UpdatedAt).
-		Where(squirrel.Eq{"id": post.ID})
+		Where(squirrel.Eq{"id": post.ID, "deleted_at": nil})
 
-	q = q.RunWith(repo.DB)
+	q = q.RunWith(runner)
 
 	result, err := q.ExecContext(ctx)
 	if err != nil {
@@ -187,11 +329,84 @@ func (repo *PostRepo) Update(ctx context.Context, post *blog.Post) error {
 }
 
 func (repo *PostRepo) Delete(ctx context.Context, id string) error {
-	q := squirrel.Delete("posts").Where(squirrel.Eq{"id": id})
+	q := squirrel.Update("posts").
+		Set("deleted_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "deleted_at": nil})
 
 	q = q.RunWith(repo.DB)
 
 	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec update: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return blog.PostByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *PostRepo) Restore(ctx context.Context, id string) error {
+	q := squirrel.Update("posts").
+		Set("deleted_at", nil).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec update: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return blog.PostByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *PostRepo) Purge(ctx context.Context, id string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("comments").Where(squirrel.Eq{"post_id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete comments: %w", err)
+	}
+
+	_, err = squirrel.Delete("post_slug_history").Where(squirrel.Eq{"post_id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete slug history: %w", err)
+	}
+
+	_, err = squirrel.Delete("mentions").Where(squirrel.Eq{"post_id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	result, err := squirrel.Delete("posts").
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil}).
+		RunWith(tx).
+		ExecContext(ctx)
 	if err != nil {
 		return fmt.Errorf("error on exec delete: %w", err)
 	}
@@ -205,5 +420,67 @@ func (repo *PostRepo) Delete(ctx context.Context, id string) error {
 		return blog.PostByIDNotFoundError{ID: id}
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
 	return nil
 }
+
+func (repo *PostRepo) PurgeTrashed(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	trashedPostIDs := squirrel.Select("id").From("posts").Where(squirrel.Lt{"deleted_at": deletedBefore})
+
+	_, err = squirrel.Delete("comments").
+		Where(squirrel.Expr("post_id IN (?)", trashedPostIDs)).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete comments: %w", err)
+	}
+
+	_, err = squirrel.Delete("post_slug_history").
+		Where(squirrel.Expr("post_id IN (?)", trashedPostIDs)).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete slug history: %w", err)
+	}
+
+	_, err = squirrel.Delete("mentions").
+		Where(squirrel.Expr("post_id IN (?)", trashedPostIDs)).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	result, err := squirrel.Delete("posts").
+		Where(squirrel.Lt{"deleted_at": deletedBefore}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error on exec delete: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,58 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+type PostSlugHistoryRepo struct {
+	DB *sql.DB
+}
+
+func scanPostSlugHistory(rs squirrel.RowScanner) (*blog.PostSlugHistory, error) {
+	var history blog.PostSlugHistory
+
+	err := rs.Scan(&history.Slug, &history.PostID, &history.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &history, nil
+}
+
+func (repo *PostSlugHistoryRepo) GetBySlug(ctx context.Context, slug string) (*blog.PostSlugHistory, error) {
+	q := squirrel.Select("slug", "post_id", "created_at").From("post_slug_history").Where(squirrel.Eq{"slug": slug})
+
+	q = q.RunWith(repo.DB)
+
+	history, err := scanPostSlugHistory(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, blog.PostSlugHistoryBySlugNotFoundError{Slug: slug}
+		}
+
+		return nil, fmt.Errorf("error on scan post slug history: %w", err)
+	}
+
+	return history, nil
+}
+
+func (repo *PostSlugHistoryRepo) SlugExists(ctx context.Context, slug string) (bool, error) {
+	q := squirrel.Select("COUNT(*)").From("post_slug_history").Where(squirrel.Eq{"slug": slug})
+
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error on query slug existence: %w", err)
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,94 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+type SettingRepo struct {
+	DB *sql.DB
+}
+
+func scanSetting(rs squirrel.RowScanner) (*settings.Setting, error) {
+	var setting settings.Setting
+
+	err := rs.Scan(&setting.Key, &setting.Value, &setting.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	return &setting, nil
+}
+
+func (repo *SettingRepo) List(ctx context.Context) ([]*settings.Setting, error) {
+	q := squirrel.Select("key", "value", "updated_at").From("settings")
+
+	q = q.RunWith(repo.DB)
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var list []*settings.Setting
+
+	for rows.Next() {
+		setting, err := scanSetting(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan setting: %w", err)
+		}
+
+		list = append(list, setting)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return list, nil
+}
+
+func (repo *SettingRepo) Upsert(ctx context.Context, list []*settings.Setting) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, setting := range list {
+		q := squirrel.Insert("settings").
+			Columns("key", "value", "updated_at").
+			Values(setting.Key, setting.Value, setting.UpdatedAt).
+			Suffix("ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at")
+
+		q = q.RunWith(tx)
+
+		_, err = q.ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error on exec query: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
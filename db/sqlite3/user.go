@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/nasermirzaei89/fullstackgo/auth"
@@ -15,8 +18,24 @@ type UserRepo struct {
 	DB *sql.DB
 }
 
+// joinUserLinks stores the links of a user one per line.
+func joinUserLinks(links []string) string {
+	return strings.Join(links, "\n")
+}
+
+func splitUserLinks(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
 func scanUser(rs squirrel.RowScanner) (*auth.User, error) {
-	var user auth.User
+	var (
+		user  auth.User
+		links string
+	)
 
 	err := rs.Scan(
 		&user.ID,
@@ -27,11 +46,18 @@ func scanUser(rs squirrel.RowScanner) (*auth.User, error) {
 		&user.AvatarURL,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.IsAdmin,
+		&user.DisabledAt,
+		&user.BannedUntil,
+		&user.Bio,
+		&links,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error on scan row: %w", err)
 	}
 
+	user.Links = splitUserLinks(links)
+
 	return &user, nil
 }
 
@@ -87,9 +113,14 @@ func (repo *UserRepo) GetByID(ctx context.Context, id string) (*auth.User, error
 	return user, nil
 }
 
-func (repo *UserRepo) List(ctx context.Context, params auth.ListUsersParams) ([]*auth.User, error) {
-	q := squirrel.Select("*").From("users")
+var userSortColumns = map[string]string{
+	"username":      "username",
+	"name":          "name",
+	"email_address": "email_address",
+	"created_at":    "created_at",
+}
 
+func filterUsers(q squirrel.SelectBuilder, params auth.ListUsersParams) squirrel.SelectBuilder {
 	if params.Username != "" {
 		q = q.Where(squirrel.Eq{"username": params.Username})
 	}
@@ -98,6 +129,48 @@ func (repo *UserRepo) List(ctx context.Context, params auth.ListUsersParams) ([]
 		q = q.Where(squirrel.Eq{"email_address": params.EmailAddress})
 	}
 
+	if params.Search != "" {
+		pattern := "%" + params.Search + "%"
+		q = q.Where(squirrel.Or{
+			squirrel.Like{"username": pattern},
+			squirrel.Like{"name": pattern},
+			squirrel.Like{"email_address": pattern},
+		})
+	}
+
+	if params.UsernamePrefix != "" {
+		// Compared with substr, since LIKE treats the underscores that usernames may have as wildcards.
+		q = q.Where(
+			"substr(username, 1, ?) = ?",
+			utf8.RuneCountInString(params.UsernamePrefix),
+			params.UsernamePrefix,
+		)
+	}
+
+	if !params.CreatedAfter.IsZero() {
+		q = q.Where(squirrel.GtOrEq{"created_at": params.CreatedAfter})
+	}
+
+	return q
+}
+
+func (repo *UserRepo) List(ctx context.Context, params auth.ListUsersParams) ([]*auth.User, error) {
+	q := filterUsers(squirrel.Select("*").From("users"), params)
+
+	if column, ok := userSortColumns[params.SortBy]; ok {
+		q = q.OrderBy(column + sortDirection(params.SortDesc))
+	} else {
+		q = q.OrderBy("created_at DESC")
+	}
+
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
+	}
+
 	q = q.RunWith(repo.DB)
 
 	rows, err := q.QueryContext(ctx)
@@ -131,6 +204,20 @@ func (repo *UserRepo) List(ctx context.Context, params auth.ListUsersParams) ([]
 	return users, nil
 }
 
+func (repo *UserRepo) Count(ctx context.Context, params auth.ListUsersParams) (int, error) {
+	q := filterUsers(squirrel.Select("COUNT(*)").From("users"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count users: %w", err)
+	}
+
+	return count, nil
+}
+
 func (repo *UserRepo) ExistsByUsername(ctx context.Context, username string) (bool, error) {
 	q := squirrel.Select("1").From("users").Where(squirrel.Eq{"username": username})
 
@@ -174,8 +261,30 @@ func (repo *UserRepo) ExistsByEmailAddress(
 
 func (repo *UserRepo) Create(ctx context.Context, user *auth.User) error {
 	q := squirrel.Insert("users").
-		Columns("id", "username", "email_address", "password_hash", "name", "avatar_url", "created_at", "updated_at").
-		Values(user.ID, user.Username, user.EmailAddress, user.PasswordHash, user.Name, user.AvatarURL, user.CreatedAt, user.UpdatedAt)
+		Columns(
+			"id",
+			"username",
+			"email_address",
+			"password_hash",
+			"name",
+			"avatar_url",
+			"bio",
+			"links",
+			"created_at",
+			"updated_at",
+		).
+		Values(
+			user.ID,
+			user.Username,
+			user.EmailAddress,
+			user.PasswordHash,
+			user.Name,
+			user.AvatarURL,
+			user.Bio,
+			joinUserLinks(user.Links),
+			user.CreatedAt,
+			user.UpdatedAt,
+		)
 
 	q = q.RunWith(repo.DB)
 
@@ -194,6 +303,8 @@ func (repo *UserRepo) Update(ctx context.Context, user *auth.User) error {
 		Set("password_hash", user.PasswordHash).
 		Set("name", user.Name).
 		Set("avatar_url", user.AvatarURL).
+		Set("bio", user.Bio).
+		Set("links", joinUserLinks(user.Links)).
 		Set("updated_at", user.UpdatedAt).
 		Where(squirrel.Eq{"id": user.ID})
 
@@ -206,3 +317,212 @@ func (repo *UserRepo) Update(ctx context.Context, user *auth.User) error {
 
 	return nil
 }
+
+func (repo *UserRepo) SetDisabledAt(ctx context.Context, id string, disabledAt *time.Time) error {
+	q := squirrel.Update("users").Set("disabled_at", disabledAt).Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return auth.UserByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *UserRepo) SetBannedUntil(ctx context.Context, id string, bannedUntil *time.Time) error {
+	q := squirrel.Update("users").Set("banned_until", bannedUntil).Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return auth.UserByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *UserRepo) SetAdmin(ctx context.Context, id string, isAdmin bool) error {
+	q := squirrel.Update("users").Set("is_admin", isAdmin).Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return auth.UserByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *UserRepo) Delete(ctx context.Context, id string, content auth.AuthoredContent) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	switch content {
+	case auth.AuthoredContentAnonymize:
+		err = reassignUserContent(ctx, tx, id, auth.DeletedUserID)
+	case auth.AuthoredContentDelete:
+		err = deleteUserContent(ctx, tx, id)
+	default:
+		err = auth.InvalidAuthoredContentError{Content: content}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Mentions name the user by username, which someone else can register once the user is gone.
+	_, err = squirrel.Delete("mentions").
+		Where(squirrel.Expr("username = (?)", squirrel.Select("username").From("users").Where(squirrel.Eq{"id": id}))).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	for _, table := range []string{
+		"password_reset_tokens",
+		"user_logins",
+		"data_exports",
+		"newsletter_subscriptions",
+		"notification_preferences",
+		"notifications",
+		"avatars",
+	} {
+		_, err = squirrel.Delete(table).Where(squirrel.Eq{"user_id": id}).RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error on exec delete %s: %w", table, err)
+		}
+	}
+
+	// Notifications of others name the user in their text.
+	_, err = squirrel.Delete("notifications").Where(squirrel.Eq{"actor_id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete notifications: %w", err)
+	}
+
+	result, err := squirrel.Delete("users").Where(squirrel.Eq{"id": id}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return auth.UserByIDNotFoundError{ID: id}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func reassignUserContent(ctx context.Context, tx *sql.Tx, fromUserID, toUserID string) error {
+	_, err := squirrel.Update("posts").
+		Set("author_id", toUserID).
+		Where(squirrel.Eq{"author_id": fromUserID}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec reassign posts: %w", err)
+	}
+
+	_, err = squirrel.Update("comments").
+		Set("user_id", toUserID).
+		Where(squirrel.Eq{"user_id": fromUserID}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec reassign comments: %w", err)
+	}
+
+	return nil
+}
+
+// deleteUserContent deletes the posts of a user and everything that references them, as well as the comments of the
+// user on other posts and the mentions in all of them.
+func deleteUserContent(ctx context.Context, tx *sql.Tx, userID string) error {
+	userPostIDs := squirrel.Select("id").From("posts").Where(squirrel.Eq{"author_id": userID})
+
+	_, err := squirrel.Delete("mentions").
+		Where(squirrel.Or{
+			squirrel.Expr("post_id IN (?)", userPostIDs),
+			squirrel.Expr(
+				"comment_id IN (?)",
+				squirrel.Select("id").From("comments").Where(squirrel.Eq{"user_id": userID}),
+			),
+		}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete mentions: %w", err)
+	}
+
+	_, err = squirrel.Delete("comments").
+		Where(squirrel.Or{
+			squirrel.Expr("post_id IN (?)", userPostIDs),
+			squirrel.Eq{"user_id": userID},
+		}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete comments: %w", err)
+	}
+
+	_, err = squirrel.Delete("post_slug_history").
+		Where(squirrel.Expr("post_id IN (?)", userPostIDs)).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete slug history: %w", err)
+	}
+
+	_, err = squirrel.Delete("posts").Where(squirrel.Eq{"author_id": userID}).RunWith(tx).ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete posts: %w", err)
+	}
+
+	return nil
+}
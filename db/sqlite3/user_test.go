@@ -0,0 +1,151 @@
+package sqlite3_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+)
+
+const helloWorldPostID = "dfa0a426-4968-4b5f-9df2-078c30354bd1"
+
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return db
+}
+
+func createUser(t *testing.T, repo *sqlite3.UserRepo, username string) *auth.User {
+	t.Helper()
+
+	user := &auth.User{
+		ID:           username + "-id",
+		Username:     username,
+		EmailAddress: username + "@example.com",
+		Name:         username,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	err := repo.Create(t.Context(), user)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	return user
+}
+
+func mentionedUsernames(t *testing.T, repo *sqlite3.MentionRepo, postID, commentID string) []string {
+	t.Helper()
+
+	mentions, err := repo.List(t.Context(), postID, commentID)
+	if err != nil {
+		t.Fatalf("failed to list mentions: %v", err)
+	}
+
+	usernames := make([]string, 0, len(mentions))
+	for _, mention := range mentions {
+		usernames = append(usernames, mention.Username)
+	}
+
+	return usernames
+}
+
+func TestDeletedUserPlaceholder(t *testing.T) {
+	userRepo := &sqlite3.UserRepo{DB: newDB(t)}
+
+	placeholder, err := userRepo.GetByID(t.Context(), auth.DeletedUserID)
+	if err != nil {
+		t.Fatalf("failed to get the deleted user placeholder: %v", err)
+	}
+
+	if auth.ValidateUsername(placeholder.Username) == nil {
+		t.Errorf("expected the username of the placeholder %q not to be one registration accepts", placeholder.Username)
+	}
+}
+
+func TestDeleteUserRemovesMentions(t *testing.T) {
+	for _, content := range []auth.AuthoredContent{auth.AuthoredContentAnonymize, auth.AuthoredContentDelete} {
+		t.Run(string(content), func(t *testing.T) {
+			db := newDB(t)
+			userRepo := &sqlite3.UserRepo{DB: db}
+			commentRepo := &sqlite3.CommentRepo{DB: db}
+			mentionRepo := &sqlite3.MentionRepo{DB: db}
+
+			leaver := createUser(t, userRepo, "leaver")
+			createUser(t, userRepo, "stayer")
+
+			comment := &blog.Comment{
+				ID:        "comment-id",
+				PostID:    helloWorldPostID,
+				UserID:    leaver.ID,
+				Content:   "<p>Hi @stayer</p>",
+				Status:    blog.CommentStatusApproved,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+
+			err := commentRepo.Create(t.Context(), comment)
+			if err != nil {
+				t.Fatalf("failed to create comment: %v", err)
+			}
+
+			for _, mention := range []struct {
+				commentID string
+				usernames []string
+			}{
+				{"", []string{"leaver", "stayer"}},
+				{comment.ID, []string{"stayer"}},
+			} {
+				err = mentionRepo.Replace(
+					t.Context(),
+					helloWorldPostID,
+					mention.commentID,
+					mention.usernames,
+					time.Now(),
+				)
+				if err != nil {
+					t.Fatalf("failed to replace mentions: %v", err)
+				}
+			}
+
+			err = userRepo.Delete(t.Context(), leaver.ID, content)
+			if err != nil {
+				t.Fatalf("failed to delete user: %v", err)
+			}
+
+			if got := mentionedUsernames(t, mentionRepo, helloWorldPostID, ""); len(got) != 1 || got[0] != "stayer" {
+				t.Errorf("expected only the mention of stayer to be kept on the post, got %v", got)
+			}
+
+			got := mentionedUsernames(t, mentionRepo, helloWorldPostID, comment.ID)
+
+			// The comment is kept under the placeholder when anonymizing, and so are its mentions.
+			if content == auth.AuthoredContentAnonymize && len(got) != 1 {
+				t.Errorf("expected the mentions of the anonymized comment to be kept, got %v", got)
+			}
+
+			if content == auth.AuthoredContentDelete && len(got) != 0 {
+				t.Errorf("expected the mentions of the deleted comment to be deleted, got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
+)
+
+type WebhookSubscriptionRepo struct {
+	DB *sql.DB
+}
+
+func joinWebhookEventTypes(eventTypes []webhook.EventType) string {
+	names := make([]string, 0, len(eventTypes))
+
+	for _, eventType := range eventTypes {
+		names = append(names, string(eventType))
+	}
+
+	return strings.Join(names, ",")
+}
+
+func splitWebhookEventTypes(s string) []webhook.EventType {
+	var eventTypes []webhook.EventType
+
+	for name := range strings.SplitSeq(s, ",") {
+		if name != "" {
+			eventTypes = append(eventTypes, webhook.EventType(name))
+		}
+	}
+
+	return eventTypes
+}
+
+func scanWebhookSubscription(rs squirrel.RowScanner) (*webhook.Subscription, error) {
+	var (
+		sub        webhook.Subscription
+		eventTypes string
+	)
+
+	err := rs.Scan(&sub.ID, &sub.URL, &sub.Secret, &eventTypes, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	sub.EventTypes = splitWebhookEventTypes(eventTypes)
+
+	return &sub, nil
+}
+
+var webhookSubscriptionColumns = []string{"id", "url", "secret", "event_types", "active", "created_at", "updated_at"}
+
+func (repo *WebhookSubscriptionRepo) Create(ctx context.Context, sub *webhook.Subscription) error {
+	q := squirrel.Insert("webhook_subscriptions").
+		Columns(webhookSubscriptionColumns...).
+		Values(
+			sub.ID,
+			sub.URL,
+			sub.Secret,
+			joinWebhookEventTypes(sub.EventTypes),
+			sub.Active,
+			sub.CreatedAt,
+			sub.UpdatedAt,
+		).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *WebhookSubscriptionRepo) Update(ctx context.Context, sub *webhook.Subscription) error {
+	q := squirrel.Update("webhook_subscriptions").
+		Set("url", sub.URL).
+		Set("secret", sub.Secret).
+		Set("event_types", joinWebhookEventTypes(sub.EventTypes)).
+		Set("active", sub.Active).
+		Set("updated_at", sub.UpdatedAt).
+		Where(squirrel.Eq{"id": sub.ID})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return webhook.SubscriptionByIDNotFoundError{ID: sub.ID}
+	}
+
+	return nil
+}
+
+func (repo *WebhookSubscriptionRepo) Delete(ctx context.Context, id string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = squirrel.Delete("webhook_deliveries").
+		Where(squirrel.Eq{"subscription_id": id}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on delete webhook deliveries: %w", err)
+	}
+
+	result, err := squirrel.Delete("webhook_subscriptions").
+		Where(squirrel.Eq{"id": id}).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec delete: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return webhook.SubscriptionByIDNotFoundError{ID: id}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *WebhookSubscriptionRepo) GetByID(ctx context.Context, id string) (*webhook.Subscription, error) {
+	q := squirrel.Select(webhookSubscriptionColumns...).
+		From("webhook_subscriptions").
+		Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	sub, err := scanWebhookSubscription(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webhook.SubscriptionByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan webhook subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (repo *WebhookSubscriptionRepo) List(ctx context.Context) ([]*webhook.Subscription, error) {
+	q := squirrel.Select(webhookSubscriptionColumns...).
+		From("webhook_subscriptions").
+		OrderBy("created_at")
+
+	q = q.RunWith(repo.DB)
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var subs []*webhook.Subscription
+
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan webhook subscription: %w", err)
+		}
+
+		subs = append(subs, sub)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return subs, nil
+}
+
+type WebhookDeliveryRepo struct {
+	DB *sql.DB
+}
+
+var webhookDeliveryColumns = []string{
+	"id",
+	"subscription_id",
+	"event_type",
+	"payload",
+	"status",
+	"attempts",
+	"last_status_code",
+	"last_error",
+	"next_attempt_at",
+	"created_at",
+	"delivered_at",
+}
+
+func scanWebhookDelivery(rs squirrel.RowScanner) (*webhook.Delivery, error) {
+	var (
+		delivery webhook.Delivery
+		payload  string
+	)
+
+	err := rs.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on scan row: %w", err)
+	}
+
+	delivery.Payload = []byte(payload)
+
+	return &delivery, nil
+}
+
+func (repo *WebhookDeliveryRepo) Create(ctx context.Context, delivery *webhook.Delivery) error {
+	q := squirrel.Insert("webhook_deliveries").
+		Columns(webhookDeliveryColumns...).
+		Values(
+			delivery.ID,
+			delivery.SubscriptionID,
+			delivery.EventType,
+			string(delivery.Payload),
+			delivery.Status,
+			delivery.Attempts,
+			delivery.LastStatusCode,
+			delivery.LastError,
+			delivery.NextAttemptAt,
+			delivery.CreatedAt,
+			delivery.DeliveredAt,
+		).
+		RunWith(repo.DB)
+
+	_, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on create webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *WebhookDeliveryRepo) Update(ctx context.Context, delivery *webhook.Delivery) error {
+	q := squirrel.Update("webhook_deliveries").
+		Set("status", delivery.Status).
+		Set("attempts", delivery.Attempts).
+		Set("last_status_code", delivery.LastStatusCode).
+		Set("last_error", delivery.LastError).
+		Set("next_attempt_at", delivery.NextAttemptAt).
+		Set("delivered_at", delivery.DeliveredAt).
+		Where(squirrel.Eq{"id": delivery.ID})
+
+	q = q.RunWith(repo.DB)
+
+	result, err := q.ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error on exec query: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return webhook.DeliveryByIDNotFoundError{ID: delivery.ID}
+	}
+
+	return nil
+}
+
+func (repo *WebhookDeliveryRepo) GetByID(ctx context.Context, id string) (*webhook.Delivery, error) {
+	q := squirrel.Select(webhookDeliveryColumns...).
+		From("webhook_deliveries").
+		Where(squirrel.Eq{"id": id})
+
+	q = q.RunWith(repo.DB)
+
+	delivery, err := scanWebhookDelivery(q.QueryRowContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webhook.DeliveryByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on scan webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+func filterWebhookDeliveries(q squirrel.SelectBuilder, params webhook.ListDeliveriesParams) squirrel.SelectBuilder {
+	if params.SubscriptionID != "" {
+		q = q.Where(squirrel.Eq{"subscription_id": params.SubscriptionID})
+	}
+
+	return q
+}
+
+func (repo *WebhookDeliveryRepo) List(
+	ctx context.Context,
+	params webhook.ListDeliveriesParams,
+) ([]*webhook.Delivery, error) {
+	q := squirrel.Select(webhookDeliveryColumns...).From("webhook_deliveries")
+
+	q = filterWebhookDeliveries(q, params).OrderBy("created_at DESC")
+
+	if params.Limit > 0 {
+		q = q.Limit(uint64(params.Limit))
+	}
+
+	if params.Offset > 0 {
+		q = q.Offset(uint64(params.Offset))
+	}
+
+	return repo.query(ctx, q)
+}
+
+func (repo *WebhookDeliveryRepo) Count(ctx context.Context, params webhook.ListDeliveriesParams) (int, error) {
+	q := filterWebhookDeliveries(squirrel.Select("COUNT(*)").From("webhook_deliveries"), params)
+	q = q.RunWith(repo.DB)
+
+	var count int
+
+	err := q.QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error on count webhook deliveries: %w", err)
+	}
+
+	return count, nil
+}
+
+func (repo *WebhookDeliveryRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*webhook.Delivery, error) {
+	q := squirrel.Select(webhookDeliveryColumns...).
+		From("webhook_deliveries").
+		Where(squirrel.Eq{"status": webhook.DeliveryStatusPending}).
+		Where(squirrel.LtOrEq{"next_attempt_at": now}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit))
+
+	return repo.query(ctx, q)
+}
+
+func (repo *WebhookDeliveryRepo) query(ctx context.Context, q squirrel.SelectBuilder) ([]*webhook.Delivery, error) {
+	rows, err := q.RunWith(repo.DB).QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on query db: %w", err)
+	}
+
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "error on close rows", "error", err)
+		}
+	}()
+
+	var deliveries []*webhook.Delivery
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan webhook delivery: %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on rows: %w", err)
+	}
+
+	return deliveries, nil
+}
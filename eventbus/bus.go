@@ -0,0 +1,191 @@
+package eventbus
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// Event is something that happened in the system. Name identifies the type of the event and is the same for every
+// value of the type.
+type Event interface {
+	EventName() string
+}
+
+// Publisher is how services announce events without knowing who listens to them.
+type Publisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// Handler reacts to an event. Errors are logged by the bus.
+type Handler func(ctx context.Context, event Event) error
+
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 256
+)
+
+type subscriber struct {
+	handler Handler
+	async   bool
+}
+
+type delivery struct {
+	ctx     context.Context //nolint:containedctx
+	event   Event
+	handler Handler
+}
+
+// Bus delivers published events to the subscribers of their name. Synchronous subscribers run in Publish, one after
+// the other. Asynchronous subscribers run on a bounded pool of workers, and Publish blocks while the queue is full. A
+// subscriber that fails or panics is logged and does not affect the publisher or the other subscribers.
+type Bus struct {
+	// Workers is the number of goroutines that run asynchronous subscribers.
+	Workers int
+	// QueueSize is the number of asynchronous deliveries that can wait for a worker.
+	QueueSize int
+
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+	queue       chan delivery
+	closed      bool
+	done        chan struct{}
+	sending     sync.WaitGroup
+	startOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// Subscribe runs handler in Publish for every event with the given name.
+func (bus *Bus) Subscribe(name string, handler Handler) {
+	bus.subscribe(name, subscriber{handler: handler})
+}
+
+// SubscribeAsync runs handler on a worker for every event with the given name.
+func (bus *Bus) SubscribeAsync(name string, handler Handler) {
+	bus.subscribe(name, subscriber{handler: handler, async: true})
+}
+
+func (bus *Bus) subscribe(name string, sub subscriber) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.subscribers == nil {
+		bus.subscribers = make(map[string][]subscriber)
+	}
+
+	bus.subscribers[name] = append(bus.subscribers[name], sub)
+}
+
+// Publish hands event to its subscribers. Asynchronous subscribers get a context that is not canceled with ctx, so
+// they can finish after the request that published the event.
+func (bus *Bus) Publish(ctx context.Context, event Event) {
+	bus.mu.RLock()
+	subscribers := bus.subscribers[event.EventName()]
+	bus.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		if !sub.async {
+			dispatch(ctx, event, sub.handler)
+
+			continue
+		}
+
+		bus.enqueue(delivery{ctx: context.WithoutCancel(ctx), event: event, handler: sub.handler})
+	}
+}
+
+// enqueue does not hold the lock while it waits for room in the queue, so a full queue cannot block Close, and
+// subscribers that publish events cannot block each other.
+func (bus *Bus) enqueue(d delivery) {
+	bus.startOnce.Do(bus.start)
+
+	bus.mu.RLock()
+
+	if bus.closed {
+		bus.mu.RUnlock()
+		slog.ErrorContext(d.ctx, "event dropped after the event bus was closed", "event", d.event.EventName())
+
+		return
+	}
+
+	bus.sending.Add(1)
+	bus.mu.RUnlock()
+
+	defer bus.sending.Done()
+
+	select {
+	case bus.queue <- d:
+	case <-bus.done:
+		slog.ErrorContext(d.ctx, "event dropped after the event bus was closed", "event", d.event.EventName())
+	}
+}
+
+func (bus *Bus) start() {
+	workers := bus.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	queueSize := bus.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	bus.queue = make(chan delivery, queueSize)
+	bus.done = make(chan struct{})
+
+	for range workers {
+		bus.wg.Go(func() {
+			for d := range bus.queue {
+				dispatch(d.ctx, d.event, d.handler)
+			}
+		})
+	}
+}
+
+// Close stops accepting asynchronous deliveries and waits for the queued ones to finish. Publishers that are waiting
+// for room in the queue drop their events.
+func (bus *Bus) Close() {
+	bus.startOnce.Do(bus.start)
+
+	bus.mu.Lock()
+
+	if bus.closed {
+		bus.mu.Unlock()
+
+		return
+	}
+
+	bus.closed = true
+	close(bus.done)
+	bus.mu.Unlock()
+
+	// No one sends to the queue after the publishers that got past the closed check are done.
+	bus.sending.Wait()
+	close(bus.queue)
+
+	bus.wg.Wait()
+}
+
+func dispatch(ctx context.Context, event Event, handler Handler) {
+	defer func() {
+		if err := recover(); err != nil {
+			slog.ErrorContext(
+				ctx,
+				"recovered from panic in event subscriber",
+				"event",
+				event.EventName(),
+				"error",
+				err,
+				"stack",
+				string(debug.Stack()),
+			)
+		}
+	}()
+
+	err := handler(ctx, event)
+	if err != nil {
+		slog.ErrorContext(ctx, "event subscriber failed", "event", event.EventName(), "error", err)
+	}
+}
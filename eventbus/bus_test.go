@@ -0,0 +1,215 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+)
+
+type testEvent struct {
+	N int
+}
+
+func (testEvent) EventName() string {
+	return "test.event"
+}
+
+type otherEvent struct{}
+
+func (otherEvent) EventName() string {
+	return "test.other"
+}
+
+func TestSyncDelivery(t *testing.T) {
+	bus := &eventbus.Bus{}
+	defer bus.Close()
+
+	var got []int
+
+	eventbus.On(bus, func(_ context.Context, event testEvent) error {
+		got = append(got, event.N)
+
+		return nil
+	})
+
+	eventbus.On(bus, func(_ context.Context, event testEvent) error {
+		got = append(got, event.N*10)
+
+		return errors.New("failed")
+	})
+
+	eventbus.On(bus, func(_ context.Context, _ otherEvent) error {
+		t.Error("expected other subscribers not to get the event")
+
+		return nil
+	})
+
+	bus.Publish(t.Context(), testEvent{N: 1})
+	bus.Publish(t.Context(), testEvent{N: 2})
+
+	// Synchronous subscribers have run, in order, when Publish returns, and a failing one does not stop the others.
+	if len(got) != 4 || got[0] != 1 || got[1] != 10 || got[2] != 2 || got[3] != 20 {
+		t.Errorf("expected [1 10 2 20], got %v", got)
+	}
+}
+
+func TestAsyncDelivery(t *testing.T) {
+	bus := &eventbus.Bus{Workers: 2}
+
+	var (
+		mu  sync.Mutex
+		sum int
+	)
+
+	eventbus.OnAsync(bus, func(_ context.Context, event testEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sum += event.N
+
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	for n := 1; n <= 10; n++ {
+		bus.Publish(ctx, testEvent{N: n})
+	}
+
+	// Asynchronous subscribers outlive the context of the publisher.
+	cancel()
+
+	bus.Close()
+
+	if sum != 55 {
+		t.Errorf("expected 55, got %d", sum)
+	}
+}
+
+func TestPanickingSubscriber(t *testing.T) {
+	bus := &eventbus.Bus{Workers: 1}
+
+	var calls atomic.Int32
+
+	for _, subscribe := range []func(*eventbus.Bus, func(context.Context, testEvent) error){
+		eventbus.On[testEvent],
+		eventbus.OnAsync[testEvent],
+	} {
+		subscribe(bus, func(context.Context, testEvent) error {
+			panic("boom")
+		})
+
+		subscribe(bus, func(context.Context, testEvent) error {
+			calls.Add(1)
+
+			return nil
+		})
+	}
+
+	bus.Publish(t.Context(), testEvent{N: 1})
+	bus.Publish(t.Context(), testEvent{N: 2})
+
+	bus.Close()
+
+	if got := calls.Load(); got != 4 {
+		t.Errorf("expected the other subscribers to run 4 times, got %d", got)
+	}
+}
+
+func TestCloseDrainsQueue(t *testing.T) {
+	bus := &eventbus.Bus{Workers: 1, QueueSize: 10}
+
+	release := make(chan struct{})
+
+	var handled atomic.Int32
+
+	eventbus.OnAsync(bus, func(context.Context, testEvent) error {
+		<-release
+		handled.Add(1)
+
+		return nil
+	})
+
+	for n := range 5 {
+		bus.Publish(t.Context(), testEvent{N: n})
+	}
+
+	closed := make(chan struct{})
+
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for queued events")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-closed
+
+	if got := handled.Load(); got != 5 {
+		t.Errorf("expected 5 events handled, got %d", got)
+	}
+
+	// Events published after Close are dropped.
+	bus.Publish(t.Context(), testEvent{N: 6})
+
+	if got := handled.Load(); got != 5 {
+		t.Errorf("expected events after Close to be dropped, got %d handled", got)
+	}
+}
+
+// Close does not deadlock with a publisher that waits for room in a full queue while a subscriber publishes too.
+func TestCloseWithFullQueue(t *testing.T) {
+	bus := &eventbus.Bus{Workers: 1, QueueSize: 1}
+
+	release := make(chan struct{})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event testEvent) error {
+		<-release
+
+		if event.N < 100 {
+			bus.Publish(ctx, testEvent{N: event.N + 100})
+		}
+
+		return nil
+	})
+
+	// The worker takes the first event and the second fills the queue.
+	bus.Publish(t.Context(), testEvent{N: 1})
+	bus.Publish(t.Context(), testEvent{N: 2})
+
+	published := make(chan struct{})
+
+	go func() {
+		bus.Publish(t.Context(), testEvent{N: 3})
+		close(published)
+	}()
+
+	closed := make(chan struct{})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		bus.Close()
+		close(closed)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for _, done := range []chan struct{}{published, closed} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected Publish and Close to return")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+)
+
+// On subscribes a synchronous handler to the events of type E.
+func On[E Event](bus *Bus, handle func(ctx context.Context, event E) error) {
+	var zero E
+
+	bus.Subscribe(zero.EventName(), typed(handle))
+}
+
+// OnAsync subscribes an asynchronous handler to the events of type E.
+func OnAsync[E Event](bus *Bus, handle func(ctx context.Context, event E) error) {
+	var zero E
+
+	bus.SubscribeAsync(zero.EventName(), typed(handle))
+}
+
+func typed[E Event](handle func(ctx context.Context, event E) error) Handler {
+	return func(ctx context.Context, event Event) error {
+		e, ok := event.(E)
+		if !ok {
+			return fmt.Errorf("unexpected event type %T for %s", event, event.EventName())
+		}
+
+		return handle(ctx, e)
+	}
+}
@@ -0,0 +1,70 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	// StatusDead marks a job that ran out of attempts or has no handler. It stays in the table for inspection.
+	StatusDead Status = "dead"
+)
+
+// Job is a unit of background work. Payload is the JSON encoded input of the handler of Type.
+type Job struct {
+	ID          string
+	Type        string
+	Payload     []byte
+	Status      Status
+	Attempts    int
+	LastError   string
+	RunAt       time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// DecodePayload unmarshals the payload of the job into v.
+func (job *Job) DecodePayload(v any) error {
+	err := json.Unmarshal(job.Payload, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload of %s job: %w", job.Type, err)
+	}
+
+	return nil
+}
+
+var ErrNoJobDue = errors.New("no job is due")
+
+type JobRepository interface {
+	Create(ctx context.Context, job *Job) (err error)
+	// Claim marks the pending job that has been due the longest as running, counts the attempt and returns it. It
+	// returns ErrNoJobDue when no job is due at now.
+	Claim(ctx context.Context, now time.Time) (job *Job, err error)
+	// Complete marks a job as succeeded and drops its payload, which can hold secrets such as password reset links.
+	Complete(ctx context.Context, id string, completedAt time.Time) (err error)
+	// Reschedule puts a failed job back in the queue to run again at runAt.
+	Reschedule(ctx context.Context, id string, runAt time.Time, lastError string) (err error)
+	// Bury moves a job to the dead letters.
+	Bury(ctx context.Context, id string, lastError string) (err error)
+	// RequeueRunning puts jobs that were left running, by a crash for example, back in the queue.
+	RequeueRunning(ctx context.Context) (count int, err error)
+	// Purge deletes succeeded and dead jobs last updated before finishedBefore.
+	Purge(ctx context.Context, finishedBefore time.Time) (count int, err error)
+}
+
+type JobByIDNotFoundError struct {
+	ID string
+}
+
+func (err JobByIDNotFoundError) Error() string {
+	return fmt.Sprintf("job with ID %q not found", err.ID)
+}
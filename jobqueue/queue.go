@@ -0,0 +1,300 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultWorkers      = 2
+	DefaultPollInterval = 5 * time.Second
+	DefaultMaxAttempts  = 5
+	DefaultBackoff      = 30 * time.Second
+	DefaultRetention    = 7 * 24 * time.Hour
+)
+
+// Handler runs a job. A returned error or a panic fails the attempt.
+type Handler func(ctx context.Context, job *Job) error
+
+// Queue runs jobs stored in JobRepo on a pool of workers. Failed jobs are retried with exponential backoff until they
+// run out of attempts and are buried.
+type Queue struct {
+	JobRepo JobRepository
+	// Workers is the number of jobs that run at the same time.
+	Workers int
+	// PollInterval is how often idle workers look for due jobs. Enqueued jobs wake a worker right away.
+	PollInterval time.Duration
+	// MaxAttempts is the number of attempts before a job is buried.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles on every retry.
+	Backoff time.Duration
+	// Retention is how long succeeded and dead jobs are kept before Purge deletes them.
+	Retention time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	wake     chan struct{}
+	wakeOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// Register sets the handler of a job type. Handlers must be registered before Start.
+func (queue *Queue) Register(jobType string, handler Handler) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.handlers == nil {
+		queue.handlers = make(map[string]Handler)
+	}
+
+	queue.handlers[jobType] = handler
+}
+
+// Enqueue adds a job that runs as soon as a worker is free.
+func (queue *Queue) Enqueue(ctx context.Context, jobType string, payload any) (*Job, error) {
+	return queue.EnqueueAt(ctx, jobType, payload, time.Now())
+}
+
+// EnqueueAt adds a job that runs at or after runAt.
+func (queue *Queue) EnqueueAt(ctx context.Context, jobType string, payload any, runAt time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	now := time.Now()
+
+	job := &Job{
+		ID:        uuid.NewString(),
+		Type:      jobType,
+		Payload:   data,
+		Status:    StatusPending,
+		RunAt:     runAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = queue.JobRepo.Create(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	if !runAt.After(now) {
+		select {
+		case queue.wakeChan() <- struct{}{}:
+		default:
+		}
+	}
+
+	return job, nil
+}
+
+func (queue *Queue) wakeChan() chan struct{} {
+	queue.wakeOnce.Do(func() {
+		queue.wake = make(chan struct{}, 1)
+	})
+
+	return queue.wake
+}
+
+// Start puts jobs left running by a previous process back in the queue and starts the workers. Workers stop taking
+// new jobs when ctx is done; Wait blocks until the jobs they are running finish.
+func (queue *Queue) Start(ctx context.Context) error {
+	count, err := queue.JobRepo.RequeueRunning(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to requeue running jobs: %w", err)
+	}
+
+	if count > 0 {
+		slog.InfoContext(ctx, "interrupted jobs requeued", "count", count)
+	}
+
+	workers := queue.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	for range workers {
+		queue.wg.Go(func() {
+			queue.work(ctx)
+		})
+	}
+
+	return nil
+}
+
+// Wait blocks until the workers have stopped.
+func (queue *Queue) Wait() {
+	queue.wg.Wait()
+}
+
+// Purge deletes the jobs that finished longer than Retention ago.
+func (queue *Queue) Purge(ctx context.Context) error {
+	retention := queue.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	count, err := queue.JobRepo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return fmt.Errorf("failed to purge finished jobs: %w", err)
+	}
+
+	if count > 0 {
+		slog.InfoContext(ctx, "finished jobs purged", "count", count)
+	}
+
+	return nil
+}
+
+// RunPurger calls Purge on every tick until ctx is done.
+func (queue *Queue) RunPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := queue.Purge(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge finished jobs", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (queue *Queue) work(ctx context.Context) {
+	pollInterval := queue.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-queue.wakeChan():
+		}
+
+		// Keep going while there are due jobs, so a burst does not wait for the next poll.
+		for ctx.Err() == nil && queue.runNext(ctx) {
+		}
+
+		timer.Reset(pollInterval)
+	}
+}
+
+// runNext claims and runs one due job. It reports whether there was one.
+func (queue *Queue) runNext(ctx context.Context) bool {
+	job, err := queue.JobRepo.Claim(ctx, time.Now())
+	if err != nil {
+		if !errors.Is(err, ErrNoJobDue) {
+			slog.ErrorContext(ctx, "failed to claim job", "error", err)
+		}
+
+		return false
+	}
+
+	// A job that has started finishes even when the queue is shutting down.
+	ctx = context.WithoutCancel(ctx)
+
+	err = queue.run(ctx, job)
+	if err == nil {
+		err = queue.JobRepo.Complete(ctx, job.ID, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to complete job", "error", err, "jobId", job.ID)
+		}
+
+		return true
+	}
+
+	queue.fail(ctx, job, err)
+
+	return true
+}
+
+func (queue *Queue) run(ctx context.Context, job *Job) (err error) {
+	queue.mu.RLock()
+	handler, ok := queue.handlers[job.Type]
+	queue.mu.RUnlock()
+
+	if !ok {
+		return noHandlerError{Type: job.Type}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "recovered from panic in job", "jobId", job.ID, "stack", string(debug.Stack()))
+
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+type noHandlerError struct {
+	Type string
+}
+
+func (err noHandlerError) Error() string {
+	return fmt.Sprintf("no handler registered for job type %q", err.Type)
+}
+
+func (queue *Queue) fail(ctx context.Context, job *Job, jobErr error) {
+	maxAttempts := queue.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	if job.Attempts >= maxAttempts || errors.As(jobErr, &noHandlerError{}) {
+		slog.ErrorContext(
+			ctx,
+			"job buried",
+			"error",
+			jobErr,
+			"jobId",
+			job.ID,
+			"type",
+			job.Type,
+			"attempts",
+			job.Attempts,
+		)
+
+		err := queue.JobRepo.Bury(ctx, job.ID, jobErr.Error())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to bury job", "error", err, "jobId", job.ID)
+		}
+
+		return
+	}
+
+	backoff := queue.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	runAt := time.Now().Add(backoff << (job.Attempts - 1))
+
+	slog.WarnContext(ctx, "job failed", "error", jobErr, "jobId", job.ID, "type", job.Type, "retryAt", runAt)
+
+	err := queue.JobRepo.Reschedule(ctx, job.ID, runAt, jobErr.Error())
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to reschedule job", "error", err, "jobId", job.ID)
+	}
+}
@@ -0,0 +1,344 @@
+package jobqueue_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
+)
+
+const testJobType = "test.job"
+
+func newJobRepo(t *testing.T) *sqlite3.JobRepo {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return &sqlite3.JobRepo{DB: db}
+}
+
+// startQueue starts queue with a single worker and stops it when the test ends.
+func startQueue(t *testing.T, queue *jobqueue.Queue) {
+	t.Helper()
+
+	queue.Workers = 1
+	queue.PollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	t.Cleanup(func() {
+		cancel()
+		queue.Wait()
+	})
+
+	err := queue.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start queue: %v", err)
+	}
+}
+
+// getJob reads a job straight from the table, since the repository only hands out jobs it claims.
+func getJob(t *testing.T, repo *sqlite3.JobRepo, id string) *jobqueue.Job {
+	t.Helper()
+
+	job := jobqueue.Job{ID: id}
+
+	var payload string
+
+	err := repo.DB.QueryRowContext(
+		t.Context(),
+		"SELECT payload, status, attempts, last_error, run_at FROM jobs WHERE id = ?",
+		id,
+	).Scan(&payload, &job.Status, &job.Attempts, &job.LastError, &job.RunAt)
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+
+	job.Payload = []byte(payload)
+
+	return &job
+}
+
+// waitForJob waits until the job has the status and returns it.
+func waitForJob(t *testing.T, repo *sqlite3.JobRepo, id string, status jobqueue.Status) *jobqueue.Job {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		job := getJob(t, repo, id)
+		if job.Status == status {
+			return job
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not become %s", id, status)
+
+	return nil
+}
+
+func createJob(t *testing.T, repo *sqlite3.JobRepo, id string, status jobqueue.Status, runAt time.Time) {
+	t.Helper()
+
+	err := repo.Create(t.Context(), &jobqueue.Job{
+		ID:        id,
+		Type:      testJobType,
+		Payload:   []byte(`{}`),
+		Status:    status,
+		RunAt:     runAt,
+		CreatedAt: runAt,
+		UpdatedAt: runAt,
+	})
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	repo := newJobRepo(t)
+	now := time.Now()
+
+	createJob(t, repo, "newer", jobqueue.StatusPending, now.Add(-time.Minute))
+	createJob(t, repo, "older", jobqueue.StatusPending, now.Add(-time.Hour))
+	createJob(t, repo, "future", jobqueue.StatusPending, now.Add(time.Hour))
+	createJob(t, repo, "dead", jobqueue.StatusDead, now.Add(-2*time.Hour))
+
+	for _, want := range []string{"older", "newer"} {
+		job, err := repo.Claim(t.Context(), now)
+		if err != nil {
+			t.Fatalf("failed to claim job: %v", err)
+		}
+
+		if job.ID != want || job.Status != jobqueue.StatusRunning || job.Attempts != 1 {
+			t.Errorf("expected %s running with 1 attempt, got %s %s with %d", want, job.ID, job.Status, job.Attempts)
+		}
+	}
+
+	_, err := repo.Claim(t.Context(), now)
+	if !errors.Is(err, jobqueue.ErrNoJobDue) {
+		t.Errorf("expected no job due, got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo}
+
+	var got string
+
+	queue.Register(testJobType, func(_ context.Context, job *jobqueue.Job) error {
+		return job.DecodePayload(&got)
+	})
+
+	startQueue(t, queue)
+
+	job, err := queue.Enqueue(t.Context(), testJobType, "secret link")
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	job = waitForJob(t, repo, job.ID, jobqueue.StatusSucceeded)
+
+	if got != "secret link" {
+		t.Errorf("expected the handler to get the payload, got %q", got)
+	}
+
+	if len(job.Payload) != 0 {
+		t.Errorf("expected the payload of a succeeded job to be dropped, got %q", job.Payload)
+	}
+}
+
+func TestRescheduleWithBackoff(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo, Backoff: time.Hour}
+
+	queue.Register(testJobType, func(context.Context, *jobqueue.Job) error {
+		return errors.New("boom")
+	})
+
+	startQueue(t, queue)
+
+	before := time.Now()
+
+	job, err := queue.Enqueue(t.Context(), testJobType, nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		job = getJob(t, repo, job.ID)
+		if job.Attempts == 1 && job.Status == jobqueue.StatusPending {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if job.Attempts != 1 || job.Status != jobqueue.StatusPending || job.LastError != "boom" {
+		t.Fatalf(
+			"expected a pending job with 1 failed attempt, got %s with %d: %q",
+			job.Status,
+			job.Attempts,
+			job.LastError,
+		)
+	}
+
+	if job.RunAt.Before(before.Add(time.Hour)) || job.RunAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected the retry an hour after the failure, got %v", job.RunAt)
+	}
+}
+
+func TestBuryAfterMaxAttempts(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo, MaxAttempts: 3, Backoff: time.Millisecond}
+
+	var calls atomic.Int32
+
+	queue.Register(testJobType, func(context.Context, *jobqueue.Job) error {
+		calls.Add(1)
+
+		return errors.New("boom")
+	})
+
+	startQueue(t, queue)
+
+	job, err := queue.Enqueue(t.Context(), testJobType, nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	job = waitForJob(t, repo, job.ID, jobqueue.StatusDead)
+
+	if job.Attempts != 3 || calls.Load() != 3 || job.LastError != "boom" {
+		t.Errorf("expected 3 attempts, got %d in the job and %d calls: %q", job.Attempts, calls.Load(), job.LastError)
+	}
+}
+
+func TestBuryWithoutHandler(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo}
+
+	startQueue(t, queue)
+
+	job, err := queue.Enqueue(t.Context(), "unknown", nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	job = waitForJob(t, repo, job.ID, jobqueue.StatusDead)
+
+	if job.Attempts != 1 {
+		t.Errorf("expected a job without a handler to be buried right away, got %d attempts", job.Attempts)
+	}
+}
+
+func TestRequeueRunningAtStart(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo}
+
+	var calls atomic.Int32
+
+	queue.Register(testJobType, func(context.Context, *jobqueue.Job) error {
+		calls.Add(1)
+
+		return nil
+	})
+
+	// A job left running by a process that crashed.
+	createJob(t, repo, "interrupted", jobqueue.StatusRunning, time.Now().Add(-time.Minute))
+
+	startQueue(t, queue)
+
+	waitForJob(t, repo, "interrupted", jobqueue.StatusSucceeded)
+
+	if calls.Load() != 1 {
+		t.Errorf("expected the interrupted job to run once, got %d", calls.Load())
+	}
+}
+
+func TestPanickingHandler(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo, Backoff: time.Millisecond}
+
+	var calls atomic.Int32
+
+	queue.Register(testJobType, func(context.Context, *jobqueue.Job) error {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+
+		return nil
+	})
+
+	startQueue(t, queue)
+
+	job, err := queue.Enqueue(t.Context(), testJobType, nil)
+	if err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	job = waitForJob(t, repo, job.ID, jobqueue.StatusSucceeded)
+
+	if job.Attempts != 2 || calls.Load() != 2 {
+		t.Errorf("expected the panic to fail the first attempt only, got %d attempts", job.Attempts)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	repo := newJobRepo(t)
+	queue := &jobqueue.Queue{JobRepo: repo, Retention: 24 * time.Hour}
+
+	old := time.Now().Add(-48 * time.Hour)
+
+	createJob(t, repo, "old-succeeded", jobqueue.StatusSucceeded, old)
+	createJob(t, repo, "old-dead", jobqueue.StatusDead, old)
+	createJob(t, repo, "old-pending", jobqueue.StatusPending, old)
+	createJob(t, repo, "new-succeeded", jobqueue.StatusSucceeded, time.Now())
+
+	err := queue.Purge(t.Context())
+	if err != nil {
+		t.Fatalf("failed to purge jobs: %v", err)
+	}
+
+	var ids []string
+
+	rows, err := repo.DB.QueryContext(t.Context(), "SELECT id FROM jobs ORDER BY id")
+	if err != nil {
+		t.Fatalf("failed to list jobs: %v", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id string
+
+		err = rows.Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to scan job: %v", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if len(ids) != 2 || ids[0] != "new-succeeded" || ids[1] != "old-pending" {
+		t.Errorf("expected new-succeeded and old-pending to be kept, got %v", ids)
+	}
+}
@@ -0,0 +1,292 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultDKIMHeaders are the header fields DKIMMailer signs when they are present.
+var DefaultDKIMHeaders = []string{
+	"From",
+	"To",
+	"Cc",
+	"Reply-To",
+	"Subject",
+	"Date",
+	"Message-Id",
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+	"Mime-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+var ErrUnsupportedDKIMKey = errors.New("dkim key must be an RSA or Ed25519 private key")
+
+// DKIMMailer adds a DKIM-Signature (RFC 6376) to messages and sends them through Mailer. It signs with
+// rsa-sha256 or ed25519-sha256 (RFC 8463), depending on Key, and relaxed/relaxed canonicalization.
+type DKIMMailer struct {
+	Mailer Mailer
+	// From is the sender address of Mailer. The signed message must be encoded the same way Mailer encodes it.
+	From string
+	// Domain and Selector locate the public key in DNS, at <Selector>._domainkey.<Domain>.
+	Domain   string
+	Selector string
+	// Key is an *rsa.PrivateKey or an ed25519.PrivateKey, usually loaded with LoadDKIMKey.
+	Key crypto.Signer
+	// Headers defaults to DefaultDKIMHeaders.
+	Headers []string
+}
+
+// LoadDKIMKey reads a PEM encoded PKCS #1 RSA key or a PKCS #8 RSA or Ed25519 key from a file.
+func LoadDKIMKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %q", path)
+	}
+
+	var key any
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, ErrUnsupportedDKIMKey
+	}
+}
+
+func (mailer *DKIMMailer) SendEmail(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(mailer.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+
+	// Fix the date and Message-Id, so Mailer encodes exactly the signed message.
+	signed := *msg
+	signed.Headers = make(map[string]string, len(msg.Headers)+2)
+
+	for key, value := range msg.Headers {
+		if textproto.CanonicalMIMEHeaderKey(key) != "Dkim-Signature" {
+			signed.Headers[key] = value
+		}
+	}
+
+	if signed.Date.IsZero() {
+		signed.Date = time.Now()
+	}
+
+	if !hasHeader(signed.Headers, "Message-Id") {
+		signed.Headers["Message-Id"] = newMessageID(from.Address)
+	}
+
+	data, err := signed.Encode(mailer.From, signed.Date)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	signature, err := mailer.Sign(data, signed.Date)
+	if err != nil {
+		return fmt.Errorf("failed to sign email: %w", err)
+	}
+
+	signed.Headers["DKIM-Signature"] = signature
+
+	return mailer.Mailer.SendEmail(ctx, &signed)
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if textproto.CanonicalMIMEHeaderKey(key) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the value of the DKIM-Signature header field for an encoded message, signed at now.
+func (mailer *DKIMMailer) Sign(data []byte, now time.Time) (string, error) {
+	var (
+		algorithm string
+		opts      crypto.SignerOpts
+	)
+
+	switch mailer.Key.(type) {
+	case *rsa.PrivateKey:
+		algorithm, opts = "rsa-sha256", crypto.SHA256
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the SHA-256 hash with PureEdDSA.
+		algorithm, opts = "ed25519-sha256", crypto.Hash(0)
+	default:
+		return "", ErrUnsupportedDKIMKey
+	}
+
+	header, body, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		return "", errors.New("message has no body")
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	fields := headerFields(string(header) + "\r\n")
+	signedNames := make([]string, 0, len(fields))
+
+	var canonical strings.Builder
+
+	// A header field that appears more than once is signed from the bottom up.
+	used := make(map[int]bool)
+
+	names := mailer.Headers
+	if names == nil {
+		names = DefaultDKIMHeaders
+	}
+
+	for _, name := range names {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fieldName(fields[i]), name) {
+				continue
+			}
+
+			used[i] = true
+
+			signedNames = append(signedNames, strings.ToLower(name))
+			canonical.WriteString(relaxedHeader(fields[i]) + "\r\n")
+
+			break
+		}
+	}
+
+	value := fmt.Sprintf(
+		"v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		algorithm,
+		mailer.Domain,
+		mailer.Selector,
+		now.Unix(),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+
+	// The signature field itself is signed with an empty b= and without the trailing CRLF.
+	canonical.WriteString(relaxedHeader("DKIM-Signature: " + value))
+
+	hash := sha256.Sum256([]byte(canonical.String()))
+
+	signature, err := mailer.Key.Sign(rand.Reader, hash[:], opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign: %w", err)
+	}
+
+	return value + base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// headerFields splits a header into fields, keeping the folded lines of each field.
+func headerFields(header string) []string {
+	var fields []string
+
+	for line := range strings.SplitAfterSeq(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+
+		if len(fields) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			fields[len(fields)-1] += line
+		} else {
+			fields = append(fields, line)
+		}
+	}
+
+	return fields
+}
+
+func fieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+
+	return strings.TrimRight(name, " \t")
+}
+
+// relaxedHeader canonicalizes a header field as in RFC 6376 section 3.4.2, without the trailing CRLF.
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + strings.Trim(compressWSP(value), " ")
+}
+
+// relaxedBody canonicalizes a body as in RFC 6376 section 3.4.4.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(compressWSP(line), " ")
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// compressWSP replaces every run of spaces and tabs with a single space.
+func compressWSP(s string) string {
+	var b strings.Builder
+
+	inWSP := false
+
+	for _, c := range []byte(s) {
+		if c == ' ' || c == '\t' {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+
+			inWSP = true
+
+			continue
+		}
+
+		inWSP = false
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
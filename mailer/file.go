@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes every message as an .eml file to Dir instead of sending it. Mail clients open these files
+// directly.
+type FileMailer struct {
+	Dir string
+	// From is the sender address, optionally with a display name.
+	From string
+}
+
+func (mailer *FileMailer) SendEmail(_ context.Context, msg *Message) error {
+	now := time.Now()
+
+	data, err := msg.Encode(mailer.From, now)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	err = os.MkdirAll(mailer.Dir, 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	// Names sort by time. Write to a temporary file first, so nobody reads a half-written message.
+	name := filepath.Join(mailer.Dir, now.UTC().Format("20060102T150405.000000000")+"-"+uuid.NewString())
+
+	err = os.WriteFile(name+".tmp", data, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	err = os.Rename(name+".tmp", name+".eml")
+	if err != nil {
+		return fmt.Errorf("failed to rename email file: %w", err)
+	}
+
+	return nil
+}
+
+// MaildirMailer delivers every message to the Maildir at Dir, so mail clients such as mutt can read it as a mailbox.
+type MaildirMailer struct {
+	Dir string
+	// From is the sender address, optionally with a display name.
+	From string
+}
+
+func (mailer *MaildirMailer) SendEmail(_ context.Context, msg *Message) error {
+	now := time.Now()
+
+	data, err := msg.Encode(mailer.From, now)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		err = os.MkdirAll(filepath.Join(mailer.Dir, sub), 0o750)
+		if err != nil {
+			return fmt.Errorf("failed to create mail directory: %w", err)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	// The name is unique and left alone by readers, which add flags after a colon when they move it to cur.
+	hostname = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(hostname)
+	name := fmt.Sprintf("%d.%s.%s", now.Unix(), uuid.NewString(), hostname)
+
+	// Messages are written to tmp and moved to new when complete, so nobody reads a half-written message.
+	err = os.WriteFile(filepath.Join(mailer.Dir, "tmp", name), data, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	err = os.Rename(filepath.Join(mailer.Dir, "tmp", name), filepath.Join(mailer.Dir, "new", name))
+	if err != nil {
+		return fmt.Errorf("failed to move email to new: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package mailer_test
+
+import (
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	fileMailer := &mailer.FileMailer{Dir: dir, From: "Blog <blog@example.com>"}
+
+	for _, subject := range []string{"First", "Second"} {
+		err := fileMailer.SendEmail(t.Context(), &mailer.Message{
+			To:      []string{"reader@example.com"},
+			Subject: subject,
+			Text:    "Hi",
+		})
+		if err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read mail directory: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(entries))
+	}
+
+	for i, subject := range []string{"First", "Second"} {
+		if !strings.HasSuffix(entries[i].Name(), ".eml") {
+			t.Errorf("expected an .eml file, got %q", entries[i].Name())
+		}
+
+		f, err := os.Open(filepath.Join(dir, entries[i].Name()))
+		if err != nil {
+			t.Fatalf("failed to open file: %v", err)
+		}
+
+		msg, err := mail.ReadMessage(f)
+		_ = f.Close()
+
+		if err != nil {
+			t.Fatalf("failed to parse file: %v", err)
+		}
+
+		if msg.Header.Get("Subject") != subject || msg.Header.Get("To") != "<reader@example.com>" {
+			t.Errorf("unexpected headers %v", msg.Header)
+		}
+	}
+}
+
+func TestMaildirMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Maildir")
+	maildirMailer := &mailer.MaildirMailer{Dir: dir, From: "Blog <blog@example.com>"}
+
+	err := maildirMailer.SendEmail(t.Context(), &mailer.Message{
+		To:      []string{"reader@example.com"},
+		Subject: "Hello",
+		Text:    "Hi",
+	})
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	for sub, want := range map[string]int{"tmp": 0, "new": 1, "cur": 0} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", sub, err)
+		}
+
+		if len(entries) != want {
+			t.Fatalf("expected %d files in %s, got %d", want, sub, len(entries))
+		}
+
+		if sub != "new" {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, sub, entries[0].Name()))
+		if err != nil {
+			t.Fatalf("failed to open file: %v", err)
+		}
+
+		msg, err := mail.ReadMessage(f)
+		_ = f.Close()
+
+		if err != nil {
+			t.Fatalf("failed to parse file: %v", err)
+		}
+
+		if msg.Header.Get("Subject") != "Hello" {
+			t.Errorf("unexpected headers %v", msg.Header)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
+)
+
+// SendEmailJobType is the job that sends an email in the background, retried by the queue when sending fails. Its
+// payload is a Message.
+const SendEmailJobType = "mailer.send_email"
+
+func SendEmailJobHandler(mailer Mailer) jobqueue.Handler {
+	return func(ctx context.Context, job *jobqueue.Job) error {
+		var msg Message
+
+		err := job.DecodePayload(&msg)
+		if err != nil {
+			return err
+		}
+
+		return mailer.SendEmail(ctx, &msg)
+	}
+}
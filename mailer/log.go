@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogMailer writes every message to slog instead of sending it.
+type LogMailer struct {
+	From string
+}
+
+func (mailer *LogMailer) SendEmail(ctx context.Context, msg *Message) error {
+	err := msg.Validate()
+	if err != nil {
+		return err
+	}
+
+	body := msg.Text
+	if body == "" {
+		body = msg.HTML
+	}
+
+	slog.InfoContext(
+		ctx,
+		"email",
+		"from", mailer.From,
+		"to", msg.To,
+		"cc", msg.Cc,
+		"subject", msg.Subject,
+		"body", body,
+	)
+
+	return nil
+}
@@ -5,5 +5,5 @@ import (
 )
 
 type Mailer interface {
-	SendEmail(ctx context.Context, to, subject, body string) error
+	SendEmail(ctx context.Context, msg *Message) error
 }
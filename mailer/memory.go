@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultMemoryMailerLimit = 100
+
+// CapturedMessage is a message kept by MemoryMailer.
+type CapturedMessage struct {
+	ID      string
+	From    string
+	Message *Message
+	SentAt  time.Time
+}
+
+type CapturedMessageByIDNotFoundError struct {
+	ID string
+}
+
+func (err CapturedMessageByIDNotFoundError) Error() string {
+	return fmt.Sprintf("captured message with ID %q not found", err.ID)
+}
+
+// MemoryMailer keeps messages in memory instead of sending them, for development and tests.
+type MemoryMailer struct {
+	From string
+	// Limit is the number of messages kept. The oldest are dropped first.
+	Limit int
+
+	mu       sync.Mutex
+	messages []*CapturedMessage
+}
+
+func (mailer *MemoryMailer) SendEmail(_ context.Context, msg *Message) error {
+	err := msg.Validate()
+	if err != nil {
+		return err
+	}
+
+	captured := &CapturedMessage{
+		ID:      uuid.NewString(),
+		From:    mailer.From,
+		Message: msg,
+		SentAt:  time.Now(),
+	}
+
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	limit := mailer.Limit
+	if limit <= 0 {
+		limit = DefaultMemoryMailerLimit
+	}
+
+	mailer.messages = append(mailer.messages, captured)
+	if len(mailer.messages) > limit {
+		mailer.messages = slices.Delete(mailer.messages, 0, len(mailer.messages)-limit)
+	}
+
+	return nil
+}
+
+// Messages returns the kept messages, newest first.
+func (mailer *MemoryMailer) Messages() []*CapturedMessage {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	messages := slices.Clone(mailer.messages)
+	slices.Reverse(messages)
+
+	return messages
+}
+
+func (mailer *MemoryMailer) Message(id string) (*CapturedMessage, error) {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	for _, message := range mailer.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+
+	return nil, CapturedMessageByIDNotFoundError{ID: id}
+}
+
+// Clear drops all kept messages.
+func (mailer *MemoryMailer) Clear() {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	mailer.messages = nil
+}
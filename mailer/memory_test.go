@@ -0,0 +1,49 @@
+package mailer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailbox := &mailer.MemoryMailer{From: "blog@example.com", Limit: 2}
+
+	for _, subject := range []string{"First", "Second", "Third"} {
+		err := mailbox.SendEmail(t.Context(), &mailer.Message{
+			To:      []string{"reader@example.com"},
+			Subject: subject,
+			Text:    "Hi",
+		})
+		if err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+	}
+
+	messages := mailbox.Messages()
+	if len(messages) != 2 || messages[0].Message.Subject != "Third" || messages[1].Message.Subject != "Second" {
+		t.Fatalf("expected the 2 newest messages, newest first, got %v", messages)
+	}
+
+	message, err := mailbox.Message(messages[1].ID)
+	if err != nil || message != messages[1] {
+		t.Errorf("failed to get message: %v", err)
+	}
+
+	err = mailbox.SendEmail(t.Context(), &mailer.Message{Subject: "No recipients", Text: "Hi"})
+	if !errors.Is(err, mailer.ErrNoRecipients) {
+		t.Errorf("expected ErrNoRecipients, got %v", err)
+	}
+
+	mailbox.Clear()
+
+	if len(mailbox.Messages()) != 0 {
+		t.Error("expected no messages after clear")
+	}
+
+	_, err = mailbox.Message(messages[0].ID)
+	if !errors.As(err, &mailer.CapturedMessageByIDNotFoundError{}) {
+		t.Errorf("expected CapturedMessageByIDNotFoundError, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is an email. Addresses may carry a display name, as in "Jane Doe <jane@example.com>".
+type Message struct {
+	To      []string `json:"to"`
+	Cc      []string `json:"cc,omitempty"`
+	ReplyTo []string `json:"replyTo,omitempty"`
+	Subject string   `json:"subject"`
+	// Text is the plain-text part.
+	Text string `json:"text,omitempty"`
+	// HTML is the HTML part. A message with both parts is sent as multipart/alternative.
+	HTML string `json:"html,omitempty"`
+	// Headers are extra header fields, such as List-Unsubscribe.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Date is written instead of the time of sending when set.
+	Date time.Time `json:"date,omitzero"`
+}
+
+var (
+	ErrNoRecipients = errors.New("message has no recipients")
+	ErrNoBody       = errors.New("message has no text or HTML part")
+)
+
+// reservedHeaders are written by Encode and cannot be set through Message.Headers.
+var reservedHeaders = []string{
+	"Bcc",
+	"Cc",
+	"Content-Transfer-Encoding",
+	"Content-Type",
+	"Date",
+	"From",
+	"Mime-Version",
+	"Reply-To",
+	"Subject",
+	"To",
+}
+
+// Validate reports whether the message has valid recipients and a body, so it can be queued and sent later.
+func (msg *Message) Validate() error {
+	_, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+
+	_, err = parseAddresses(msg.ReplyTo)
+	if err != nil {
+		return err
+	}
+
+	if msg.Text == "" && msg.HTML == "" {
+		return ErrNoBody
+	}
+
+	return nil
+}
+
+// Recipients returns the addresses of To and Cc without display names.
+func (msg *Message) Recipients() ([]string, error) {
+	var recipients []string
+
+	for _, list := range [][]string{msg.To, msg.Cc} {
+		addresses, err := parseAddresses(list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range addresses {
+			recipients = append(recipients, address.Address)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	return recipients, nil
+}
+
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	addresses := make([]*mail.Address, 0, len(list))
+
+	for _, s := range list {
+		address, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", s, err)
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// formatAddresses returns an address list header value, folded over several lines.
+func formatAddresses(list []string) (string, error) {
+	addresses, err := parseAddresses(list)
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		values = append(values, address.String())
+	}
+
+	return strings.Join(values, ",\r\n "), nil
+}
+
+// Encode returns the message in RFC 5322 format with MIME parts, sent from the given address at now. Non-ASCII
+// display names and subjects are written as RFC 2047 encoded words and bodies as quoted-printable UTF-8. A message
+// with a Date and a Message-Id header is encoded the same way every time, so it can be signed before it is sent.
+func (msg *Message) Encode(from string, now time.Time) ([]byte, error) {
+	if len(msg.To) == 0 && len(msg.Cc) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	if msg.Text == "" && msg.HTML == "" {
+		return nil, ErrNoBody
+	}
+
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+
+	var buf bytes.Buffer
+
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", fromAddress.String())
+
+	for _, field := range []struct {
+		key  string
+		list []string
+	}{
+		{"To", msg.To},
+		{"Cc", msg.Cc},
+		{"Reply-To", msg.ReplyTo},
+	} {
+		if len(field.list) == 0 {
+			continue
+		}
+
+		value, err := formatAddresses(field.list)
+		if err != nil {
+			return nil, err
+		}
+
+		writeHeader(field.key, value)
+	}
+
+	// Long subjects are split into several encoded words; put each on its own line.
+	writeHeader("Subject", strings.ReplaceAll(mime.QEncoding.Encode("utf-8", msg.Subject), "?= =?", "?=\r\n =?"))
+	if !msg.Date.IsZero() {
+		now = msg.Date
+	}
+
+	writeHeader("Date", now.Format(time.RFC1123Z))
+
+	headers := make(map[string]string, len(msg.Headers)+1)
+
+	for key, value := range msg.Headers {
+		if key == "" || strings.ContainsAny(key, "\r\n: ") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header %q", key)
+		}
+
+		key = textproto.CanonicalMIMEHeaderKey(key)
+
+		if slices.Contains(reservedHeaders, key) {
+			return nil, fmt.Errorf("header %q cannot be set", key)
+		}
+
+		headers[key] = value
+	}
+
+	if _, ok := headers["Message-Id"]; !ok {
+		headers["Message-Id"] = newMessageID(fromAddress.Address)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(headers)) {
+		writeHeader(key, mime.QEncoding.Encode("utf-8", headers[key]))
+	}
+
+	writeHeader("MIME-Version", "1.0")
+
+	if msg.Text == "" || msg.HTML == "" {
+		contentType := "text/plain; charset=utf-8"
+		body := msg.Text
+
+		if msg.HTML != "" {
+			contentType = "text/html; charset=utf-8"
+			body = msg.HTML
+		}
+
+		writeHeader("Content-Type", contentType)
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		err = writeQuotedPrintable(&buf, body)
+		if err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+
+	err = mw.SetBoundary(boundary(headers["Message-Id"]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set boundary: %w", err)
+	}
+
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{
+		"boundary": mw.Boundary(),
+	}))
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create part: %w", err)
+		}
+
+		err = writeQuotedPrintable(pw, part.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func newMessageID(from string) string {
+	_, domain, _ := strings.Cut(from, "@")
+
+	return "<" + uuid.NewString() + "@" + domain + ">"
+}
+
+// boundary derives the multipart boundary from the unique Message-Id. It starts with "=_", which quoted-printable
+// never produces, so it cannot appear in a part.
+func boundary(messageID string) string {
+	sum := sha256.Sum256([]byte(messageID))
+
+	return "=_" + hex.EncodeToString(sum[:16])
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+
+	_, err := qw.Write([]byte(body))
+	if err != nil {
+		return fmt.Errorf("failed to write body: %w", err)
+	}
+
+	err = qw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close body writer: %w", err)
+	}
+
+	return nil
+}
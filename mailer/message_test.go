@@ -0,0 +1,200 @@
+package mailer_test
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func readMessage(t *testing.T, msg *mailer.Message) *mail.Message {
+	t.Helper()
+
+	data, err := msg.Encode("Blog <blog@example.com>", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+
+	for line := range strings.SplitSeq(string(data), "\r\n") {
+		if len(line) > 998 {
+			t.Errorf("line is longer than 998 characters: %q", line)
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	return parsed
+}
+
+func TestMessageEncodeHeaders(t *testing.T) {
+	parsed := readMessage(t, &mailer.Message{
+		To:      []string{"Jürgen Müller <jurgen@example.com>", "reader@example.com"},
+		Cc:      []string{"editor@example.com"},
+		ReplyTo: []string{"support@example.com"},
+		Subject: "Grüße aus dem Blog – ein sehr langer Betreff, der über mehrere kodierte Wörter gehen muss ✓",
+		Text:    "Hallo",
+		Headers: map[string]string{"List-Unsubscribe": "<https://blog.example.com/unsubscribe>"},
+	})
+
+	var dec mime.WordDecoder
+
+	subject, err := dec.DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("failed to decode subject: %v", err)
+	}
+
+	if subject != "Grüße aus dem Blog – ein sehr langer Betreff, der über mehrere kodierte Wörter gehen muss ✓" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+
+	to, err := parsed.Header.AddressList("To")
+	if err != nil {
+		t.Fatalf("failed to parse To: %v", err)
+	}
+
+	if len(to) != 2 || to[0].Name != "Jürgen Müller" || to[0].Address != "jurgen@example.com" {
+		t.Errorf("unexpected To %v", to)
+	}
+
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Blog" {
+		t.Errorf("unexpected From %v: %v", from, err)
+	}
+
+	for key, want := range map[string]string{
+		"Cc":               "<editor@example.com>",
+		"Reply-To":         "<support@example.com>",
+		"Date":             "Fri, 02 Jan 2026 03:04:05 +0000",
+		"Mime-Version":     "1.0",
+		"List-Unsubscribe": "<https://blog.example.com/unsubscribe>",
+	} {
+		if got := parsed.Header.Get(key); got != want {
+			t.Errorf("expected %s %q, got %q", key, want, got)
+		}
+	}
+
+	if id := parsed.Header.Get("Message-Id"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("unexpected Message-Id %q", id)
+	}
+}
+
+func TestMessageEncodeMultipart(t *testing.T) {
+	parsed := readMessage(t, &mailer.Message{
+		To:      []string{"reader@example.com"},
+		Subject: "Hello",
+		Text:    "Hi there,\nthis line is long enough to need a soft line break in quoted-printable encoding, ünïcödé.",
+		HTML:    "<p>Hi there, ünïcödé.</p>",
+	})
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q: %v", mediaType, err)
+	}
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+
+	want := []struct{ contentType, body string }{
+		{
+			"text/plain; charset=utf-8",
+			"Hi there,\r\nthis line is long enough to need a soft line break in quoted-printable encoding, ünïcödé.",
+		},
+		{"text/html; charset=utf-8", "<p>Hi there, ünïcödé.</p>"},
+	}
+
+	for _, w := range want {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+
+		if part.Header.Get("Content-Type") != w.contentType {
+			t.Errorf("expected content type %q, got %q", w.contentType, part.Header.Get("Content-Type"))
+		}
+
+		if part.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+			t.Errorf("expected quoted-printable, got %q", part.Header.Get("Content-Transfer-Encoding"))
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("failed to decode part: %v", err)
+		}
+
+		if string(body) != w.body {
+			t.Errorf("expected body %q, got %q", w.body, body)
+		}
+	}
+
+	_, err = mr.NextPart()
+	if err != io.EOF {
+		t.Errorf("expected 2 parts, got more: %v", err)
+	}
+}
+
+func TestMessageEncodeRejectsInvalidMessages(t *testing.T) {
+	tests := map[string]*mailer.Message{
+		"no recipients":   {Subject: "Hello", Text: "Hi"},
+		"no body":         {To: []string{"reader@example.com"}, Subject: "Hello"},
+		"invalid address": {To: []string{"not an address"}, Text: "Hi"},
+		"header injection": {
+			To:      []string{"reader@example.com"},
+			Text:    "Hi",
+			Headers: map[string]string{"X-Note": "a\r\nBcc: x@example.com"},
+		},
+		"reserved header": {
+			To:      []string{"reader@example.com"},
+			Text:    "Hi",
+			Headers: map[string]string{"bcc": "x@example.com"},
+		},
+	}
+
+	for name, msg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := msg.Encode("blog@example.com", time.Now())
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	msg, err := mailer.Render("password-reset", map[string]any{
+		"ResetLink": "https://blog.example.com/reset-password?token=abc&x=<y>",
+	})
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+
+	if msg.Subject != "Password Reset Request" {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+
+	if !strings.Contains(msg.Text, "https://blog.example.com/reset-password?token=abc&x=<y>\n") {
+		t.Errorf("expected the raw link in the text part, got %q", msg.Text)
+	}
+
+	if !strings.Contains(msg.HTML, "<!DOCTYPE html>") || !strings.Contains(msg.HTML, "token=abc&amp;x=%3cy%3e") {
+		t.Errorf("expected the escaped link in the HTML layout, got %q", msg.HTML)
+	}
+
+	_, err = mailer.Render("password-reset", map[string]any{})
+	if err == nil {
+		t.Error("expected an error for missing data")
+	}
+
+	_, err = mailer.Render("no-such-email", nil)
+	if err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultOutboxMaxAttempts = 5
+	DefaultOutboxBackoff     = time.Minute
+	outboxBatchSize          = 100
+)
+
+// ErrOutboxMessageNotFailed is returned when retrying a message that has not failed.
+var ErrOutboxMessageNotFailed = errors.New("outbox message has not failed")
+
+// Outbox is a Mailer that stores messages in MessageRepo and returns right away. RunSender delivers them through
+// Transport and retries failed attempts with exponential backoff.
+type Outbox struct {
+	MessageRepo OutboxRepository
+	// Transport delivers the messages, usually an SMTPMailer.
+	Transport Mailer
+	// MaxAttempts is the number of attempts before a message fails for good.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles on every retry.
+	Backoff time.Duration
+
+	wake     chan struct{}
+	wakeOnce sync.Once
+}
+
+// SendEmail queues a message for the sender.
+func (outbox *Outbox) SendEmail(ctx context.Context, msg *Message) error {
+	err := msg.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid email: %w", err)
+	}
+
+	now := time.Now()
+
+	message := &OutboxMessage{
+		ID:            uuid.NewString(),
+		Message:       msg,
+		Status:        OutboxStatusQueued,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	err = outbox.MessageRepo.Create(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox message: %w", err)
+	}
+
+	outbox.notify()
+
+	return nil
+}
+
+func (outbox *Outbox) wakeChan() chan struct{} {
+	outbox.wakeOnce.Do(func() {
+		outbox.wake = make(chan struct{}, 1)
+	})
+
+	return outbox.wake
+}
+
+func (outbox *Outbox) notify() {
+	select {
+	case outbox.wakeChan() <- struct{}{}:
+	default:
+	}
+}
+
+func (outbox *Outbox) maxAttempts() int {
+	if outbox.MaxAttempts <= 0 {
+		return DefaultOutboxMaxAttempts
+	}
+
+	return outbox.MaxAttempts
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (outbox *Outbox) backoff(attempts int) time.Duration {
+	base := outbox.Backoff
+	if base <= 0 {
+		base = DefaultOutboxBackoff
+	}
+
+	return base << (attempts - 1)
+}
+
+// SendDue attempts the queued messages that are due.
+func (outbox *Outbox) SendDue(ctx context.Context) error {
+	messages, err := outbox.MessageRepo.ListDue(ctx, time.Now(), outboxBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list due outbox messages: %w", err)
+	}
+
+	for _, message := range messages {
+		err = outbox.attempt(ctx, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (outbox *Outbox) attempt(ctx context.Context, message *OutboxMessage) error {
+	sendErr := outbox.Transport.SendEmail(ctx, message.Message)
+
+	now := time.Now()
+	message.Attempts++
+	message.UpdatedAt = now
+
+	switch {
+	case sendErr == nil:
+		message.Status = OutboxStatusSent
+		message.LastError = ""
+		message.NextAttemptAt = nil
+		message.SentAt = &now
+	case message.Attempts >= outbox.maxAttempts():
+		message.Status = OutboxStatusFailed
+		message.LastError = sendErr.Error()
+		message.NextAttemptAt = nil
+
+		slog.ErrorContext(ctx, "failed to send email", "error", sendErr, "messageId", message.ID)
+	default:
+		nextAttemptAt := now.Add(outbox.backoff(message.Attempts))
+		message.LastError = sendErr.Error()
+		message.NextAttemptAt = &nextAttemptAt
+	}
+
+	err := outbox.MessageRepo.Update(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// RunSender calls SendDue on every tick and whenever a message is queued, until ctx is done.
+func (outbox *Outbox) RunSender(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := outbox.SendDue(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send outbox messages", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-outbox.wakeChan():
+		}
+	}
+}
+
+// Retry queues a failed message again with a fresh set of attempts.
+func (outbox *Outbox) Retry(ctx context.Context, id string) error {
+	message, err := outbox.MessageRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get outbox message: %w", err)
+	}
+
+	if message.Status != OutboxStatusFailed {
+		return ErrOutboxMessageNotFailed
+	}
+
+	now := time.Now()
+	message.Status = OutboxStatusQueued
+	message.Attempts = 0
+	message.NextAttemptAt = &now
+	message.UpdatedAt = now
+
+	err = outbox.MessageRepo.Update(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+
+	outbox.notify()
+
+	return nil
+}
+
+func (outbox *Outbox) ListMessages(ctx context.Context, params ListOutboxMessagesParams) ([]*OutboxMessage, error) {
+	messages, err := outbox.MessageRepo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (outbox *Outbox) CountMessages(ctx context.Context, params ListOutboxMessagesParams) (int, error) {
+	count, err := outbox.MessageRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count outbox messages: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusQueued OutboxStatus = "queued"
+	OutboxStatusSent   OutboxStatus = "sent"
+	OutboxStatusFailed OutboxStatus = "failed"
+)
+
+var OutboxStatuses = []OutboxStatus{OutboxStatusQueued, OutboxStatusSent, OutboxStatusFailed}
+
+// OutboxMessage is an email stored in the outbox. A queued message is attempted again at NextAttemptAt until it is
+// sent or runs out of attempts and fails.
+type OutboxMessage struct {
+	ID            string
+	Message       *Message
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	SentAt        *time.Time
+}
+
+type ListOutboxMessagesParams struct {
+	Status OutboxStatus
+	Limit  int
+	Offset int
+}
+
+type OutboxRepository interface {
+	Create(ctx context.Context, message *OutboxMessage) (err error)
+	Update(ctx context.Context, message *OutboxMessage) (err error)
+	GetByID(ctx context.Context, id string) (message *OutboxMessage, err error)
+	// List returns messages newest first.
+	List(ctx context.Context, params ListOutboxMessagesParams) (messages []*OutboxMessage, err error)
+	Count(ctx context.Context, params ListOutboxMessagesParams) (count int, err error)
+	// ListDue returns queued messages whose next attempt is at or before now, oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) (messages []*OutboxMessage, err error)
+}
+
+type OutboxMessageByIDNotFoundError struct {
+	ID string
+}
+
+func (err OutboxMessageByIDNotFoundError) Error() string {
+	return fmt.Sprintf("outbox message with ID %q not found", err.ID)
+}
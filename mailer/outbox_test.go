@@ -0,0 +1,226 @@
+package mailer_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func newOutbox(t *testing.T, server *smtpServer) *mailer.Outbox {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	host, port := server.addr()
+
+	return &mailer.Outbox{
+		MessageRepo: &sqlite3.OutboxMessageRepo{DB: db},
+		Transport:   &mailer.SMTPMailer{Host: host, Port: port, From: "blog@example.com"},
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}
+}
+
+func newMessage() *mailer.Message {
+	return &mailer.Message{
+		To:      []string{"reader@example.com"},
+		Subject: "Hello",
+		Text:    "Hi there",
+	}
+}
+
+// sendUntilSettled calls SendDue until no message is queued.
+func sendUntilSettled(t *testing.T, outbox *mailer.Outbox) {
+	t.Helper()
+
+	for range 100 {
+		err := outbox.SendDue(t.Context())
+		if err != nil {
+			t.Fatalf("failed to send due messages: %v", err)
+		}
+
+		count, err := outbox.CountMessages(
+			t.Context(),
+			mailer.ListOutboxMessagesParams{Status: mailer.OutboxStatusQueued},
+		)
+		if err != nil {
+			t.Fatalf("failed to count queued messages: %v", err)
+		}
+
+		if count == 0 {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("messages are still queued")
+}
+
+func onlyMessage(t *testing.T, outbox *mailer.Outbox) *mailer.OutboxMessage {
+	t.Helper()
+
+	messages, err := outbox.ListMessages(t.Context(), mailer.ListOutboxMessagesParams{})
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+
+	return messages[0]
+}
+
+func TestOutboxQueuesWithoutSending(t *testing.T) {
+	server := newSMTPServer(t, 0)
+	outbox := newOutbox(t, server)
+
+	err := outbox.SendEmail(t.Context(), newMessage())
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	message := onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusQueued {
+		t.Errorf("expected status %q, got %q", mailer.OutboxStatusQueued, message.Status)
+	}
+
+	if len(server.messages()) != 0 {
+		t.Error("expected nothing to be sent before the sender runs")
+	}
+}
+
+func TestOutboxSends(t *testing.T) {
+	server := newSMTPServer(t, 0)
+	outbox := newOutbox(t, server)
+
+	err := outbox.SendEmail(t.Context(), newMessage())
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	sendUntilSettled(t, outbox)
+
+	message := onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusSent {
+		t.Errorf("expected status %q, got %q", mailer.OutboxStatusSent, message.Status)
+	}
+
+	if message.Attempts != 1 || message.SentAt == nil {
+		t.Errorf("expected 1 attempt and a sent time, got %d attempts and %v", message.Attempts, message.SentAt)
+	}
+
+	received := server.messages()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 received message, got %d", len(received))
+	}
+
+	if !strings.Contains(received[0], "Subject: Hello") || !strings.Contains(received[0], "Hi there") {
+		t.Errorf("unexpected message data: %q", received[0])
+	}
+}
+
+func TestOutboxRetriesTemporaryFailures(t *testing.T) {
+	server := newSMTPServer(t, 2)
+	outbox := newOutbox(t, server)
+
+	err := outbox.SendEmail(t.Context(), newMessage())
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	err = outbox.SendDue(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send due messages: %v", err)
+	}
+
+	message := onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusQueued || message.Attempts != 1 {
+		t.Fatalf("expected a queued message after 1 attempt, got %q after %d", message.Status, message.Attempts)
+	}
+
+	if message.LastError == "" || message.NextAttemptAt == nil || !message.NextAttemptAt.After(message.CreatedAt) {
+		t.Errorf(
+			"expected the error and a later attempt to be recorded, got %q at %v",
+			message.LastError,
+			message.NextAttemptAt,
+		)
+	}
+
+	sendUntilSettled(t, outbox)
+
+	message = onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusSent || message.Attempts != 3 {
+		t.Errorf("expected the message to be sent on attempt 3, got %q after %d", message.Status, message.Attempts)
+	}
+
+	if len(server.messages()) != 1 {
+		t.Errorf("expected 1 received message, got %d", len(server.messages()))
+	}
+}
+
+func TestOutboxFailsAfterMaxAttempts(t *testing.T) {
+	server := newSMTPServer(t, 10)
+	outbox := newOutbox(t, server)
+
+	err := outbox.SendEmail(t.Context(), newMessage())
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	sendUntilSettled(t, outbox)
+
+	message := onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusFailed || message.Attempts != 3 {
+		t.Fatalf("expected the message to fail after 3 attempts, got %q after %d", message.Status, message.Attempts)
+	}
+
+	if !strings.Contains(message.LastError, "try again later") {
+		t.Errorf("expected the SMTP error to be recorded, got %q", message.LastError)
+	}
+
+	server.mu.Lock()
+	server.failures = 0
+	server.mu.Unlock()
+
+	err = outbox.Retry(t.Context(), message.ID)
+	if err != nil {
+		t.Fatalf("failed to retry message: %v", err)
+	}
+
+	sendUntilSettled(t, outbox)
+
+	message = onlyMessage(t, outbox)
+
+	if message.Status != mailer.OutboxStatusSent {
+		t.Errorf("expected the retried message to be sent, got %q", message.Status)
+	}
+
+	err = outbox.Retry(t.Context(), message.ID)
+	if err == nil {
+		t.Error("expected an error retrying a sent message")
+	}
+}
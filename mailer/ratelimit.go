@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRatePerMinute is the number of messages sent per minute unless configured otherwise. It stays within the
+// limits of most mail providers.
+const DefaultRatePerMinute = 60
+
+// RateLimitedMailer sends through Mailer with at least Interval between two messages, so bulk mail such as
+// newsletter digests stays within the limits of the mail provider. SendEmail waits for its turn.
+type RateLimitedMailer struct {
+	Mailer   Mailer
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (mailer *RateLimitedMailer) SendEmail(ctx context.Context, msg *Message) error {
+	mailer.mu.Lock()
+
+	now := time.Now()
+
+	at := mailer.next
+	if at.Before(now) {
+		at = now
+	}
+
+	mailer.next = at.Add(mailer.Interval)
+
+	mailer.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return mailer.Mailer.SendEmail(ctx, msg)
+}
@@ -0,0 +1,47 @@
+package mailer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func TestRateLimitedMailer(t *testing.T) {
+	mailbox := &mailer.MemoryMailer{From: "blog@example.com"}
+	limited := &mailer.RateLimitedMailer{Mailer: mailbox, Interval: 50 * time.Millisecond}
+
+	msg := &mailer.Message{To: []string{"reader@example.com"}, Subject: "Hello", Text: "Hi"}
+
+	start := time.Now()
+
+	for range 3 {
+		err := limited.SendEmail(t.Context(), msg)
+		if err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 3 emails to take at least 2 intervals, took %v", elapsed)
+	}
+
+	if len(mailbox.Messages()) != 3 {
+		t.Errorf("expected 3 emails, got %d", len(mailbox.Messages()))
+	}
+
+	// Waiting for a turn stops with the context.
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	limited.Interval = time.Hour
+
+	_ = limited.SendEmail(ctx, msg)
+
+	err := limited.SendEmail(ctx, msg)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to stop with the context, got %v", err)
+	}
+}
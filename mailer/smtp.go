@@ -1,38 +1,408 @@
 package mailer
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"os"
+	"sync"
+	"time"
 )
 
+// TLSMode is how SMTPMailer secures the connection to the server.
+type TLSMode string
+
+const (
+	// TLSModeOpportunistic upgrades the connection with STARTTLS when the server offers it.
+	TLSModeOpportunistic TLSMode = "opportunistic"
+	// TLSModeNone never uses TLS. Use it only for local relays.
+	TLSModeNone TLSMode = "none"
+	// TLSModeStartTLS requires the server to offer STARTTLS and fails otherwise.
+	TLSModeStartTLS TLSMode = "starttls"
+	// TLSModeImplicit connects with TLS from the start, usually on port 465.
+	TLSModeImplicit TLSMode = "tls"
+)
+
+// AuthMechanism is the SASL mechanism SMTPMailer authenticates with.
+type AuthMechanism string
+
+const (
+	AuthPlain   AuthMechanism = "plain"
+	AuthLogin   AuthMechanism = "login"
+	AuthCRAMMD5 AuthMechanism = "cram-md5"
+)
+
+const (
+	DefaultSMTPDialTimeout    = 10 * time.Second
+	DefaultSMTPCommandTimeout = 30 * time.Second
+	DefaultSMTPIdleTimeout    = 30 * time.Second
+)
+
+var ErrStartTLSNotSupported = errors.New("smtp server does not support STARTTLS")
+
+// SMTPMailer sends emails through an SMTP server. It keeps the connection open between emails, so batches are sent
+// over one connection, and closes it after IdleTimeout.
 type SMTPMailer struct {
 	Host     string
 	Port     string
 	Username string
 	Password string
-	From     string
+	// From is the sender address, optionally with a display name.
+	From string
+	// TLSMode defaults to TLSModeOpportunistic.
+	TLSMode TLSMode
+	// AuthMechanism defaults to AuthPlain. Authentication is skipped without a Username.
+	AuthMechanism AuthMechanism
+	// RootCAs verifies the certificate of the server instead of the system roots, e.g. for a relay with a private CA.
+	RootCAs *x509.CertPool
+	// InsecureSkipVerify accepts any certificate. Use it only for local relays.
+	InsecureSkipVerify bool
+	// DialTimeout limits connecting and greeting the server.
+	DialTimeout time.Duration
+	// CommandTimeout limits every SMTP command, including sending the message data.
+	CommandTimeout time.Duration
+	// IdleTimeout is how long an unused connection is kept open.
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	conn      net.Conn
+	client    *smtp.Client
+	idleTimer *time.Timer
 }
 
-func (mailer *SMTPMailer) SendEmail(ctx context.Context, to, subject, body string) error {
-	msg := []byte("To: " + to + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		body + "\r\n")
+// LoadCertPool reads PEM encoded CA certificates from a file.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
 
-	var auth smtp.Auth
-	if mailer.Username != "" {
-		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
-	} else {
-		auth = nil
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %q", path)
 	}
 
-	addr := fmt.Sprintf("%s:%s", mailer.Host, mailer.Port)
+	return pool, nil
+}
+
+func (mailer *SMTPMailer) SendEmail(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(mailer.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
 
-	err := smtp.SendMail(addr, auth, mailer.From, []string{to}, msg)
+	recipients, err := msg.Recipients()
 	if err != nil {
+		return fmt.Errorf("failed to get recipients: %w", err)
+	}
+
+	data, err := msg.Encode(mailer.From, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	if mailer.idleTimer != nil {
+		mailer.idleTimer.Stop()
+	}
+
+	err = mailer.send(ctx, from.Address, recipients, data)
+	if err != nil {
+		// The connection may be in the middle of a transaction; start over next time.
+		mailer.closeConn()
+
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
+	var idleTimer *time.Timer
+
+	idleTimer = time.AfterFunc(cmp.Or(mailer.IdleTimeout, DefaultSMTPIdleTimeout), func() {
+		mailer.mu.Lock()
+		defer mailer.mu.Unlock()
+
+		// A newer email may have used the connection since the timer fired.
+		if mailer.idleTimer == idleTimer {
+			mailer.closeConn()
+		}
+	})
+
+	mailer.idleTimer = idleTimer
+
 	return nil
 }
+
+func (mailer *SMTPMailer) send(ctx context.Context, from string, recipients []string, data []byte) error {
+	if mailer.client != nil {
+		// Check that the kept connection is still usable.
+		mailer.setDeadline(ctx)
+
+		err := mailer.client.Reset()
+		if err != nil {
+			mailer.closeConn()
+		}
+	}
+
+	if mailer.client == nil {
+		err := mailer.connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Interrupt blocked reads and writes when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = mailer.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	mailer.setDeadline(ctx)
+
+	err := mailer.client.Mail(from)
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	for _, recipient := range recipients {
+		mailer.setDeadline(ctx)
+
+		err = mailer.client.Rcpt(recipient)
+		if err != nil {
+			return withContextErr(ctx, err)
+		}
+	}
+
+	mailer.setDeadline(ctx)
+
+	w, err := mailer.client.Data()
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	return nil
+}
+
+// connect dials the server, secures the connection and authenticates.
+func (mailer *SMTPMailer) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(mailer.DialTimeout, DefaultSMTPDialTimeout))
+	defer cancel()
+
+	addr := net.JoinHostPort(mailer.Host, mailer.Port)
+	dialer := &net.Dialer{}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if mailer.tlsMode() == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: mailer.tlsConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	// The greeting and handshake share the dial timeout.
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, mailer.Host)
+	if err != nil {
+		_ = conn.Close()
+
+		return fmt.Errorf("failed to greet smtp server: %w", withContextErr(ctx, err))
+	}
+
+	err = mailer.handshake(client)
+	if err != nil {
+		_ = client.Close()
+
+		return withContextErr(ctx, err)
+	}
+
+	mailer.conn = conn
+	mailer.client = client
+
+	return nil
+}
+
+func (mailer *SMTPMailer) handshake(client *smtp.Client) error {
+	err := client.Hello("localhost")
+	if err != nil {
+		return fmt.Errorf("failed to say hello: %w", err)
+	}
+
+	switch mailer.tlsMode() {
+	case TLSModeStartTLS, TLSModeOpportunistic:
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			if mailer.tlsMode() == TLSModeStartTLS {
+				return ErrStartTLSNotSupported
+			}
+
+			break
+		}
+
+		err = client.StartTLS(mailer.tlsConfig())
+		if err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	case TLSModeNone, TLSModeImplicit:
+	default:
+		return fmt.Errorf("unsupported tls mode %q", mailer.TLSMode)
+	}
+
+	if mailer.Username == "" {
+		return nil
+	}
+
+	var auth smtp.Auth
+
+	switch mailer.AuthMechanism {
+	case AuthLogin:
+		auth = &loginAuth{username: mailer.Username, password: mailer.Password, host: mailer.Host}
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(mailer.Username, mailer.Password)
+	case AuthPlain, "":
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	default:
+		return fmt.Errorf("unsupported auth mechanism %q", mailer.AuthMechanism)
+	}
+
+	err = client.Auth(auth)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	return nil
+}
+
+func (mailer *SMTPMailer) tlsMode() TLSMode {
+	if mailer.TLSMode == "" {
+		return TLSModeOpportunistic
+	}
+
+	return mailer.TLSMode
+}
+
+func (mailer *SMTPMailer) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         mailer.Host,
+		RootCAs:            mailer.RootCAs,
+		InsecureSkipVerify: mailer.InsecureSkipVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// setDeadline limits the next command by CommandTimeout and the deadline of ctx, whichever comes first.
+func (mailer *SMTPMailer) setDeadline(ctx context.Context) {
+	deadline := time.Now().Add(cmp.Or(mailer.CommandTimeout, DefaultSMTPCommandTimeout))
+
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	_ = mailer.conn.SetDeadline(deadline)
+}
+
+func (mailer *SMTPMailer) closeConn() {
+	if mailer.client == nil {
+		return
+	}
+
+	_ = mailer.conn.SetDeadline(time.Now().Add(time.Second))
+	_ = mailer.client.Quit()
+	_ = mailer.client.Close()
+
+	mailer.client = nil
+	mailer.conn = nil
+}
+
+// Close closes the kept connection.
+func (mailer *SMTPMailer) Close() {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	if mailer.idleTimer != nil {
+		mailer.idleTimer.Stop()
+	}
+
+	mailer.closeConn()
+}
+
+// withContextErr reports the error of ctx instead of the i/o timeout it caused. The connection deadline can pass a
+// moment before ctx notices its own.
+func withContextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", context.Cause(ctx), err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+
+	return err
+}
+
+// loginAuth implements the LOGIN mechanism, which some servers offer instead of PLAIN.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (auth *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, only send credentials over TLS or to localhost.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != auth.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (auth *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch string(fromServer) {
+	case "Username:", "User Name\x00":
+		return []byte(auth.username), nil
+	case "Password:", "Password\x00":
+		return []byte(auth.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
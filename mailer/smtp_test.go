@@ -0,0 +1,477 @@
+package mailer_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+// smtpServer is a local SMTP server that rejects the first failures messages with a temporary error and keeps the
+// data of the ones it accepts.
+type smtpServer struct {
+	// tlsConfig offers STARTTLS, or secures every connection when implicitTLS is set.
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	// username and password are required with AUTH when set.
+	username string
+	password string
+	// stall never answers the end of the message data.
+	stall bool
+
+	listener net.Listener
+
+	mu          sync.Mutex
+	failures    int
+	received    []string
+	secure      []bool
+	connections int
+}
+
+func newSMTPServer(t *testing.T, failures int) *smtpServer {
+	t.Helper()
+
+	return startSMTPServer(t, &smtpServer{failures: failures})
+}
+
+func startSMTPServer(t *testing.T, server *smtpServer) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	if server.implicitTLS {
+		listener = tls.NewListener(listener, server.tlsConfig)
+	}
+
+	server.listener = listener
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mu.Lock()
+			server.connections++
+			server.mu.Unlock()
+
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+// testCertificate returns a certificate for 127.0.0.1 and a pool that trusts it.
+func testCertificate(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	return &tls.Config{Certificates: ts.TLS.Certificates, MinVersion: tls.VersionTLS12}, pool
+}
+
+func (server *smtpServer) addr() (host, port string) {
+	host, port, _ = net.SplitHostPort(server.listener.Addr().String())
+
+	return host, port
+}
+
+func (server *smtpServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	tp := textproto.NewConn(conn)
+	secure := server.implicitTLS
+	authenticated := server.username == ""
+
+	_ = tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"localhost"}
+
+			if server.tlsConfig != nil && !secure {
+				extensions = append(extensions, "STARTTLS")
+			}
+
+			if server.username != "" {
+				extensions = append(extensions, "AUTH PLAIN LOGIN CRAM-MD5")
+			}
+
+			for i, extension := range extensions {
+				sep := "-"
+				if i == len(extensions)-1 {
+					sep = " "
+				}
+
+				_ = tp.PrintfLine("250%s%s", sep, extension)
+			}
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready")
+
+			tlsConn := tls.Server(conn, server.tlsConfig)
+
+			err = tlsConn.Handshake()
+			if err != nil {
+				return
+			}
+
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			if server.authenticate(tp, arg) {
+				authenticated = true
+
+				_ = tp.PrintfLine("235 authenticated")
+			} else {
+				_ = tp.PrintfLine("535 invalid credentials")
+			}
+		case "MAIL":
+			if !authenticated {
+				_ = tp.PrintfLine("530 authentication required")
+
+				continue
+			}
+
+			server.mu.Lock()
+			fail := server.failures > 0
+			if fail {
+				server.failures--
+			}
+			server.mu.Unlock()
+
+			if fail {
+				_ = tp.PrintfLine("451 try again later")
+
+				continue
+			}
+
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			if server.stall {
+				continue
+			}
+
+			server.mu.Lock()
+			server.received = append(server.received, string(data))
+			server.secure = append(server.secure, secure)
+			server.mu.Unlock()
+
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (server *smtpServer) authenticate(tp *textproto.Conn, arg string) bool {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+
+	readLine := func() string {
+		line, _ := tp.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+
+		return string(decoded)
+	}
+
+	challenge := func(s string) string {
+		_ = tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(s)))
+
+		return readLine()
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		decoded, _ := base64.StdEncoding.DecodeString(initial)
+
+		return string(decoded) == "\x00"+server.username+"\x00"+server.password
+	case "LOGIN":
+		return challenge("Username:") == server.username && challenge("Password:") == server.password
+	case "CRAM-MD5":
+		const nonce = "<1896.697170952@localhost>"
+
+		mac := hmac.New(md5.New, []byte(server.password))
+		mac.Write([]byte(nonce))
+
+		return challenge(nonce) == server.username+" "+hex.EncodeToString(mac.Sum(nil))
+	default:
+		return false
+	}
+}
+
+func (server *smtpServer) messages() []string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return append([]string(nil), server.received...)
+}
+
+func (server *smtpServer) stats() (connections int, secure []bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.connections, append([]bool(nil), server.secure...)
+}
+
+func newSMTPMailer(t *testing.T, server *smtpServer) *mailer.SMTPMailer {
+	t.Helper()
+
+	host, port := server.addr()
+
+	m := &mailer.SMTPMailer{Host: host, Port: port, From: "Blog <blog@example.com>"}
+
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func sendTestEmail(t *testing.T, m *mailer.SMTPMailer) error {
+	t.Helper()
+
+	return m.SendEmail(t.Context(), newMessage())
+}
+
+func TestSMTPMailerReusesConnection(t *testing.T) {
+	server := newSMTPServer(t, 0)
+	m := newSMTPMailer(t, server)
+
+	for range 3 {
+		err := sendTestEmail(t, m)
+		if err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+	}
+
+	connections, _ := server.stats()
+	if connections != 1 || len(server.messages()) != 3 {
+		t.Errorf("expected 3 emails over 1 connection, got %d over %d", len(server.messages()), connections)
+	}
+
+	m.Close()
+
+	err := sendTestEmail(t, m)
+	if err != nil {
+		t.Fatalf("failed to send email after close: %v", err)
+	}
+
+	connections, _ = server.stats()
+	if connections != 2 {
+		t.Errorf("expected a new connection after close, got %d connections", connections)
+	}
+}
+
+func TestSMTPMailerReconnectsAfterIdleTimeout(t *testing.T) {
+	server := newSMTPServer(t, 0)
+	m := newSMTPMailer(t, server)
+	m.IdleTimeout = 10 * time.Millisecond
+
+	for range 2 {
+		err := sendTestEmail(t, m)
+		if err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	connections, _ := server.stats()
+	if connections != 2 {
+		t.Errorf("expected the idle connection to be closed, got %d connections", connections)
+	}
+}
+
+func TestSMTPMailerTLSModes(t *testing.T) {
+	serverTLS, pool := testCertificate(t)
+
+	tests := []struct {
+		name        string
+		server      *smtpServer
+		mode        mailer.TLSMode
+		trust       bool
+		skipVerify  bool
+		wantErr     bool
+		wantSecured bool
+	}{
+		{name: "none ignores STARTTLS", server: &smtpServer{tlsConfig: serverTLS}, mode: mailer.TLSModeNone},
+		{name: "opportunistic without STARTTLS", server: &smtpServer{}, mode: mailer.TLSModeOpportunistic},
+		{
+			name:        "opportunistic with STARTTLS",
+			server:      &smtpServer{tlsConfig: serverTLS},
+			mode:        mailer.TLSModeOpportunistic,
+			trust:       true,
+			wantSecured: true,
+		},
+		{name: "required STARTTLS missing", server: &smtpServer{}, mode: mailer.TLSModeStartTLS, wantErr: true},
+		{
+			name:        "required STARTTLS",
+			server:      &smtpServer{tlsConfig: serverTLS},
+			mode:        mailer.TLSModeStartTLS,
+			trust:       true,
+			wantSecured: true,
+		},
+		{
+			name:    "STARTTLS with unknown CA",
+			server:  &smtpServer{tlsConfig: serverTLS},
+			mode:    mailer.TLSModeStartTLS,
+			wantErr: true,
+		},
+		{
+			name:        "implicit",
+			server:      &smtpServer{tlsConfig: serverTLS, implicitTLS: true},
+			mode:        mailer.TLSModeImplicit,
+			trust:       true,
+			wantSecured: true,
+		},
+		{
+			name:    "implicit with unknown CA",
+			server:  &smtpServer{tlsConfig: serverTLS, implicitTLS: true},
+			mode:    mailer.TLSModeImplicit,
+			wantErr: true,
+		},
+		{
+			name:        "implicit skipping verification",
+			server:      &smtpServer{tlsConfig: serverTLS, implicitTLS: true},
+			mode:        mailer.TLSModeImplicit,
+			skipVerify:  true,
+			wantSecured: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startSMTPServer(t, tt.server)
+			m := newSMTPMailer(t, server)
+			m.TLSMode = tt.mode
+			m.InsecureSkipVerify = tt.skipVerify
+
+			if tt.trust {
+				m.RootCAs = pool
+			}
+
+			err := sendTestEmail(t, m)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to send email: %v", err)
+			}
+
+			_, secure := server.stats()
+			if len(secure) != 1 || secure[0] != tt.wantSecured {
+				t.Errorf("expected secured %v, got %v", tt.wantSecured, secure)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	serverTLS, pool := testCertificate(t)
+
+	for _, mechanism := range []mailer.AuthMechanism{mailer.AuthPlain, mailer.AuthLogin, mailer.AuthCRAMMD5} {
+		t.Run(string(mechanism), func(t *testing.T) {
+			server := startSMTPServer(t, &smtpServer{tlsConfig: serverTLS, username: "blog", password: "secret"})
+
+			m := newSMTPMailer(t, server)
+			m.TLSMode = mailer.TLSModeStartTLS
+			m.RootCAs = pool
+			m.AuthMechanism = mechanism
+			m.Username = "blog"
+			m.Password = "wrong"
+
+			err := sendTestEmail(t, m)
+			if err == nil {
+				t.Fatal("expected an error with a wrong password")
+			}
+
+			m.Password = "secret"
+
+			err = sendTestEmail(t, m)
+			if err != nil {
+				t.Fatalf("failed to send email: %v", err)
+			}
+
+			if len(server.messages()) != 1 {
+				t.Errorf("expected 1 received message, got %d", len(server.messages()))
+			}
+		})
+	}
+}
+
+func TestSMTPMailerHonoursContext(t *testing.T) {
+	server := startSMTPServer(t, &smtpServer{stall: true})
+	m := newSMTPMailer(t, server)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	err := m.SendEmail(ctx, newMessage())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the send to stop with the context, took %s", elapsed)
+	}
+
+	m.CommandTimeout = 100 * time.Millisecond
+
+	err = m.SendEmail(t.Context(), newMessage())
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var embeddedTemplatesFS embed.FS
+
+const (
+	htmlLayout = "layout.gohtml"
+	textLayout = "layout.gotext"
+)
+
+// emailTemplate is an email with an HTML and a plain-text version. The text file defines the "subject" and both
+// files define the "content" that their layout wraps.
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var loadTemplates = sync.OnceValues(func() (map[string]*emailTemplate, error) {
+	names, err := fs.Glob(embeddedTemplatesFS, "templates/*.gotext")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email templates: %w", err)
+	}
+
+	templates := make(map[string]*emailTemplate, len(names))
+
+	for _, name := range names {
+		name = strings.TrimSuffix(path.Base(name), ".gotext")
+		if name == strings.TrimSuffix(textLayout, ".gotext") {
+			continue
+		}
+
+		html, err := htmltemplate.New(htmlLayout).
+			Option("missingkey=error").
+			ParseFS(embeddedTemplatesFS, "templates/"+htmlLayout, "templates/"+name+".gohtml")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse html email template %q: %w", name, err)
+		}
+
+		text, err := texttemplate.New(textLayout).
+			Option("missingkey=error").
+			ParseFS(embeddedTemplatesFS, "templates/"+textLayout, "templates/"+name+".gotext")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse text email template %q: %w", name, err)
+		}
+
+		templates[name] = &emailTemplate{html: html, text: text}
+	}
+
+	return templates, nil
+})
+
+// Render builds a message with the subject and the HTML and text parts of the named email template. The caller sets
+// the recipients.
+func Render(name string, data any) (*Message, error) {
+	templates, err := loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("email template %q not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+
+	err = tmpl.text.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render subject of email template %q: %w", name, err)
+	}
+
+	err = tmpl.text.Execute(&text, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render text of email template %q: %w", name, err)
+	}
+
+	err = tmpl.html.Execute(&html, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render html of email template %q: %w", name, err)
+	}
+
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,441 @@
+package newsletter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+const (
+	DefaultBatchSize = 100
+	// DefaultConfirmInterval is how long to wait before mailing another confirmation link to the same address.
+	DefaultConfirmInterval = 10 * time.Minute
+	// pendingTTL is how long a confirmation link works.
+	pendingTTL = 7 * 24 * time.Hour
+	// digestPostsLimit is the number of posts listed in one digest.
+	digestPostsLimit = 20
+)
+
+var (
+	ErrInvalidEmailAddress = errors.New("invalid email address")
+	ErrInvalidFrequency    = errors.New("invalid frequency")
+	ErrInvalidSignature    = errors.New("invalid link signature")
+)
+
+const (
+	purposeConfirm     = "confirm"
+	purposeUnsubscribe = "unsubscribe"
+)
+
+// Service manages double opt-in subscriptions and emails subscribers about new posts, right away or in daily or
+// weekly digests. Emails are sent through Mailer in batches of BatchSize.
+type Service struct {
+	SubscriptionRepo SubscriptionRepository
+	BlogSvc          *blog.Service
+	// Mailer is usually the outbox, which sends at the rate its transport allows.
+	Mailer mailer.Mailer
+	// SigningKey signs confirmation and unsubscribe links.
+	SigningKey []byte
+	// BaseURL is used to build the links in digests.
+	BaseURL   string
+	BatchSize int
+	// ConfirmInterval limits how often a confirmation link is mailed to an address, so the form cannot be used to
+	// flood someone with emails.
+	ConfirmInterval time.Duration
+
+	wake     chan struct{}
+	wakeOnce sync.Once
+}
+
+type CreateSubscriptionRequest struct {
+	EmailAddress string
+	// UserID ties the subscription to an account.
+	UserID    string
+	Frequency Frequency
+}
+
+// CreateSubscription records a pending subscription and mails a confirmation link that points at baseURL. Asking
+// again for a pending subscription sends a new link, at most once per ConfirmInterval. A confirmed subscription is only
+// changed for its own account,
+// so the form does not reveal who is subscribed.
+func (svc *Service) CreateSubscription(ctx context.Context, req *CreateSubscriptionRequest, baseURL string) error {
+	address, err := mail.ParseAddress(req.EmailAddress)
+	if err != nil {
+		return ErrInvalidEmailAddress
+	}
+
+	if !req.Frequency.IsValid() {
+		return ErrInvalidFrequency
+	}
+
+	emailAddress := strings.ToLower(address.Address)
+
+	sub, err := svc.SubscriptionRepo.GetByEmailAddress(ctx, emailAddress)
+	if err != nil && !errors.As(err, &SubscriptionByEmailNotFoundError{}) {
+		return fmt.Errorf("failed to get subscription by email address: %w", err)
+	}
+
+	switch {
+	case sub == nil:
+		sub = &Subscription{
+			ID:           uuid.NewString(),
+			EmailAddress: emailAddress,
+			UserID:       req.UserID,
+			Frequency:    req.Frequency,
+			Status:       StatusPending,
+			CreatedAt:    time.Now(),
+		}
+
+		err = svc.SubscriptionRepo.Create(ctx, sub)
+		if err != nil {
+			return fmt.Errorf("failed to create subscription: %w", err)
+		}
+	case sub.Status == StatusPending:
+		if time.Since(sub.CreatedAt) < svc.confirmInterval() {
+			return nil
+		}
+
+		// The subscription stays with the account that asked for it first, so others cannot take it over.
+		sub.Frequency = req.Frequency
+		// A new link extends the time to confirm.
+		sub.CreatedAt = time.Now()
+
+		err = svc.SubscriptionRepo.Update(ctx, sub)
+		if err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+	case req.UserID != "" && sub.UserID == req.UserID:
+		return svc.UpdateFrequency(ctx, sub.ID, req.Frequency)
+	default:
+		return nil
+	}
+
+	msg, err := mailer.Render("newsletter-confirm", map[string]any{
+		"Frequency":   string(sub.Frequency),
+		"ConfirmLink": baseURL + svc.ConfirmPath(sub.ID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render confirmation email: %w", err)
+	}
+
+	msg.To = []string{sub.EmailAddress}
+
+	err = svc.Mailer.SendEmail(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmPath returns the signed path that confirms a subscription.
+func (svc *Service) ConfirmPath(id string) string {
+	return svc.signedPath("/newsletter/confirm", purposeConfirm, id)
+}
+
+// UnsubscribePath returns the signed path that deletes a subscription.
+func (svc *Service) UnsubscribePath(id string) string {
+	return svc.signedPath("/newsletter/unsubscribe", purposeUnsubscribe, id)
+}
+
+func (svc *Service) signedPath(path, purpose, id string) string {
+	query := url.Values{}
+	query.Set("id", id)
+	query.Set("signature", svc.sign(purpose, id))
+
+	return path + "?" + query.Encode()
+}
+
+func (svc *Service) sign(purpose, id string) string {
+	mac := hmac.New(sha256.New, svc.SigningKey)
+	mac.Write([]byte(purpose + "\n" + id))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (svc *Service) verify(purpose, id, signature string) error {
+	if !hmac.Equal([]byte(signature), []byte(svc.sign(purpose, id))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Confirm checks the signature of a confirmation link and confirms the subscription. Digests list the posts
+// published from now on.
+func (svc *Service) Confirm(ctx context.Context, id, signature string) error {
+	err := svc.verify(purposeConfirm, id, signature)
+	if err != nil {
+		return err
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if sub.Status == StatusConfirmed {
+		return nil
+	}
+
+	now := time.Now()
+	sub.Status = StatusConfirmed
+	sub.ConfirmedAt = &now
+	sub.LastSentAt = &now
+
+	err = svc.SubscriptionRepo.Update(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Unsubscribe checks the signature of an unsubscribe link and deletes the subscription. Following the link again is
+// not an error.
+func (svc *Service) Unsubscribe(ctx context.Context, id, signature string) error {
+	err := svc.verify(purposeUnsubscribe, id, signature)
+	if err != nil {
+		return err
+	}
+
+	err = svc.SubscriptionRepo.Delete(ctx, id)
+	if err != nil && !errors.As(err, &SubscriptionByIDNotFoundError{}) {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) GetSubscriptionByUserID(ctx context.Context, userID string) (*Subscription, error) {
+	sub, err := svc.SubscriptionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription by user id: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (svc *Service) UpdateFrequency(ctx context.Context, id string, frequency Frequency) error {
+	if !frequency.IsValid() {
+		return ErrInvalidFrequency
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	sub.Frequency = frequency
+
+	err = svc.SubscriptionRepo.Update(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) DeleteSubscription(ctx context.Context, id string) error {
+	err := svc.SubscriptionRepo.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe wakes the sender when a post is published, so immediate subscribers hear about it right away.
+func (svc *Service) Subscribe(bus *eventbus.Bus) {
+	eventbus.On(bus, func(_ context.Context, _ blog.PostPublished) error {
+		svc.notify()
+
+		return nil
+	})
+}
+
+func (svc *Service) wakeChan() chan struct{} {
+	svc.wakeOnce.Do(func() {
+		svc.wake = make(chan struct{}, 1)
+	})
+
+	return svc.wake
+}
+
+func (svc *Service) notify() {
+	select {
+	case svc.wakeChan() <- struct{}{}:
+	default:
+	}
+}
+
+func (svc *Service) confirmInterval() time.Duration {
+	if svc.ConfirmInterval <= 0 {
+		return DefaultConfirmInterval
+	}
+
+	return svc.ConfirmInterval
+}
+
+func (svc *Service) batchSize() int {
+	if svc.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+
+	return svc.BatchSize
+}
+
+// SendDigests emails the subscribers that are due the posts published since their last email, one batch at a time.
+func (svc *Service) SendDigests(ctx context.Context) error {
+	latest, err := svc.BlogSvc.ListPosts(ctx, blog.ListPostsParams{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to get latest post: %w", err)
+	}
+
+	if len(latest) == 0 {
+		return nil
+	}
+
+	for {
+		subs, err := svc.SubscriptionRepo.ListDue(ctx, latest[0].CreatedAt, time.Now(), svc.batchSize())
+		if err != nil {
+			return fmt.Errorf("failed to list due subscriptions: %w", err)
+		}
+
+		for _, sub := range subs {
+			err = svc.sendDigest(ctx, sub)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(subs) < svc.batchSize() {
+			return nil
+		}
+	}
+}
+
+func (svc *Service) sendDigest(ctx context.Context, sub *Subscription) error {
+	posts, err := svc.BlogSvc.ListPosts(ctx, blog.ListPostsParams{
+		Limit:        digestPostsLimit,
+		CreatedAfter: *sub.LastSentAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list posts: %w", err)
+	}
+
+	// The posts may have been deleted since; skip them either way.
+	if len(posts) > 0 {
+		msg, err := svc.digest(sub, posts)
+		if err != nil {
+			return err
+		}
+
+		err = svc.Mailer.SendEmail(ctx, msg)
+		if err != nil {
+			return fmt.Errorf("failed to send digest: %w", err)
+		}
+	}
+
+	now := time.Now()
+	sub.LastSentAt = &now
+
+	err = svc.SubscriptionRepo.Update(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	return nil
+}
+
+type digestPost struct {
+	Title   string
+	URL     string
+	Excerpt string
+}
+
+func (svc *Service) digest(sub *Subscription, posts []*blog.Post) (*mailer.Message, error) {
+	baseURL := strings.TrimSuffix(svc.BaseURL, "/")
+
+	items := make([]digestPost, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, digestPost{
+			Title:   post.Title,
+			URL:     baseURL + "/posts/" + url.PathEscape(post.Slug),
+			Excerpt: post.Excerpt,
+		})
+	}
+
+	unsubscribeLink := baseURL + svc.UnsubscribePath(sub.ID)
+
+	msg, err := mailer.Render("newsletter-digest", map[string]any{
+		"Posts":           items,
+		"Frequency":       string(sub.Frequency),
+		"UnsubscribeLink": unsubscribeLink,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	msg.To = []string{sub.EmailAddress}
+	msg.Headers = map[string]string{
+		// One-click unsubscribe as in RFC 8058.
+		"List-Unsubscribe":      "<" + unsubscribeLink + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	return msg, nil
+}
+
+// PurgePending deletes subscriptions that were never confirmed.
+func (svc *Service) PurgePending(ctx context.Context) error {
+	count, err := svc.SubscriptionRepo.PurgePending(ctx, time.Now().Add(-pendingTTL))
+	if err != nil {
+		return fmt.Errorf("failed to purge pending subscriptions: %w", err)
+	}
+
+	if count > 0 {
+		slog.InfoContext(ctx, "pending subscriptions purged", "count", count)
+	}
+
+	return nil
+}
+
+// RunSender sends digests on every tick and whenever a post is published, until ctx is done.
+func (svc *Service) RunSender(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := svc.SendDigests(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to send newsletter digests", "error", err)
+		}
+
+		err = svc.PurgePending(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge pending subscriptions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-svc.wakeChan():
+		}
+	}
+}
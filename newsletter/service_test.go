@@ -0,0 +1,412 @@
+package newsletter_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+)
+
+func newService(t *testing.T) (*newsletter.Service, *mailer.MemoryMailer) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	mailbox := &mailer.MemoryMailer{From: "blog@example.com"}
+
+	return &newsletter.Service{
+		SubscriptionRepo: &sqlite3.NewsletterSubscriptionRepo{DB: db},
+		BlogSvc:          &blog.Service{PostRepo: &sqlite3.PostRepo{DB: db}},
+		Mailer:           mailbox,
+		SigningKey:       []byte("test-signing-key"),
+		BaseURL:          "https://blog.example.com",
+	}, mailbox
+}
+
+func publish(t *testing.T, svc *newsletter.Service, title string) {
+	t.Helper()
+
+	now := time.Now()
+
+	err := svc.BlogSvc.PostRepo.Create(t.Context(), &blog.Post{
+		ID:        uuid.NewString(),
+		Title:     title,
+		Slug:      strings.ToLower(strings.ReplaceAll(title, " ", "-")),
+		Excerpt:   "About " + title,
+		Content:   "<p>" + title + "</p>",
+		Format:    blog.PostFormatHTML,
+		AuthorID:  uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+}
+
+var confirmLinkRegexp = regexp.MustCompile(`https://\S+/newsletter/confirm\?\S+`)
+
+// subscribe creates a subscription and confirms it with the link from the confirmation email.
+func subscribe(
+	t *testing.T,
+	svc *newsletter.Service,
+	mailbox *mailer.MemoryMailer,
+	emailAddress string,
+	frequency newsletter.Frequency,
+) *newsletter.Subscription {
+	t.Helper()
+
+	err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+		EmailAddress: emailAddress,
+		Frequency:    frequency,
+	}, "https://blog.example.com")
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	messages := mailbox.Messages()
+	if len(messages) == 0 || !slices.Contains(messages[0].Message.To, emailAddress) {
+		t.Fatalf("expected a confirmation email to %s", emailAddress)
+	}
+
+	link, err := url.Parse(confirmLinkRegexp.FindString(messages[0].Message.Text))
+	if err != nil {
+		t.Fatalf("failed to parse confirmation link: %v", err)
+	}
+
+	err = svc.Confirm(t.Context(), link.Query().Get("id"), link.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("failed to confirm subscription: %v", err)
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByID(t.Context(), link.Query().Get("id"))
+	if err != nil {
+		t.Fatalf("failed to get subscription: %v", err)
+	}
+
+	return sub
+}
+
+// sentTo returns the messages sent to emailAddress, newest first.
+func sentTo(mailbox *mailer.MemoryMailer, emailAddress string) []*mailer.Message {
+	var messages []*mailer.Message
+
+	for _, m := range mailbox.Messages() {
+		if slices.Contains(m.Message.To, emailAddress) {
+			messages = append(messages, m.Message)
+		}
+	}
+
+	return messages
+}
+
+func TestDigestIsSentAfterConfirmation(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+		EmailAddress: "Reader@Example.com",
+		Frequency:    newsletter.FrequencyImmediate,
+	}, "https://blog.example.com")
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByEmailAddress(t.Context(), "reader@example.com")
+	if err != nil {
+		t.Fatalf("failed to get subscription: %v", err)
+	}
+
+	err = svc.Confirm(t.Context(), sub.ID, strings.Repeat("0", 64))
+	if !errors.Is(err, newsletter.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	publish(t, svc, "Before Confirmation")
+
+	err = svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	if messages := sentTo(mailbox, "reader@example.com"); len(messages) != 1 {
+		t.Fatalf("expected only the confirmation email before confirming, got %d emails", len(messages))
+	}
+
+	// Asking again right away does not mail another link, and the first one works.
+	sub = subscribe(t, svc, mailbox, "reader@example.com", newsletter.FrequencyImmediate)
+	if sub.Status != newsletter.StatusConfirmed {
+		t.Fatalf("expected a confirmed subscription, got %q", sub.Status)
+	}
+
+	// Posts published before the confirmation are not sent.
+	err = svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	if messages := sentTo(mailbox, "reader@example.com"); len(messages) != 1 {
+		t.Fatalf("expected no digest for older posts, got %d emails", len(messages))
+	}
+
+	publish(t, svc, "Newsletter Launch")
+
+	err = svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	messages := sentTo(mailbox, "reader@example.com")
+	if len(messages) != 2 {
+		t.Fatalf("expected a digest, got %d emails", len(messages))
+	}
+
+	digest := messages[0]
+
+	if digest.Subject != "New post: Newsletter Launch" {
+		t.Errorf("unexpected subject %q", digest.Subject)
+	}
+
+	if !strings.Contains(digest.Text, "https://blog.example.com/posts/newsletter-launch") {
+		t.Errorf("expected a link to the post in %q", digest.Text)
+	}
+
+	if strings.Contains(digest.Text, "Before Confirmation") {
+		t.Errorf("expected only new posts in %q", digest.Text)
+	}
+
+	unsubscribeLink := "https://blog.example.com" + svc.UnsubscribePath(sub.ID)
+
+	if digest.Headers["List-Unsubscribe"] != "<"+unsubscribeLink+">" ||
+		digest.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected list headers %v", digest.Headers)
+	}
+
+	// Nothing new, nothing sent.
+	err = svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	if messages := sentTo(mailbox, "reader@example.com"); len(messages) != 2 {
+		t.Errorf("expected no more digests, got %d emails", len(messages))
+	}
+}
+
+func TestDigestFrequency(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	subscribe(t, svc, mailbox, "immediate@example.com", newsletter.FrequencyImmediate)
+	daily := subscribe(t, svc, mailbox, "daily@example.com", newsletter.FrequencyDaily)
+	weekly := subscribe(t, svc, mailbox, "weekly@example.com", newsletter.FrequencyWeekly)
+
+	publish(t, svc, "First Post")
+
+	err := svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	if len(sentTo(mailbox, "immediate@example.com")) != 2 {
+		t.Errorf("expected a digest to the immediate subscriber")
+	}
+
+	if len(sentTo(mailbox, "daily@example.com")) != 1 || len(sentTo(mailbox, "weekly@example.com")) != 1 {
+		t.Errorf("expected no digest to the daily and weekly subscribers yet")
+	}
+
+	// A day later, the daily subscriber is due and the weekly one is not.
+	for _, sub := range []*newsletter.Subscription{daily, weekly} {
+		lastSentAt := sub.LastSentAt.Add(-25 * time.Hour)
+		sub.LastSentAt = &lastSentAt
+
+		err = svc.SubscriptionRepo.Update(t.Context(), sub)
+		if err != nil {
+			t.Fatalf("failed to update subscription: %v", err)
+		}
+	}
+
+	publish(t, svc, "Second Post")
+
+	err = svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	messages := sentTo(mailbox, "daily@example.com")
+	if len(messages) != 2 {
+		t.Fatalf("expected a digest to the daily subscriber, got %d emails", len(messages))
+	}
+
+	if messages[0].Subject != "2 new posts" {
+		t.Errorf("unexpected subject %q", messages[0].Subject)
+	}
+
+	if len(sentTo(mailbox, "weekly@example.com")) != 1 {
+		t.Errorf("expected no digest to the weekly subscriber yet")
+	}
+}
+
+func TestDigestsAreSentInBatches(t *testing.T) {
+	svc, mailbox := newService(t)
+	svc.BatchSize = 2
+
+	for i := range 5 {
+		subscribe(t, svc, mailbox, fmt.Sprintf("reader%d@example.com", i), newsletter.FrequencyImmediate)
+	}
+
+	publish(t, svc, "Newsletter Launch")
+
+	err := svc.SendDigests(t.Context())
+	if err != nil {
+		t.Fatalf("failed to send digests: %v", err)
+	}
+
+	for i := range 5 {
+		if len(sentTo(mailbox, fmt.Sprintf("reader%d@example.com", i))) != 2 {
+			t.Errorf("expected a digest to reader%d", i)
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	sub := subscribe(t, svc, mailbox, "reader@example.com", newsletter.FrequencyWeekly)
+
+	link, err := url.Parse(svc.UnsubscribePath(sub.ID))
+	if err != nil {
+		t.Fatalf("failed to parse unsubscribe link: %v", err)
+	}
+
+	// A confirmation signature does not unsubscribe.
+	confirmLink, err := url.Parse(svc.ConfirmPath(sub.ID))
+	if err != nil {
+		t.Fatalf("failed to parse confirmation link: %v", err)
+	}
+
+	err = svc.Unsubscribe(t.Context(), sub.ID, confirmLink.Query().Get("signature"))
+	if !errors.Is(err, newsletter.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	for range 2 {
+		err = svc.Unsubscribe(t.Context(), sub.ID, link.Query().Get("signature"))
+		if err != nil {
+			t.Fatalf("failed to unsubscribe: %v", err)
+		}
+	}
+
+	_, err = svc.SubscriptionRepo.GetByID(t.Context(), sub.ID)
+	if !errors.As(err, &newsletter.SubscriptionByIDNotFoundError{}) {
+		t.Errorf("expected the subscription to be deleted, got %v", err)
+	}
+}
+
+func TestConfirmedSubscriptionIsNotChangedByOthers(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	sub := subscribe(t, svc, mailbox, "reader@example.com", newsletter.FrequencyWeekly)
+
+	err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+		EmailAddress: "reader@example.com",
+		Frequency:    newsletter.FrequencyImmediate,
+	}, "https://blog.example.com")
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	got, err := svc.SubscriptionRepo.GetByID(t.Context(), sub.ID)
+	if err != nil {
+		t.Fatalf("failed to get subscription: %v", err)
+	}
+
+	if got.Frequency != newsletter.FrequencyWeekly || got.Status != newsletter.StatusConfirmed {
+		t.Errorf("expected the subscription to be unchanged, got %q %q", got.Frequency, got.Status)
+	}
+
+	if len(sentTo(mailbox, "reader@example.com")) != 1 {
+		t.Errorf("expected no new confirmation email")
+	}
+}
+
+func TestConfirmationEmailsAreThrottled(t *testing.T) {
+	svc, mailbox := newService(t)
+	svc.ConfirmInterval = 50 * time.Millisecond
+
+	for range 2 {
+		err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+			EmailAddress: "reader@example.com",
+			Frequency:    newsletter.FrequencyWeekly,
+		}, "https://blog.example.com")
+		if err != nil {
+			t.Fatalf("failed to create subscription: %v", err)
+		}
+	}
+
+	if messages := sentTo(mailbox, "reader@example.com"); len(messages) != 1 {
+		t.Fatalf("expected one confirmation email, got %d", len(messages))
+	}
+
+	time.Sleep(svc.ConfirmInterval)
+
+	err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+		EmailAddress: "reader@example.com",
+		Frequency:    newsletter.FrequencyWeekly,
+	}, "https://blog.example.com")
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	if messages := sentTo(mailbox, "reader@example.com"); len(messages) != 2 {
+		t.Errorf("expected a new confirmation email after the interval, got %d", len(messages))
+	}
+}
+
+func TestPendingSubscriptionIsNotTakenOver(t *testing.T) {
+	svc, _ := newService(t)
+	svc.ConfirmInterval = time.Nanosecond
+
+	for _, userID := range []string{"reader-id", "other-id", ""} {
+		err := svc.CreateSubscription(t.Context(), &newsletter.CreateSubscriptionRequest{
+			EmailAddress: "reader@example.com",
+			UserID:       userID,
+			Frequency:    newsletter.FrequencyWeekly,
+		}, "https://blog.example.com")
+		if err != nil {
+			t.Fatalf("failed to create subscription: %v", err)
+		}
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByEmailAddress(t.Context(), "reader@example.com")
+	if err != nil {
+		t.Fatalf("failed to get subscription: %v", err)
+	}
+
+	if sub.UserID != "reader-id" {
+		t.Errorf("expected the subscription to stay with reader-id, got %q", sub.UserID)
+	}
+}
@@ -0,0 +1,95 @@
+package newsletter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Frequency is how often a subscriber gets an email about new posts.
+type Frequency string
+
+const (
+	FrequencyImmediate Frequency = "immediate"
+	FrequencyDaily     Frequency = "daily"
+	FrequencyWeekly    Frequency = "weekly"
+)
+
+var Frequencies = []Frequency{FrequencyImmediate, FrequencyDaily, FrequencyWeekly}
+
+func (f Frequency) IsValid() bool {
+	return slices.Contains(Frequencies, f)
+}
+
+// Period is the least time between two emails.
+func (f Frequency) Period() time.Duration {
+	switch f {
+	case FrequencyDaily:
+		return 24 * time.Hour
+	case FrequencyWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+type Status string
+
+const (
+	// StatusPending subscriptions wait for the subscriber to follow the confirmation link.
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+)
+
+type Subscription struct {
+	ID           string
+	EmailAddress string
+	// UserID is set for subscriptions tied to an account.
+	UserID      string
+	Frequency   Frequency
+	Status      Status
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	// LastSentAt is when the last digest was sent, or when the subscription was confirmed. The next digest lists the
+	// posts published after it.
+	LastSentAt *time.Time
+}
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, sub *Subscription) (err error)
+	Update(ctx context.Context, sub *Subscription) (err error)
+	Delete(ctx context.Context, id string) (err error)
+	GetByID(ctx context.Context, id string) (sub *Subscription, err error)
+	GetByEmailAddress(ctx context.Context, emailAddress string) (sub *Subscription, err error)
+	GetByUserID(ctx context.Context, userID string) (sub *Subscription, err error)
+	// ListDue returns confirmed subscriptions that were last sent before postedAt and whose frequency allows an email
+	// at now, least recently sent first.
+	ListDue(ctx context.Context, postedAt, now time.Time, limit int) (subs []*Subscription, err error)
+	// PurgePending deletes pending subscriptions created before the given time.
+	PurgePending(ctx context.Context, createdBefore time.Time) (count int, err error)
+}
+
+type SubscriptionByIDNotFoundError struct {
+	ID string
+}
+
+func (err SubscriptionByIDNotFoundError) Error() string {
+	return fmt.Sprintf("subscription with ID %q not found", err.ID)
+}
+
+type SubscriptionByEmailNotFoundError struct {
+	EmailAddress string
+}
+
+func (err SubscriptionByEmailNotFoundError) Error() string {
+	return fmt.Sprintf("subscription with email address %q not found", err.EmailAddress)
+}
+
+type SubscriptionByUserIDNotFoundError struct {
+	UserID string
+}
+
+func (err SubscriptionByUserIDNotFoundError) Error() string {
+	return fmt.Sprintf("subscription of user %q not found", err.UserID)
+}
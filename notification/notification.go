@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Notification is an entry in the notification center of a user.
+type Notification struct {
+	ID     string
+	UserID string
+	Type   Type
+	// ActorID is the user who caused the notification.
+	ActorID string
+	// Text and URL are rendered when the notification is created, so it reads the same after the post or comment
+	// changes or is deleted.
+	Text      string
+	URL       string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+type ListNotificationsParams struct {
+	UserID string
+	Unread bool
+	Limit  int
+	Offset int
+}
+
+type Repository interface {
+	Create(ctx context.Context, n *Notification) (err error)
+	GetByID(ctx context.Context, id string) (n *Notification, err error)
+	// List returns notifications newest first.
+	List(ctx context.Context, params ListNotificationsParams) (notifications []*Notification, err error)
+	Count(ctx context.Context, params ListNotificationsParams) (count int, err error)
+	// MarkRead marks a notification of the user as read.
+	MarkRead(ctx context.Context, userID, id string, readAt time.Time) (err error)
+	MarkAllRead(ctx context.Context, userID string, readAt time.Time) (err error)
+}
+
+type NotificationByIDNotFoundError struct {
+	ID string
+}
+
+func (err NotificationByIDNotFoundError) Error() string {
+	return fmt.Sprintf("notification by id '%s' not found", err.ID)
+}
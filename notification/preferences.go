@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Type is the kind of activity a user is notified about.
+type Type string
+
+const (
+	// TypeComment is a new comment on one of the user's posts.
+	TypeComment Type = "comment"
+	// TypeReply is a reply to a comment of the user.
+	TypeReply Type = "reply"
+	// TypeMention is a comment that mentions the user by @username.
+	TypeMention Type = "mention"
+	// TypeCommentApproved is a comment of the user that a moderator approved.
+	TypeCommentApproved Type = "comment_approved"
+	// TypeCommentRejected is a comment of the user that a moderator deleted.
+	TypeCommentRejected Type = "comment_rejected"
+)
+
+// EmailTypes are the types users get emails about and can turn off. Moderation outcomes are only shown in the app.
+var EmailTypes = []Type{TypeComment, TypeReply, TypeMention}
+
+func (t Type) IsEmailed() bool {
+	return slices.Contains(EmailTypes, t)
+}
+
+// Preferences tells which notifications a user gets by email.
+type Preferences struct {
+	UserID        string
+	EmailComments bool
+	EmailReplies  bool
+	EmailMentions bool
+	UpdatedAt     time.Time
+}
+
+// DefaultPreferences are the preferences of users who have not changed them: every email is on.
+func DefaultPreferences(userID string) *Preferences {
+	return &Preferences{
+		UserID:        userID,
+		EmailComments: true,
+		EmailReplies:  true,
+		EmailMentions: true,
+	}
+}
+
+// Email reports whether the user gets notifications of type t by email.
+func (prefs *Preferences) Email(t Type) bool {
+	switch t {
+	case TypeComment:
+		return prefs.EmailComments
+	case TypeReply:
+		return prefs.EmailReplies
+	case TypeMention:
+		return prefs.EmailMentions
+	default:
+		return false
+	}
+}
+
+func (prefs *Preferences) SetEmail(t Type, enabled bool) {
+	switch t {
+	case TypeComment:
+		prefs.EmailComments = enabled
+	case TypeReply:
+		prefs.EmailReplies = enabled
+	case TypeMention:
+		prefs.EmailMentions = enabled
+	}
+}
+
+type PreferencesRepository interface {
+	Get(ctx context.Context, userID string) (prefs *Preferences, err error)
+	// Save creates or replaces the preferences of a user.
+	Save(ctx context.Context, prefs *Preferences) (err error)
+}
+
+type PreferencesByUserIDNotFoundError struct {
+	UserID string
+}
+
+func (err PreferencesByUserIDNotFoundError) Error() string {
+	return fmt.Sprintf("notification preferences for user '%s' not found", err.UserID)
+}
@@ -0,0 +1,474 @@
+package notification
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+var (
+	ErrInvalidType      = errors.New("invalid notification type")
+	ErrInvalidSignature = errors.New("invalid unsubscribe link signature")
+)
+
+// Service keeps the notification center of users and emails them about activity on their posts and comments, as
+// their preferences allow.
+type Service struct {
+	NotificationRepo Repository
+	PreferencesRepo  PreferencesRepository
+	AuthSvc          *auth.Service
+	BlogSvc          *blog.Service
+	Mailer           mailer.Mailer
+	// SigningKey signs unsubscribe links.
+	SigningKey []byte
+	// BaseURL is used to build the links in emails.
+	BaseURL string
+}
+
+// GetPreferences returns the preferences of a user, or the defaults if the user has not changed them.
+func (svc *Service) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	prefs, err := svc.PreferencesRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.As(err, &PreferencesByUserIDNotFoundError{}) {
+			return DefaultPreferences(userID), nil
+		}
+
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+func (svc *Service) UpdatePreferences(ctx context.Context, prefs *Preferences) error {
+	prefs.UpdatedAt = time.Now()
+
+	err := svc.PreferencesRepo.Save(ctx, prefs)
+	if err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+
+	return nil
+}
+
+// UnsubscribePath returns the signed path that turns off emails of type t for a user.
+func (svc *Service) UnsubscribePath(userID string, t Type) string {
+	query := url.Values{}
+	query.Set("user", userID)
+	query.Set("type", string(t))
+	query.Set("signature", svc.sign(userID, t))
+
+	return "/notifications/unsubscribe?" + query.Encode()
+}
+
+func (svc *Service) sign(userID string, t Type) string {
+	mac := hmac.New(sha256.New, svc.SigningKey)
+	mac.Write([]byte(string(t) + "\n" + userID))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Unsubscribe checks the signature of an unsubscribe link and turns off emails of type t for the user, so it works
+// without signing in.
+func (svc *Service) Unsubscribe(ctx context.Context, userID string, t Type, signature string) error {
+	if !t.IsEmailed() {
+		return ErrInvalidType
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(svc.sign(userID, t))) {
+		return ErrInvalidSignature
+	}
+
+	prefs, err := svc.GetPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	prefs.SetEmail(t, false)
+
+	return svc.UpdatePreferences(ctx, prefs)
+}
+
+// Subscribe notifies in the background about comments when they are created, approved or deleted, and about
+// mentions in posts when they are published or updated.
+func (svc *Service) Subscribe(bus *eventbus.Bus) {
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.PostPublished) error {
+		return svc.NotifyPostMentions(ctx, &event.Post, event.Post.CreatedAt)
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.PostUpdated) error {
+		return svc.NotifyPostMentions(ctx, &event.Post, event.MentionsSavedAt)
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.CommentCreated) error {
+		return svc.NotifyComment(ctx, &event.Comment)
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.CommentApproved) error {
+		return errors.Join(
+			svc.NotifyModeration(ctx, &event.Comment, event.ModeratorID, TypeCommentApproved),
+			svc.NotifyComment(ctx, &event.Comment),
+		)
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.CommentDeleted) error {
+		return svc.NotifyModeration(ctx, &event.Comment, event.ModeratorID, TypeCommentRejected)
+	})
+}
+
+// NotifyComment notifies the users that are mentioned in an approved comment, the author of the post and the earlier
+// commenters on the post. Each user gets at most one notification, for the most specific reason.
+func (svc *Service) NotifyComment(ctx context.Context, comment *blog.Comment) error {
+	if comment.Status != blog.CommentStatusApproved {
+		return nil
+	}
+
+	post, err := svc.BlogSvc.GetPostByID(ctx, comment.PostID)
+	if err != nil {
+		return fmt.Errorf("failed to get post: %w", err)
+	}
+
+	commenter, err := svc.AuthSvc.GetUserByID(ctx, comment.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get commenter: %w", err)
+	}
+
+	recipients, err := svc.recipients(ctx, comment, post)
+	if err != nil {
+		return err
+	}
+
+	commenterName := cmp.Or(commenter.Name, commenter.Username)
+
+	var errs []error
+
+	for _, recipient := range recipients {
+		n := &Notification{
+			ID:        uuid.NewString(),
+			UserID:    recipient.userID,
+			Type:      recipient.reason,
+			ActorID:   commenter.ID,
+			Text:      commentText(recipient.reason, commenterName, post.Title),
+			URL:       postPath(post) + "#comment-" + comment.ID,
+			CreatedAt: time.Now(),
+		}
+
+		err = svc.notify(ctx, n, commenter, comment.Content)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify user %s: %w", recipient.userID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type recipient struct {
+	userID string
+	reason Type
+}
+
+func (svc *Service) recipients(ctx context.Context, comment *blog.Comment, post *blog.Post) ([]recipient, error) {
+	var recipients []recipient
+
+	seen := map[string]bool{comment.UserID: true, auth.DeletedUserID: true}
+
+	add := func(userID string, reason Type) {
+		if !seen[userID] {
+			seen[userID] = true
+
+			recipients = append(recipients, recipient{userID: userID, reason: reason})
+		}
+	}
+
+	mentioned, err := svc.mentionedUsers(ctx, comment.PostID, comment.ID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range mentioned {
+		add(user.ID, TypeMention)
+	}
+
+	if comment.ParentID != "" {
+		parent, err := svc.BlogSvc.GetCommentByID(ctx, comment.ParentID)
+		if err != nil && !errors.As(err, &blog.CommentByIDNotFoundError{}) {
+			return nil, fmt.Errorf("failed to get parent comment: %w", err)
+		}
+
+		// The parent may have been deleted since.
+		if parent != nil {
+			add(parent.UserID, TypeReply)
+		}
+	}
+
+	add(post.AuthorID, TypeComment)
+
+	return recipients, nil
+}
+
+// mentionedUsers returns the existing users mentioned in a post, or in one of its comments when commentID is not
+// empty. When savedAt is not zero, only the mentions saved at that time are included, which are the ones a write
+// added.
+func (svc *Service) mentionedUsers(
+	ctx context.Context,
+	postID, commentID string,
+	savedAt time.Time,
+) ([]*auth.User, error) {
+	mentions, err := svc.BlogSvc.ListMentions(ctx, postID, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+
+	var users []*auth.User
+
+	for _, mention := range mentions {
+		if !savedAt.IsZero() && !mention.CreatedAt.Equal(savedAt) {
+			continue
+		}
+
+		user, err := svc.AuthSvc.GetUserByUsername(ctx, mention.Username)
+		if err != nil {
+			if errors.As(err, &auth.UserByUsernameNotFoundError{}) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get mentioned user: %w", err)
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// NotifyPostMentions notifies the users whose mentions in a post were saved at savedAt, so that editing a post only
+// notifies the users it newly mentions.
+func (svc *Service) NotifyPostMentions(ctx context.Context, post *blog.Post, savedAt time.Time) error {
+	if savedAt.IsZero() {
+		return nil
+	}
+
+	mentioned, err := svc.mentionedUsers(ctx, post.ID, "", savedAt)
+	if err != nil {
+		return err
+	}
+
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	author, err := svc.AuthSvc.GetUserByID(ctx, post.AuthorID)
+	if err != nil {
+		return fmt.Errorf("failed to get author: %w", err)
+	}
+
+	authorName := cmp.Or(author.Name, author.Username)
+
+	var errs []error
+
+	for _, user := range mentioned {
+		if user.ID == author.ID || user.ID == auth.DeletedUserID {
+			continue
+		}
+
+		n := &Notification{
+			ID:        uuid.NewString(),
+			UserID:    user.ID,
+			Type:      TypeMention,
+			ActorID:   author.ID,
+			Text:      fmt.Sprintf(`%s mentioned you in "%s"`, authorName, post.Title),
+			URL:       postPath(post),
+			CreatedAt: time.Now(),
+		}
+
+		err = svc.notify(ctx, n, author, post.Excerpt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify user %s: %w", user.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NotifyModeration tells the author of a comment that a moderator approved or deleted it. Authors are not notified
+// about what they do to their own comments.
+func (svc *Service) NotifyModeration(ctx context.Context, comment *blog.Comment, moderatorID string, t Type) error {
+	if moderatorID == "" || moderatorID == comment.UserID || comment.UserID == auth.DeletedUserID {
+		return nil
+	}
+
+	post, err := svc.BlogSvc.GetPostByID(ctx, comment.PostID)
+	if err != nil {
+		return fmt.Errorf("failed to get post: %w", err)
+	}
+
+	n := &Notification{
+		ID:        uuid.NewString(),
+		UserID:    comment.UserID,
+		Type:      t,
+		ActorID:   moderatorID,
+		URL:       postPath(post),
+		CreatedAt: time.Now(),
+	}
+
+	switch {
+	case t == TypeCommentApproved:
+		n.Text = fmt.Sprintf(`Your comment on "%s" was approved`, post.Title)
+		n.URL += "#comment-" + comment.ID
+	case comment.Status == blog.CommentStatusPending:
+		n.Text = fmt.Sprintf(`Your comment on "%s" was not approved`, post.Title)
+	default:
+		n.Text = fmt.Sprintf(`Your comment on "%s" was removed by a moderator`, post.Title)
+	}
+
+	err = svc.NotificationRepo.Create(ctx, n)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return nil
+}
+
+func postPath(post *blog.Post) string {
+	return "/posts/" + url.PathEscape(post.Slug)
+}
+
+// commentText is the text of a notification about a comment.
+func commentText(t Type, commenterName, postTitle string) string {
+	switch t {
+	case TypeMention:
+		return fmt.Sprintf(`%s mentioned you on "%s"`, commenterName, postTitle)
+	case TypeReply:
+		return fmt.Sprintf(`%s replied to your comment on "%s"`, commenterName, postTitle)
+	default:
+		return fmt.Sprintf(`%s commented on "%s"`, commenterName, postTitle)
+	}
+}
+
+// notify creates a notification and emails it with the content it is about.
+func (svc *Service) notify(ctx context.Context, n *Notification, actor *auth.User, content string) error {
+	err := svc.NotificationRepo.Create(ctx, n)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return svc.email(ctx, n, actor, content)
+}
+
+// email sends a notification by email, unless the user turned emails of its type off.
+func (svc *Service) email(ctx context.Context, n *Notification, actor *auth.User, content string) error {
+	prefs, err := svc.GetPreferences(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !prefs.Email(n.Type) {
+		return nil
+	}
+
+	user, err := svc.AuthSvc.GetUserByID(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.IsDisabled() || user.IsBanned(time.Now()) {
+		return nil
+	}
+
+	baseURL := strings.TrimSuffix(svc.BaseURL, "/")
+	unsubscribeLink := baseURL + svc.UnsubscribePath(n.UserID, n.Type)
+
+	msg, err := mailer.Render("comment-notification", map[string]any{
+		"Summary":         n.Text,
+		"ActorName":       cmp.Or(actor.Name, actor.Username),
+		"URL":             baseURL + n.URL,
+		"Excerpt":         svc.BlogSvc.PlainText(content),
+		"UnsubscribeLink": unsubscribeLink,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render notification email: %w", err)
+	}
+
+	msg.To = []string{user.EmailAddress}
+	msg.Headers = map[string]string{
+		// One-click unsubscribe as in RFC 8058.
+		"List-Unsubscribe":      "<" + unsubscribeLink + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	err = svc.Mailer.SendEmail(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send notification email: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) ListNotifications(
+	ctx context.Context,
+	params ListNotificationsParams,
+) ([]*Notification, error) {
+	notifications, err := svc.NotificationRepo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// GetNotification gets a notification of the user.
+func (svc *Service) GetNotification(ctx context.Context, userID, id string) (*Notification, error) {
+	n, err := svc.NotificationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+
+	if n.UserID != userID {
+		return nil, fmt.Errorf("failed to get notification: %w", NotificationByIDNotFoundError{ID: id})
+	}
+
+	return n, nil
+}
+
+func (svc *Service) CountNotifications(ctx context.Context, params ListNotificationsParams) (int, error) {
+	count, err := svc.NotificationRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+func (svc *Service) CountUnread(ctx context.Context, userID string) (int, error) {
+	return svc.CountNotifications(ctx, ListNotificationsParams{UserID: userID, Unread: true})
+}
+
+func (svc *Service) MarkRead(ctx context.Context, userID, id string) error {
+	err := svc.NotificationRepo.MarkRead(ctx, userID, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark notification as read: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) MarkAllRead(ctx context.Context, userID string) error {
+	err := svc.NotificationRepo.MarkAllRead(ctx, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark notifications as read: %w", err)
+	}
+
+	return nil
+}
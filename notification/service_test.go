@@ -0,0 +1,616 @@
+package notification_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+)
+
+func newService(t *testing.T) (*notification.Service, *mailer.MemoryMailer) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	mailbox := &mailer.MemoryMailer{From: "blog@example.com"}
+
+	authSvc := &auth.Service{UserRepo: &sqlite3.UserRepo{DB: db}}
+
+	return &notification.Service{
+		NotificationRepo: &sqlite3.NotificationRepo{DB: db},
+		PreferencesRepo:  &sqlite3.NotificationPreferencesRepo{DB: db},
+		AuthSvc:          authSvc,
+		BlogSvc: &blog.Service{
+			PostRepo:    &sqlite3.PostRepo{DB: db},
+			CommentRepo: &sqlite3.CommentRepo{DB: db},
+			MentionRepo: &sqlite3.MentionRepo{DB: db},
+			UserChecker: authSvc,
+			TextPolicy:  bluemonday.StrictPolicy(),
+		},
+		Mailer:     mailbox,
+		SigningKey: []byte("test-signing-key"),
+		BaseURL:    "https://blog.example.com",
+	}, mailbox
+}
+
+func createUser(t *testing.T, svc *notification.Service, username string) *auth.User {
+	t.Helper()
+
+	now := time.Now()
+
+	user := &auth.User{
+		ID:           uuid.NewString(),
+		Username:     username,
+		EmailAddress: username + "@example.com",
+		Name:         username,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err := svc.AuthSvc.UserRepo.Create(t.Context(), user)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	return user
+}
+
+func createPost(t *testing.T, svc *notification.Service, author *auth.User) *blog.Post {
+	t.Helper()
+
+	now := time.Now()
+
+	post := &blog.Post{
+		ID:        uuid.NewString(),
+		Title:     "Notifications",
+		Slug:      "notifications-" + uuid.NewString(),
+		Content:   "<p>Notifications</p>",
+		Format:    blog.PostFormatHTML,
+		AuthorID:  author.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := svc.BlogSvc.PostRepo.Create(t.Context(), post)
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	return post
+}
+
+func createComment(
+	t *testing.T,
+	svc *notification.Service,
+	post *blog.Post,
+	user *auth.User,
+	content string,
+	status blog.CommentStatus,
+) *blog.Comment {
+	t.Helper()
+
+	now := time.Now()
+
+	content, mentioned, err := blog.LinkMentions(t.Context(), content, svc.AuthSvc)
+	if err != nil {
+		t.Fatalf("failed to link mentions: %v", err)
+	}
+
+	comment := &blog.Comment{
+		ID:        uuid.NewString(),
+		PostID:    post.ID,
+		UserID:    user.ID,
+		Content:   content,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = svc.BlogSvc.CommentRepo.Create(t.Context(), comment)
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	err = svc.BlogSvc.MentionRepo.Replace(t.Context(), post.ID, comment.ID, mentioned, now)
+	if err != nil {
+		t.Fatalf("failed to save mentions: %v", err)
+	}
+
+	return comment
+}
+
+// createReply creates an approved reply to parent, without mentions.
+func createReply(t *testing.T, svc *notification.Service, parent *blog.Comment, user *auth.User) *blog.Comment {
+	t.Helper()
+
+	now := time.Now()
+
+	comment := &blog.Comment{
+		ID:        uuid.NewString(),
+		PostID:    parent.PostID,
+		ParentID:  parent.ID,
+		UserID:    user.ID,
+		Content:   "<p>Agreed</p>",
+		Status:    blog.CommentStatusApproved,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := svc.BlogSvc.CommentRepo.Create(t.Context(), comment)
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+
+	return comment
+}
+
+// subjects returns the subjects of the emails sent to user.
+func subjects(mailbox *mailer.MemoryMailer, user *auth.User) []string {
+	var subjects []string
+
+	for _, m := range mailbox.Messages() {
+		if slices.Contains(m.Message.To, user.EmailAddress) {
+			subjects = append(subjects, m.Message.Subject)
+		}
+	}
+
+	return subjects
+}
+
+func TestNotifyComment(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	earlier := createUser(t, svc, "earlier")
+	commenter := createUser(t, svc, "commenter")
+	mentioned := createUser(t, svc, "mentioned")
+
+	post := createPost(t, svc, author)
+
+	createComment(t, svc, post, earlier, "<p>First!</p>", blog.CommentStatusApproved)
+
+	comment := createComment(
+		t,
+		svc,
+		post,
+		commenter,
+		"<p>Thanks @mentioned and @author, see @nobody &amp; mail me at commenter@example.com</p>",
+		blog.CommentStatusApproved,
+	)
+
+	err := svc.NotifyComment(t.Context(), comment)
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	// The author is mentioned too, and gets one email for the mention.
+	if got := subjects(mailbox, author); !slices.Equal(got, []string{`commenter mentioned you on "Notifications"`}) {
+		t.Errorf("unexpected emails to the author: %q", got)
+	}
+
+	if got := subjects(mailbox, mentioned); !slices.Equal(got, []string{`commenter mentioned you on "Notifications"`}) {
+		t.Errorf("unexpected emails to the mentioned user: %q", got)
+	}
+
+	// Comments on the same post are not replies to the earlier one.
+	if got := subjects(mailbox, earlier); len(got) != 0 {
+		t.Errorf("expected no email to the earlier commenter, got %q", got)
+	}
+
+	if got := subjects(mailbox, commenter); len(got) != 0 {
+		t.Errorf("expected no email to the commenter, got %q", got)
+	}
+
+	msg := mailbox.Messages()[0].Message
+
+	if msg.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("expected a one-click unsubscribe header, got %v", msg.Headers)
+	}
+
+	link := "https://blog.example.com/posts/" + post.Slug + "#comment-" + comment.ID
+	if !strings.Contains(msg.Text, link) || !strings.Contains(msg.Text, "see @nobody & mail me") {
+		t.Errorf("expected the comment and a link to it in %q", msg.Text)
+	}
+}
+
+func TestNotifyCommentDoesNotEmailBannedUsers(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	commenter := createUser(t, svc, "commenter")
+
+	bannedUntil := time.Now().Add(time.Hour)
+
+	err := svc.AuthSvc.UserRepo.SetBannedUntil(t.Context(), author.ID, &bannedUntil)
+	if err != nil {
+		t.Fatalf("failed to ban user: %v", err)
+	}
+
+	post := createPost(t, svc, author)
+	comment := createComment(t, svc, post, commenter, "<p>Hello</p>", blog.CommentStatusApproved)
+
+	err = svc.NotifyComment(t.Context(), comment)
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	if count, _ := svc.CountUnread(t.Context(), author.ID); count != 1 {
+		t.Errorf("expected the banned author to be notified in the app, got %d notifications", count)
+	}
+
+	if got := subjects(mailbox, author); len(got) != 0 {
+		t.Errorf("expected no email to the banned author, got %q", got)
+	}
+}
+
+func TestNotifyReply(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	earlier := createUser(t, svc, "earlier")
+	other := createUser(t, svc, "other")
+	replier := createUser(t, svc, "replier")
+
+	post := createPost(t, svc, author)
+
+	parent := createComment(t, svc, post, earlier, "<p>First!</p>", blog.CommentStatusApproved)
+	createComment(t, svc, post, other, "<p>Second!</p>", blog.CommentStatusApproved)
+
+	err := svc.NotifyComment(t.Context(), createReply(t, svc, parent, replier))
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	want := []string{`replier replied to your comment on "Notifications"`}
+	if got := subjects(mailbox, earlier); !slices.Equal(got, want) {
+		t.Errorf("unexpected emails to the parent commenter: %q", got)
+	}
+
+	if got := subjects(mailbox, other); len(got) != 0 {
+		t.Errorf("expected no email to the other commenter, got %q", got)
+	}
+
+	if got := subjects(mailbox, author); !slices.Equal(got, []string{`replier commented on "Notifications"`}) {
+		t.Errorf("unexpected emails to the author: %q", got)
+	}
+
+	// Replies to a comment of the author count as replies, and only one email is sent.
+	err = svc.NotifyComment(t.Context(), createReply(t, svc, createComment(
+		t,
+		svc,
+		post,
+		author,
+		"<p>Thanks all</p>",
+		blog.CommentStatusApproved,
+	), replier))
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	if got := subjects(mailbox, author); len(got) != 2 ||
+		got[0] != `replier replied to your comment on "Notifications"` {
+		t.Errorf("unexpected emails to the author: %q", got)
+	}
+}
+
+func TestNotifyCommentRespectsPreferences(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	commenter := createUser(t, svc, "commenter")
+
+	post := createPost(t, svc, author)
+
+	pending := createComment(t, svc, post, commenter, "<p>Held</p>", blog.CommentStatusPending)
+
+	err := svc.NotifyComment(t.Context(), pending)
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	if got := subjects(mailbox, author); len(got) != 0 {
+		t.Fatalf("expected no email about a pending comment, got %q", got)
+	}
+
+	comment := createComment(t, svc, post, commenter, "<p>Hello</p>", blog.CommentStatusApproved)
+
+	err = svc.NotifyComment(t.Context(), comment)
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	if got := subjects(mailbox, author); !slices.Equal(got, []string{`commenter commented on "Notifications"`}) {
+		t.Fatalf("unexpected emails to the author: %q", got)
+	}
+
+	link, err := url.Parse(svc.UnsubscribePath(author.ID, notification.TypeComment))
+	if err != nil {
+		t.Fatalf("failed to parse unsubscribe link: %v", err)
+	}
+
+	// The link is only good for its own type.
+	err = svc.Unsubscribe(t.Context(), author.ID, notification.TypeReply, link.Query().Get("signature"))
+	if !errors.Is(err, notification.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	err = svc.Unsubscribe(t.Context(), author.ID, notification.TypeComment, link.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+
+	prefs, err := svc.GetPreferences(t.Context(), author.ID)
+	if err != nil {
+		t.Fatalf("failed to get preferences: %v", err)
+	}
+
+	if prefs.EmailComments || !prefs.EmailReplies || !prefs.EmailMentions {
+		t.Errorf("expected only comment emails to be off, got %+v", prefs)
+	}
+
+	err = svc.NotifyComment(
+		t.Context(),
+		createComment(t, svc, post, commenter, "<p>Again</p>", blog.CommentStatusApproved),
+	)
+	if err != nil {
+		t.Fatalf("failed to notify comment: %v", err)
+	}
+
+	if got := subjects(mailbox, author); len(got) != 1 {
+		t.Errorf("expected no more emails to the author, got %q", got)
+	}
+}
+
+func TestInAppNotifications(t *testing.T) {
+	svc, _ := newService(t)
+
+	author := createUser(t, svc, "author")
+	commenter := createUser(t, svc, "commenter")
+
+	post := createPost(t, svc, author)
+
+	// Notifications are created whatever the email preferences are.
+	prefs := notification.DefaultPreferences(author.ID)
+	prefs.EmailComments = false
+
+	err := svc.UpdatePreferences(t.Context(), prefs)
+	if err != nil {
+		t.Fatalf("failed to update preferences: %v", err)
+	}
+
+	for range 2 {
+		err = svc.NotifyComment(
+			t.Context(),
+			createComment(t, svc, post, commenter, "<p>Hello</p>", blog.CommentStatusApproved),
+		)
+		if err != nil {
+			t.Fatalf("failed to notify comment: %v", err)
+		}
+	}
+
+	notifications, err := svc.ListNotifications(
+		t.Context(),
+		notification.ListNotificationsParams{UserID: author.ID},
+	)
+	if err != nil {
+		t.Fatalf("failed to list notifications: %v", err)
+	}
+
+	if len(notifications) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(notifications))
+	}
+
+	n := notifications[0]
+	if n.Text != `commenter commented on "Notifications"` || n.ActorID != commenter.ID ||
+		!strings.HasPrefix(n.URL, "/posts/"+post.Slug+"#comment-") {
+		t.Errorf("unexpected notification %+v", n)
+	}
+
+	// Others cannot read or mark the notifications of the user.
+	_, err = svc.GetNotification(t.Context(), commenter.ID, n.ID)
+	if !errors.As(err, &notification.NotificationByIDNotFoundError{}) {
+		t.Errorf("expected NotificationByIDNotFoundError, got %v", err)
+	}
+
+	err = svc.MarkRead(t.Context(), commenter.ID, n.ID)
+	if !errors.As(err, &notification.NotificationByIDNotFoundError{}) {
+		t.Errorf("expected NotificationByIDNotFoundError, got %v", err)
+	}
+
+	err = svc.MarkRead(t.Context(), author.ID, n.ID)
+	if err != nil {
+		t.Fatalf("failed to mark notification as read: %v", err)
+	}
+
+	if count, _ := svc.CountUnread(t.Context(), author.ID); count != 1 {
+		t.Errorf("expected 1 unread notification, got %d", count)
+	}
+
+	err = svc.MarkAllRead(t.Context(), author.ID)
+	if err != nil {
+		t.Fatalf("failed to mark notifications as read: %v", err)
+	}
+
+	if count, _ := svc.CountUnread(t.Context(), author.ID); count != 0 {
+		t.Errorf("expected no unread notifications, got %d", count)
+	}
+}
+
+func TestNotifyModeration(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	moderator := createUser(t, svc, "moderator")
+	commenter := createUser(t, svc, "commenter")
+
+	post := createPost(t, svc, author)
+
+	approved := createComment(t, svc, post, commenter, "<p>Hello</p>", blog.CommentStatusApproved)
+	rejected := createComment(t, svc, post, commenter, "<p>Spam</p>", blog.CommentStatusPending)
+
+	err := svc.NotifyModeration(t.Context(), approved, moderator.ID, notification.TypeCommentApproved)
+	if err != nil {
+		t.Fatalf("failed to notify moderation: %v", err)
+	}
+
+	err = svc.NotifyModeration(t.Context(), rejected, moderator.ID, notification.TypeCommentRejected)
+	if err != nil {
+		t.Fatalf("failed to notify moderation: %v", err)
+	}
+
+	// Users are not notified about their own actions.
+	err = svc.NotifyModeration(t.Context(), approved, commenter.ID, notification.TypeCommentRejected)
+	if err != nil {
+		t.Fatalf("failed to notify moderation: %v", err)
+	}
+
+	notifications, err := svc.ListNotifications(
+		t.Context(),
+		notification.ListNotificationsParams{UserID: commenter.ID},
+	)
+	if err != nil {
+		t.Fatalf("failed to list notifications: %v", err)
+	}
+
+	var texts []string
+	for _, n := range notifications {
+		texts = append(texts, n.Text)
+	}
+
+	want := []string{
+		`Your comment on "Notifications" was not approved`,
+		`Your comment on "Notifications" was approved`,
+	}
+	if !slices.Equal(texts, want) && !slices.Equal(texts, []string{want[1], want[0]}) {
+		t.Errorf("expected %q, got %q", want, texts)
+	}
+
+	if got := subjects(mailbox, commenter); len(got) != 0 {
+		t.Errorf("expected no moderation emails, got %q", got)
+	}
+}
+
+func TestNotifyPostMentions(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	alice := createUser(t, svc, "alice")
+	bob := createUser(t, svc, "bob")
+
+	post := createPost(t, svc, author)
+
+	err := svc.BlogSvc.MentionRepo.Replace(t.Context(), post.ID, "", []string{"alice", "author"}, post.CreatedAt)
+	if err != nil {
+		t.Fatalf("failed to save mentions: %v", err)
+	}
+
+	err = svc.NotifyPostMentions(t.Context(), post, post.CreatedAt)
+	if err != nil {
+		t.Fatalf("failed to notify post mentions: %v", err)
+	}
+
+	// An edit mentions bob too.
+	updatedAt := post.CreatedAt.Add(time.Minute)
+
+	err = svc.BlogSvc.MentionRepo.Replace(t.Context(), post.ID, "", []string{"alice", "bob"}, updatedAt)
+	if err != nil {
+		t.Fatalf("failed to save mentions: %v", err)
+	}
+
+	err = svc.NotifyPostMentions(t.Context(), post, updatedAt)
+	if err != nil {
+		t.Fatalf("failed to notify post mentions: %v", err)
+	}
+
+	want := []string{`author mentioned you in "Notifications"`}
+
+	if got := subjects(mailbox, alice); !slices.Equal(got, want) {
+		t.Errorf("expected one email to alice, got %q", got)
+	}
+
+	if got := subjects(mailbox, bob); !slices.Equal(got, want) {
+		t.Errorf("expected one email to bob, got %q", got)
+	}
+
+	if got := subjects(mailbox, author); len(got) != 0 {
+		t.Errorf("expected no email to the author, got %q", got)
+	}
+
+	count, err := svc.CountUnread(t.Context(), bob.ID)
+	if err != nil || count != 1 {
+		t.Errorf("expected a notification to bob, got %d %v", count, err)
+	}
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(context.Context, audit.Event) {}
+
+func TestChangePostAuthorDoesNotNotifyMentionsAgain(t *testing.T) {
+	svc, mailbox := newService(t)
+
+	author := createUser(t, svc, "author")
+	newAuthor := createUser(t, svc, "newauthor")
+	alice := createUser(t, svc, "alice")
+
+	post := createPost(t, svc, author)
+
+	err := svc.BlogSvc.MentionRepo.Replace(t.Context(), post.ID, "", []string{"alice"}, post.UpdatedAt)
+	if err != nil {
+		t.Fatalf("failed to save mentions: %v", err)
+	}
+
+	err = svc.NotifyPostMentions(t.Context(), post, post.UpdatedAt)
+	if err != nil {
+		t.Fatalf("failed to notify post mentions: %v", err)
+	}
+
+	bus := &eventbus.Bus{}
+	svc.Subscribe(bus)
+
+	svc.BlogSvc.Auditor = nopRecorder{}
+	svc.BlogSvc.Events = bus
+
+	err = svc.BlogSvc.ChangePostAuthor(t.Context(), post.ID, newAuthor.ID)
+	if err != nil {
+		t.Fatalf("failed to change post author: %v", err)
+	}
+
+	bus.Close()
+
+	if got := subjects(mailbox, alice); len(got) != 1 {
+		t.Errorf("expected only the first email to alice, got %q", got)
+	}
+
+	count, err := svc.CountUnread(t.Context(), alice.ID)
+	if err != nil || count != 1 {
+		t.Errorf("expected one notification to alice, got %d %v", count, err)
+	}
+}
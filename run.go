@@ -2,6 +2,8 @@ package fullstackgo
 
 import (
 	"context"
+	"crypto/hkdf"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -16,15 +18,32 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/nasermirzaei89/env"
+	"github.com/nasermirzaei89/fullstackgo/audit"
 	"github.com/nasermirzaei89/fullstackgo/auth"
 	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/dataexport"
 	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
 	"github.com/nasermirzaei89/fullstackgo/mailer"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+	"github.com/nasermirzaei89/fullstackgo/settings"
 	"github.com/nasermirzaei89/fullstackgo/web"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
 )
 
 const (
-	HTTPServerTimeOut = 60 * time.Second
+	HTTPServerTimeOut       = 60 * time.Second
+	TrashPurgeInterval      = 1 * time.Hour
+	DataExportPurgeInterval = 1 * time.Hour
+	JobPurgeInterval        = 1 * time.Hour
+	EventWorkers            = 4
+	EventQueueSize          = 256
+	WebhookTimeout          = 10 * time.Second
+	WebhookRetryInterval    = 30 * time.Second
+	OutboxSendInterval      = 30 * time.Second
+	NewsletterSendInterval  = 1 * time.Minute
 )
 
 func Run(ctx context.Context) error {
@@ -51,47 +70,253 @@ func Run(ctx context.Context) error {
 	// Repositories
 	userRepo := &sqlite3.UserRepo{DB: db}
 	postRepo := &sqlite3.PostRepo{DB: db}
+	postSlugHistoryRepo := &sqlite3.PostSlugHistoryRepo{DB: db}
 	commentRepo := &sqlite3.CommentRepo{DB: db}
+	mentionRepo := &sqlite3.MentionRepo{DB: db}
 	passwordResetTokenRepo := &sqlite3.PasswordResetTokenRepo{DB: db}
+	settingRepo := &sqlite3.SettingRepo{DB: db}
+	loginRepo := &sqlite3.LoginRepo{DB: db}
+	avatarRepo := &sqlite3.AvatarRepo{DB: db}
+	dataExportRepo := &sqlite3.DataExportRepo{DB: db}
+	auditEntryRepo := &sqlite3.AuditEntryRepo{DB: db}
+	webhookSubscriptionRepo := &sqlite3.WebhookSubscriptionRepo{DB: db}
+	webhookDeliveryRepo := &sqlite3.WebhookDeliveryRepo{DB: db}
+	jobRepo := &sqlite3.JobRepo{DB: db}
+	outboxMessageRepo := &sqlite3.OutboxMessageRepo{DB: db}
+	newsletterSubscriptionRepo := &sqlite3.NewsletterSubscriptionRepo{DB: db}
+	notificationRepo := &sqlite3.NotificationRepo{DB: db}
+	notificationPreferencesRepo := &sqlite3.NotificationPreferencesRepo{DB: db}
 
 	// Services
+	eventBus := &eventbus.Bus{
+		Workers:   EventWorkers,
+		QueueSize: EventQueueSize,
+	}
+
+	auditSvc := &audit.Service{
+		EntryRepo: auditEntryRepo,
+	}
+
 	authSvc := &auth.Service{
 		UserRepo:               userRepo,
 		PasswordResetTokenRepo: passwordResetTokenRepo,
+		LoginRepo:              loginRepo,
+		AvatarRepo:             avatarRepo,
+		Auditor:                auditSvc,
+		Events:                 eventBus,
+	}
+
+	settingsSvc := &settings.Service{
+		SettingRepo: settingRepo,
 	}
 
 	blogSvc := &blog.Service{
-		PostRepo:    postRepo,
-		CommentRepo: commentRepo,
-		HTMLPolicy:  bluemonday.UGCPolicy(),
-		TextPolicy:  bluemonday.StrictPolicy(),
+		PostRepo:            postRepo,
+		PostSlugHistoryRepo: postSlugHistoryRepo,
+		CommentRepo:         commentRepo,
+		MentionRepo:         mentionRepo,
+		UserChecker:         authSvc,
+		HTMLPolicy:          blog.NewHTMLPolicy(),
+		TextPolicy:          bluemonday.StrictPolicy(),
+		TrashRetention:      time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		SettingsSvc:         settingsSvc,
+		Auditor:             auditSvc,
+		Events:              eventBus,
+	}
+
+	go blogSvc.RunTrashPurger(ctx, TrashPurgeInterval)
+
+	baseURL := env.GetString("BASE_URL", "")
+
+	webhookSvc := &webhook.Service{
+		SubscriptionRepo: webhookSubscriptionRepo,
+		DeliveryRepo:     webhookDeliveryRepo,
+		Client:           &http.Client{Timeout: WebhookTimeout},
+		BaseURL:          baseURL,
+		MaxAttempts:      env.GetInt("WEBHOOK_MAX_ATTEMPTS", webhook.DefaultMaxAttempts),
+		Backoff:          webhook.DefaultBackoff,
 	}
 
+	webhookSvc.Subscribe(eventBus)
+
+	go webhookSvc.RunRetrier(ctx, WebhookRetryInterval)
+
 	// Session
-	cookieStore := sessions.NewCookieStore([]byte(env.MustGetString("SESSION_KEY")))
+	sessionKey := []byte(env.MustGetString("SESSION_KEY"))
+	cookieStore := sessions.NewCookieStore(sessionKey)
 	sessionName := env.GetString("SESSION_NAME", "fullstackgo")
 
 	// Mailer
-	smtpHost := env.MustGetString("SMTP_HOST")
-	smtpPort := env.MustGetString("SMTP_PORT")
-	smtpFrom := env.MustGetString("SMTP_FROM")
-	smtpMailer := &mailer.SMTPMailer{
-		Host:     smtpHost,
-		Port:     smtpPort,
-		Username: env.GetString("SMTP_USERNAME", ""),
-		Password: env.GetString("SMTP_PASSWORD", ""),
-		From:     smtpFrom,
+	mailFrom := env.GetString("SMTP_FROM", "noreply@localhost")
+
+	var (
+		transport  mailer.Mailer
+		smtpMailer *mailer.SMTPMailer
+		devMailbox *mailer.MemoryMailer
+	)
+
+	switch mailerType := env.GetString("MAILER", "smtp"); mailerType {
+	case "smtp":
+		smtpMailer = &mailer.SMTPMailer{
+			Host:               env.MustGetString("SMTP_HOST"),
+			Port:               env.MustGetString("SMTP_PORT"),
+			Username:           env.GetString("SMTP_USERNAME", ""),
+			Password:           env.GetString("SMTP_PASSWORD", ""),
+			From:               mailFrom,
+			TLSMode:            mailer.TLSMode(env.GetString("SMTP_TLS_MODE", string(mailer.TLSModeOpportunistic))),
+			AuthMechanism:      mailer.AuthMechanism(env.GetString("SMTP_AUTH", string(mailer.AuthPlain))),
+			InsecureSkipVerify: env.GetBool("SMTP_TLS_SKIP_VERIFY", false),
+			DialTimeout:        time.Duration(env.GetInt("SMTP_DIAL_TIMEOUT_SECONDS", 10)) * time.Second,
+			CommandTimeout:     time.Duration(env.GetInt("SMTP_COMMAND_TIMEOUT_SECONDS", 30)) * time.Second,
+		}
+
+		if caFile := env.GetString("SMTP_CA_FILE", ""); caFile != "" {
+			smtpMailer.RootCAs, err = mailer.LoadCertPool(caFile)
+			if err != nil {
+				return fmt.Errorf("error on load smtp ca file: %w", err)
+			}
+		}
+
+		transport = smtpMailer
+	case "file":
+		transport = &mailer.FileMailer{Dir: env.GetString("MAIL_DIR", "mail"), From: mailFrom}
+	case "maildir":
+		transport = &mailer.MaildirMailer{Dir: env.GetString("MAIL_DIR", "mail"), From: mailFrom}
+	case "log":
+		transport = &mailer.LogMailer{From: mailFrom}
+	case "memory":
+		// The inbox shows every message, password reset links included, to anyone who can reach the server.
+		if !env.GetBool("DEV_MODE", false) {
+			return errors.New("the memory mailer is only available with DEV_MODE=true")
+		}
+
+		devMailbox = &mailer.MemoryMailer{From: mailFrom}
+		transport = devMailbox
+	default:
+		return fmt.Errorf("unsupported mailer %q", mailerType)
+	}
+
+	if dkimKeyFile := env.GetString("DKIM_PRIVATE_KEY_FILE", ""); dkimKeyFile != "" {
+		dkimKey, err := mailer.LoadDKIMKey(dkimKeyFile)
+		if err != nil {
+			return fmt.Errorf("error on load dkim private key: %w", err)
+		}
+
+		transport = &mailer.DKIMMailer{
+			Mailer:   transport,
+			From:     mailFrom,
+			Domain:   env.MustGetString("DKIM_DOMAIN"),
+			Selector: env.MustGetString("DKIM_SELECTOR"),
+			Key:      dkimKey,
+		}
+	}
+
+	// A rate of 0 turns the limit off.
+	if rate := env.GetInt("MAIL_RATE_PER_MINUTE", mailer.DefaultRatePerMinute); rate > 0 {
+		transport = &mailer.RateLimitedMailer{Mailer: transport, Interval: time.Minute / time.Duration(rate)}
+	}
+
+	outbox := &mailer.Outbox{
+		MessageRepo: outboxMessageRepo,
+		Transport:   transport,
+		MaxAttempts: env.GetInt("MAIL_MAX_ATTEMPTS", mailer.DefaultOutboxMaxAttempts),
 	}
 
+	go outbox.RunSender(ctx, OutboxSendInterval)
+
+	// Job queue
+	jobQueue := &jobqueue.Queue{
+		JobRepo:     jobRepo,
+		Workers:     env.GetInt("JOB_WORKERS", jobqueue.DefaultWorkers),
+		MaxAttempts: env.GetInt("JOB_MAX_ATTEMPTS", jobqueue.DefaultMaxAttempts),
+		Retention:   time.Duration(env.GetInt("JOB_RETENTION_DAYS", 7)) * 24 * time.Hour,
+	}
+
+	// Signed links use their own keys, derived from the session key unless they are set.
+	dataExportSigningKey, err := signingKey(sessionKey, "DATA_EXPORT_SIGNING_KEY")
+	if err != nil {
+		return err
+	}
+
+	newsletterSigningKey, err := signingKey(sessionKey, "NEWSLETTER_SIGNING_KEY")
+	if err != nil {
+		return err
+	}
+
+	notificationSigningKey, err := signingKey(sessionKey, "NOTIFICATION_SIGNING_KEY")
+	if err != nil {
+		return err
+	}
+
+	dataExportSvc := &dataexport.Service{
+		ExportRepo:      dataExportRepo,
+		AuthSvc:         authSvc,
+		BlogSvc:         blogSvc,
+		Mailer:          outbox,
+		JobQueue:        jobQueue,
+		SigningKey:      dataExportSigningKey,
+		LinkTTL:         time.Duration(env.GetInt("DATA_EXPORT_LINK_HOURS", 24)) * time.Hour,
+		RequestInterval: time.Duration(env.GetInt("DATA_EXPORT_INTERVAL_HOURS", 24)) * time.Hour,
+	}
+
+	jobQueue.Register(mailer.SendEmailJobType, mailer.SendEmailJobHandler(outbox))
+	jobQueue.Register(dataexport.BuildJobType, dataexport.BuildJobHandler(dataExportSvc))
+
+	err = jobQueue.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("error on start job queue: %w", err)
+	}
+
+	go jobQueue.RunPurger(ctx, JobPurgeInterval)
+
+	go dataExportSvc.RunPurger(ctx, DataExportPurgeInterval)
+
+	newsletterSvc := &newsletter.Service{
+		SubscriptionRepo: newsletterSubscriptionRepo,
+		BlogSvc:          blogSvc,
+		Mailer:           outbox,
+		SigningKey:       newsletterSigningKey,
+		BaseURL:          baseURL,
+		BatchSize:        env.GetInt("NEWSLETTER_BATCH_SIZE", newsletter.DefaultBatchSize),
+		ConfirmInterval:  time.Duration(env.GetInt("NEWSLETTER_CONFIRM_INTERVAL_MINUTES", 10)) * time.Minute,
+	}
+
+	newsletterSvc.Subscribe(eventBus)
+
+	go newsletterSvc.RunSender(ctx, NewsletterSendInterval)
+
+	notificationSvc := &notification.Service{
+		NotificationRepo: notificationRepo,
+		PreferencesRepo:  notificationPreferencesRepo,
+		AuthSvc:          authSvc,
+		BlogSvc:          blogSvc,
+		Mailer:           outbox,
+		SigningKey:       notificationSigningKey,
+		BaseURL:          baseURL,
+	}
+
+	notificationSvc.Subscribe(eventBus)
+
 	// HTTP Handler
 	handler := &web.Handler{
 		CookieStore:        cookieStore,
 		SessionName:        sessionName,
 		AuthSvc:            authSvc,
 		BlogSvc:            blogSvc,
-		Mailer:             smtpMailer,
+		SettingsSvc:        settingsSvc,
+		DataExportSvc:      dataExportSvc,
+		AuditSvc:           auditSvc,
+		WebhookSvc:         webhookSvc,
+		NewsletterSvc:      newsletterSvc,
+		NotificationSvc:    notificationSvc,
+		JobQueue:           jobQueue,
+		Outbox:             outbox,
+		DevMailbox:         devMailbox,
 		CSRFAuthKeys:       []byte(env.MustGetString("CSRF_AUTH_KEY")),
 		CSRFTrustedOrigins: env.GetStringSlice("CSRF_TRUSTED_ORIGINS", []string{}),
+		TrustedProxies:     env.GetStringSlice("TRUSTED_PROXIES", []string{}),
+		BaseURL:            baseURL,
+		RobotsDisallow:     env.GetStringSlice("ROBOTS_DISALLOW", []string{}),
 	}
 
 	// HTTP Server
@@ -132,7 +357,32 @@ func Run(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("error shutting down server: %w", err)
 		}
+
+		// Let asynchronous event subscribers finish.
+		eventBus.Close()
+
+		// The workers stopped taking jobs when ctx was done. Let the running ones finish.
+		jobQueue.Wait()
+
+		if smtpMailer != nil {
+			smtpMailer.Close()
+		}
 	}
 
 	return nil
 }
+
+// signingKey returns the key in the environment variable name, or derives one from the session key with name as the
+// HKDF label, so every purpose gets a different key and deployments do not need a new secret for each.
+func signingKey(sessionKey []byte, name string) ([]byte, error) {
+	if key := env.GetString(name, ""); key != "" {
+		return []byte(key), nil
+	}
+
+	key, err := hkdf.Key(sha256.New, sessionKey, nil, "fullstackgo "+name, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("error on derive %s: %w", name, err)
+	}
+
+	return key, nil
+}
@@ -1,24 +1,47 @@
 package fullstackgo_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/nasermirzaei89/fullstackgo/audit"
 	"github.com/nasermirzaei89/fullstackgo/auth"
 	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/dataexport"
 	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
 	"github.com/nasermirzaei89/fullstackgo/mailer"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+	"github.com/nasermirzaei89/fullstackgo/settings"
 	"github.com/nasermirzaei89/fullstackgo/web"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
 	"github.com/playwright-community/playwright-go"
 )
 
 func TestAll(t *testing.T) {
-	server := runServer(t)
+	server, _ := runServer(t)
 	defer server.Close()
 
 	t.Run("App", runApp(server.URL))
@@ -154,9 +177,9 @@ func runUpdateProfile(page playwright.Page) func(t *testing.T) {
 			t.Fatalf("could not fill email address: %v", err)
 		}
 
-		err = page.Locator("input[name=avatarUrl]").Fill("https://i.pravatar.cc/100?u=" + newEmail)
+		err = page.Locator("textarea[name=bio]").Fill("Testing the profile page.")
 		if err != nil {
-			t.Fatalf("could not fill avatar URL: %v", err)
+			t.Fatalf("could not fill bio: %v", err)
 		}
 
 		err = page.GetByText("Update Profile").Click()
@@ -251,7 +274,7 @@ func runLogin(page playwright.Page, username, password string) func(t *testing.T
 	}
 }
 
-func runServer(t *testing.T) *httptest.Server {
+func runServer(t *testing.T) (*httptest.Server, *mailer.MemoryMailer) {
 	t.Helper()
 
 	ctx := context.Background()
@@ -279,30 +302,141 @@ func runServer(t *testing.T) *httptest.Server {
 	// Repositories
 	userRepo := &sqlite3.UserRepo{DB: db}
 	postRepo := &sqlite3.PostRepo{DB: db}
+	postSlugHistoryRepo := &sqlite3.PostSlugHistoryRepo{DB: db}
 	commentRepo := &sqlite3.CommentRepo{DB: db}
+	mentionRepo := &sqlite3.MentionRepo{DB: db}
 	passwordResetTokenRepo := &sqlite3.PasswordResetTokenRepo{DB: db}
+	settingRepo := &sqlite3.SettingRepo{DB: db}
+	loginRepo := &sqlite3.LoginRepo{DB: db}
+	avatarRepo := &sqlite3.AvatarRepo{DB: db}
+	dataExportRepo := &sqlite3.DataExportRepo{DB: db}
+	auditEntryRepo := &sqlite3.AuditEntryRepo{DB: db}
+	webhookSubscriptionRepo := &sqlite3.WebhookSubscriptionRepo{DB: db}
+	webhookDeliveryRepo := &sqlite3.WebhookDeliveryRepo{DB: db}
+	jobRepo := &sqlite3.JobRepo{DB: db}
+	outboxMessageRepo := &sqlite3.OutboxMessageRepo{DB: db}
+	newsletterSubscriptionRepo := &sqlite3.NewsletterSubscriptionRepo{DB: db}
+	notificationRepo := &sqlite3.NotificationRepo{DB: db}
+	notificationPreferencesRepo := &sqlite3.NotificationPreferencesRepo{DB: db}
 
 	// Services
+	eventBus := &eventbus.Bus{}
+	t.Cleanup(eventBus.Close)
+
+	auditSvc := &audit.Service{
+		EntryRepo: auditEntryRepo,
+	}
+
 	authSvc := &auth.Service{
 		UserRepo:               userRepo,
 		PasswordResetTokenRepo: passwordResetTokenRepo,
+		LoginRepo:              loginRepo,
+		AvatarRepo:             avatarRepo,
+		Auditor:                auditSvc,
+		Events:                 eventBus,
+	}
+
+	settingsSvc := &settings.Service{
+		SettingRepo: settingRepo,
 	}
 
 	blogSvc := &blog.Service{
-		PostRepo:    postRepo,
-		CommentRepo: commentRepo,
-		HTMLPolicy:  bluemonday.UGCPolicy(),
-		TextPolicy:  bluemonday.StrictPolicy(),
+		PostRepo:            postRepo,
+		PostSlugHistoryRepo: postSlugHistoryRepo,
+		CommentRepo:         commentRepo,
+		MentionRepo:         mentionRepo,
+		UserChecker:         authSvc,
+		HTMLPolicy:          blog.NewHTMLPolicy(),
+		TextPolicy:          bluemonday.StrictPolicy(),
+		TrashRetention:      30 * 24 * time.Hour,
+		SettingsSvc:         settingsSvc,
+		Auditor:             auditSvc,
+		Events:              eventBus,
+	}
+
+	webhookSvc := &webhook.Service{
+		SubscriptionRepo: webhookSubscriptionRepo,
+		DeliveryRepo:     webhookDeliveryRepo,
+	}
+
+	webhookSvc.Subscribe(eventBus)
+
+	mailbox := &mailer.MemoryMailer{From: "noreply@example.com"}
+
+	outbox := &mailer.Outbox{
+		MessageRepo: outboxMessageRepo,
+		Transport:   mailbox,
+	}
+
+	outboxCtx, stopOutbox := context.WithCancel(ctx)
+	t.Cleanup(stopOutbox)
+
+	go outbox.RunSender(outboxCtx, 100*time.Millisecond)
+
+	jobQueue := &jobqueue.Queue{
+		JobRepo:      jobRepo,
+		PollInterval: 100 * time.Millisecond,
+	}
+
+	dataExportSvc := &dataexport.Service{
+		ExportRepo: dataExportRepo,
+		AuthSvc:    authSvc,
+		BlogSvc:    blogSvc,
+		Mailer:     outbox,
+		JobQueue:   jobQueue,
+		SigningKey: []byte("test-data-export-signing-key"),
+		LinkTTL:    24 * time.Hour,
+	}
+
+	jobQueue.Register(mailer.SendEmailJobType, mailer.SendEmailJobHandler(outbox))
+	jobQueue.Register(dataexport.BuildJobType, dataexport.BuildJobHandler(dataExportSvc))
+
+	jobCtx, stopJobs := context.WithCancel(ctx)
+
+	t.Cleanup(func() {
+		stopJobs()
+		jobQueue.Wait()
+	})
+
+	err = jobQueue.Start(jobCtx)
+	if err != nil {
+		t.Fatalf("could not start job queue: %v", err)
+	}
+
+	newsletterSvc := &newsletter.Service{
+		SubscriptionRepo: newsletterSubscriptionRepo,
+		BlogSvc:          blogSvc,
+		Mailer:           outbox,
+		SigningKey:       []byte(testNewsletterSigningKey),
+	}
+
+	newsletterSvc.Subscribe(eventBus)
+
+	notificationSvc := &notification.Service{
+		NotificationRepo: notificationRepo,
+		PreferencesRepo:  notificationPreferencesRepo,
+		AuthSvc:          authSvc,
+		BlogSvc:          blogSvc,
+		Mailer:           outbox,
+		SigningKey:       []byte("test-notification-signing-key"),
 	}
 
-	mockMailer := &mailer.MockMailer{}
+	notificationSvc.Subscribe(eventBus)
 
 	handler := &web.Handler{
 		CookieStore:        cookieStore,
 		SessionName:        sessionName,
 		AuthSvc:            authSvc,
 		BlogSvc:            blogSvc,
-		Mailer:             mockMailer,
+		SettingsSvc:        settingsSvc,
+		DataExportSvc:      dataExportSvc,
+		AuditSvc:           auditSvc,
+		WebhookSvc:         webhookSvc,
+		NewsletterSvc:      newsletterSvc,
+		NotificationSvc:    notificationSvc,
+		JobQueue:           jobQueue,
+		Outbox:             outbox,
+		DevMailbox:         mailbox,
 		CSRFAuthKeys:       []byte("test-csrf-auth-key"),
 		CSRFTrustedOrigins: []string{},
 	}
@@ -313,5 +447,1131 @@ func runServer(t *testing.T) *httptest.Server {
 
 	t.Logf("Server running at %s", server.URL)
 
-	return server
+	return server, mailbox
+}
+
+const testNewsletterSigningKey = "test-newsletter-signing-key"
+
+var csrfTokenRegexp = regexp.MustCompile(`name="gorilla.csrf.Token" value="([^"]+)"`)
+
+// submitForm loads the page with the form for its CSRF token and posts values to action without following redirects.
+func submitForm(t *testing.T, client *http.Client, serverURL, page, action string, values url.Values) *http.Response {
+	t.Helper()
+
+	resp, err := client.Get(serverURL + page)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", page, err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read %s: %v", page, err)
+	}
+
+	match := csrfTokenRegexp.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("no CSRF token on %s", page)
+	}
+
+	values.Set("gorilla.csrf.Token", html.UnescapeString(string(match[1])))
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		serverURL+action,
+		strings.NewReader(values.Encode()),
+	)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", serverURL)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("could not post %s: %v", action, err)
+	}
+
+	_ = resp.Body.Close()
+
+	return resp
+}
+
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("could not create cookie jar: %v", err)
+	}
+
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// waitForEmail returns the newest message to the address, waiting for the outbox to send it.
+func waitForEmail(t *testing.T, mailbox *mailer.MemoryMailer, to string) *mailer.CapturedMessage {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		for _, m := range mailbox.Messages() {
+			if slices.Contains(m.Message.To, to) {
+				return m
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("expected an email to %s", to)
+
+	return nil
+}
+
+func TestPasswordResetEmail(t *testing.T) {
+	server, mailbox := runServer(t)
+	defer server.Close()
+
+	resp := submitForm(t, newTestClient(t), server.URL, "/register", "/register", url.Values{
+		"username":             {"resetuser"},
+		"emailAddress":         {"resetuser@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	client := newTestClient(t)
+
+	resp = submitForm(t, client, server.URL, "/forgot-password", "/forgot-password", url.Values{
+		"emailAddress": {"resetuser@example.com"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected forgot password to redirect, got %d", resp.StatusCode)
+	}
+
+	// The email goes through the job queue and the outbox.
+	message := waitForEmail(t, mailbox, "resetuser@example.com")
+
+	if message.Message.Subject != "Password Reset Request" {
+		t.Errorf("unexpected subject %q", message.Message.Subject)
+	}
+
+	resetLink := regexp.MustCompile(`http://\S+/reset-password\?token=\S+`).FindString(message.Message.Text)
+	if resetLink == "" {
+		t.Fatalf("expected a reset link in %q", message.Message.Text)
+	}
+
+	// The dev inbox lists the message and its links.
+	resp, err := client.Get(server.URL + "/_dev/mail/" + message.ID)
+	if err != nil {
+		t.Fatalf("could not get dev inbox: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not read dev inbox: %d %v", resp.StatusCode, err)
+	}
+
+	if !strings.Contains(string(body), `href="`+resetLink+`"`) {
+		t.Errorf("expected the reset link in the dev inbox")
+	}
+
+	resetPath := strings.TrimPrefix(resetLink, server.URL)
+
+	resp = submitForm(t, client, server.URL, resetPath, "/reset-password", url.Values{
+		"token":                   {strings.TrimPrefix(resetPath, "/reset-password?token=")},
+		"newPassword":             {"newpassword456"},
+		"newPasswordConfirmation": {"newpassword456"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected reset password to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/login", "/login", url.Values{
+		"username": {"resetuser"},
+		"password": {"newpassword456"},
+	})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/" {
+		t.Errorf(
+			"expected to sign in with the new password, got %d to %q",
+			resp.StatusCode,
+			resp.Header.Get("Location"),
+		)
+	}
+}
+
+func TestNewsletterSubscription(t *testing.T) {
+	server, mailbox := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/newsletter", "/newsletter", url.Values{
+		"emailAddress": {"reader@example.com"},
+		"frequency":    {string(newsletter.FrequencyWeekly)},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected subscribe to redirect, got %d", resp.StatusCode)
+	}
+
+	message := waitForEmail(t, mailbox, "reader@example.com")
+
+	confirmLink := regexp.MustCompile(`http://\S+/newsletter/confirm\?\S+`).FindString(message.Message.Text)
+	if confirmLink == "" {
+		t.Fatalf("expected a confirmation link in %q", message.Message.Text)
+	}
+
+	resp, err := client.Get(strings.ReplaceAll(confirmLink, "signature=", "signature=0"))
+	if err != nil {
+		t.Fatalf("could not get confirmation link: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a tampered link to be forbidden, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(confirmLink)
+	if err != nil {
+		t.Fatalf("could not get confirmation link: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected confirmation to redirect, got %d", resp.StatusCode)
+	}
+
+	confirmURL, err := url.Parse(confirmLink)
+	if err != nil {
+		t.Fatalf("could not parse confirmation link: %v", err)
+	}
+
+	// The unsubscribe link of the digests.
+	unsubscribePath := (&newsletter.Service{SigningKey: []byte(testNewsletterSigningKey)}).
+		UnsubscribePath(confirmURL.Query().Get("id"))
+
+	// Mail clients unsubscribe in one click, without a session or CSRF token.
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		server.URL+unsubscribePath,
+		strings.NewReader("List-Unsubscribe=One-Click"),
+	)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not post unsubscribe link: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected one-click unsubscribe to succeed, got %d", resp.StatusCode)
+	}
+
+	// Subscribing again starts over with a new confirmation.
+	mailbox.Clear()
+
+	resp = submitForm(t, client, server.URL, "/newsletter", "/newsletter", url.Values{
+		"emailAddress": {"reader@example.com"},
+		"frequency":    {string(newsletter.FrequencyDaily)},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected subscribe to redirect, got %d", resp.StatusCode)
+	}
+
+	waitForEmail(t, mailbox, "reader@example.com")
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"prefsuser"},
+		"emailAddress":         {"prefsuser@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	// Registration signs the user in.
+	resp = submitForm(t, client, server.URL, "/profile", "/profile/notifications", url.Values{
+		"emailReplies": {"on"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected saving preferences to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/profile")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not read profile: %d %v", resp.StatusCode, err)
+	}
+
+	for name, checked := range map[string]bool{"emailComments": false, "emailReplies": true, "emailMentions": false} {
+		if got := regexp.MustCompile(`name="` + name + `"\s+checked`).Match(body); got != checked {
+			t.Errorf("expected %s checked to be %v", name, checked)
+		}
+	}
+}
+
+func TestNotificationCenter(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	resp, err := newTestClient(t).Get(server.URL + "/notifications/unread-count")
+	if err != nil {
+		t.Fatalf("could not get unread count: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+		t.Errorf("expected guests to be sent to login, got %d", resp.StatusCode)
+	}
+
+	client := newTestClient(t)
+
+	resp = submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"belluser"},
+		"emailAddress":         {"belluser@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(server.URL + "/notifications/unread-count")
+	if err != nil {
+		t.Fatalf("could not get unread count: %v", err)
+	}
+
+	var unread struct {
+		Count int `json:"count"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&unread)
+	_ = resp.Body.Close()
+
+	if err != nil || unread.Count != 0 {
+		t.Fatalf("expected no unread notifications, got %d %v", unread.Count, err)
+	}
+
+	resp, err = client.Get(server.URL + "/notifications")
+	if err != nil {
+		t.Fatalf("could not get notifications: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not read notifications: %d %v", resp.StatusCode, err)
+	}
+
+	if !strings.Contains(string(body), "No notifications yet.") ||
+		!strings.Contains(string(body), `href="/notifications"`) {
+		t.Errorf("expected an empty notification center and the bell")
+	}
+
+	resp = submitForm(t, client, server.URL, "/profile", "/notifications/read-all", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("expected marking all as read to redirect, got %d", resp.StatusCode)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	for _, username := range []string{"mentionee", "mentioner"} {
+		resp := submitForm(t, newTestClient(t), server.URL, "/register", "/register", url.Values{
+			"username":             {username},
+			"emailAddress":         {username + "@example.com"},
+			"password":             {"password123"},
+			"passwordConfirmation": {"password123"},
+		})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+		}
+	}
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/login", "/login", url.Values{
+		"username": {"mentioner"},
+		"password": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected login to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/users/autocomplete?q=mentionee")
+	if err != nil {
+		t.Fatalf("could not get username suggestions: %v", err)
+	}
+
+	var usernames []string
+
+	err = json.NewDecoder(resp.Body).Decode(&usernames)
+	_ = resp.Body.Close()
+
+	if err != nil || !slices.Equal(usernames, []string{"mentionee"}) {
+		t.Fatalf("expected mentionee to be suggested, got %q %v", usernames, err)
+	}
+
+	resp, err = client.Get(server.URL + "/posts/hello-world")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	postID := regexp.MustCompile(`name="postId" value="([^"]+)"`).FindSubmatch(body)
+	if postID == nil {
+		t.Fatalf("no comment form on the post")
+	}
+
+	submitForm(t, client, server.URL, "/posts/hello-world", "/comments", url.Values{
+		"postId":  {string(postID[1])},
+		"content": {"<p>Thanks @mentionee, see <code>@mentionee</code></p>"},
+	})
+
+	resp, err = client.Get(server.URL + "/posts/hello-world")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	if !strings.Contains(string(body), `Thanks <a href="/users/mentionee" class="mention">@mentionee</a>`) {
+		t.Errorf("expected the mention to link to the profile")
+	}
+
+	if !strings.Contains(string(body), "<code>@mentionee</code>") {
+		t.Errorf("expected the mention in code to be left alone")
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"profiled"},
+		"emailAddress":         {"profiled@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/profile", "/profile", url.Values{
+		"name":         {"Profiled User"},
+		"emailAddress": {"profiled@example.com"},
+		"bio":          {"Writes about Go."},
+		"links":        {"https://example.com/blog\n\nhttps://example.org"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected profile update to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/posts/hello-world")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	if !regexp.MustCompile(`<a href="/users/[^"]+" class="as-link" rel="author">`).Match(body) {
+		t.Errorf("expected a byline linking to the profile of the author")
+	}
+
+	postID := regexp.MustCompile(`name="postId" value="([^"]+)"`).FindSubmatch(body)
+	if postID == nil {
+		t.Fatalf("no comment form on the post")
+	}
+
+	submitForm(t, client, server.URL, "/posts/hello-world", "/comments", url.Values{
+		"postId":  {string(postID[1])},
+		"content": {"<p>A comment from the profile test</p>"},
+	})
+
+	resp, err = newTestClient(t).Get(server.URL + "/users/profiled")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read profile: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	for _, want := range []string{
+		"Profiled User",
+		"Writes about Go.",
+		`href="https://example.com/blog"`,
+		`href="https://example.org"`,
+		"A comment from the profile test",
+		`href="/posts/hello-world#comment-`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected the profile to contain %q", want)
+		}
+	}
+
+	resp = submitForm(t, client, server.URL, "/profile", "/profile", url.Values{
+		"name":         {"Profiled User"},
+		"emailAddress": {"profiled@example.com"},
+		"links":        {"javascript:alert(1)"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected profile update to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err = newTestClient(t).Get(server.URL + "/users/profiled")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read profile: %v", err)
+	}
+
+	if strings.Contains(string(body), "javascript:") || !strings.Contains(string(body), "Writes about Go.") {
+		t.Errorf("expected the invalid link to be rejected and the profile to be unchanged")
+	}
+
+	resp, err = newTestClient(t).Get(server.URL + "/users/nobody")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown user, got %d", resp.StatusCode)
+	}
+}
+
+// uploadAvatar posts an avatar file with the CSRF token from the profile page.
+func uploadAvatar(t *testing.T, client *http.Client, serverURL string, file []byte) *http.Response {
+	t.Helper()
+
+	resp, err := client.Get(serverURL + "/profile")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read profile: %v", err)
+	}
+
+	match := csrfTokenRegexp.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("no CSRF token on profile")
+	}
+
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+
+	err = mw.WriteField("gorilla.csrf.Token", html.UnescapeString(string(match[1])))
+	if err != nil {
+		t.Fatalf("could not write token: %v", err)
+	}
+
+	fw, err := mw.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatalf("could not create file field: %v", err)
+	}
+
+	_, err = fw.Write(file)
+	if err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	err = mw.Close()
+	if err != nil {
+		t.Fatalf("could not close multipart writer: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, serverURL+"/profile/avatar", &buf)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Origin", serverURL)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("could not upload avatar: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return resp
+}
+
+func TestAvatars(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"avatared"},
+		"emailAddress":         {"avatared@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/profile", "/profile", url.Values{
+		"name":         {"Ava Tared"},
+		"emailAddress": {"avatared@example.com"},
+		"avatarUrl":    {"https://tracker.example.com/avatar.png"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected profile update to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/profile")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read profile: %v", err)
+	}
+
+	userID := regexp.MustCompile(`src="/avatars/([^?"]+)\?size=256"`).FindSubmatch(body)
+	if userID == nil {
+		t.Fatalf("no avatar on the profile page")
+	}
+
+	avatarURL := server.URL + "/avatars/" + string(userID[1])
+
+	getAvatar := func(size int) (*http.Response, []byte) {
+		t.Helper()
+
+		resp, err := newTestClient(t).Get(avatarURL + "?size=" + strconv.Itoa(size))
+		if err != nil {
+			t.Fatalf("could not get avatar: %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		if err != nil {
+			t.Fatalf("could not read avatar: %v", err)
+		}
+
+		return resp, body
+	}
+
+	// Remote avatars are off by default, so the avatar URL is not saved and the initials are shown.
+	resp, body = getAvatar(64)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected the initials avatar, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if !strings.Contains(string(body), ">AT</text>") {
+		t.Errorf("expected the initials of the name, got %s", body)
+	}
+
+	resp = uploadAvatar(t, client, server.URL, []byte("not an image"))
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected the upload to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, _ = getAvatar(64)
+	if resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected an invalid upload to be rejected")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for x := range 300 {
+		for y := range 200 {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err = png.Encode(&buf, img)
+	if err != nil {
+		t.Fatalf("could not encode image: %v", err)
+	}
+
+	resp = uploadAvatar(t, client, server.URL, buf.Bytes())
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected the upload to redirect, got %d", resp.StatusCode)
+	}
+
+	for _, tc := range []struct{ requested, want int }{{48, 64}, {96, 128}, {1000, 256}} {
+		resp, body = getAvatar(tc.requested)
+		if resp.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("expected the uploaded avatar, got %s", resp.Header.Get("Content-Type"))
+		}
+
+		avatar, err := png.Decode(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("could not decode avatar: %v", err)
+		}
+
+		if avatar.Bounds().Dx() != tc.want || avatar.Bounds().Dy() != tc.want {
+			t.Errorf("expected a %dx%d avatar for size %d, got %v", tc.want, tc.want, tc.requested, avatar.Bounds())
+		}
+	}
+
+	submitForm(t, client, server.URL, "/profile", "/profile/avatar/delete", url.Values{})
+
+	resp, _ = getAvatar(64)
+	if resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("expected the initials avatar after removing the upload")
+	}
+}
+
+func TestCommentSpanClasses(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"overlayer"},
+		"emailAddress":         {"overlayer@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err := client.Get(server.URL + "/posts/hello-world")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	postID := regexp.MustCompile(`name="postId" value="([^"]+)"`).FindSubmatch(body)
+	if postID == nil {
+		t.Fatalf("no comment form on the post")
+	}
+
+	submitForm(t, client, server.URL, "/posts/hello-world", "/comments", url.Values{
+		"postId":  {string(postID[1])},
+		"content": {`<p><span class="fixed">Overlay attempt</span></p>`},
+	})
+
+	resp, err = client.Get(server.URL + "/posts/hello-world")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	if !strings.Contains(string(body), "Overlay attempt") {
+		t.Fatalf("expected the comment to be shown")
+	}
+
+	if strings.Contains(string(body), `<span class="fixed">`) {
+		t.Errorf("expected the class of the span to be removed")
+	}
+}
+
+func TestDataExport(t *testing.T) {
+	server, mailbox := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"exportuser"},
+		"emailAddress":         {"exportuser@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/profile", "/profile/export", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected requesting an export to redirect, got %d", resp.StatusCode)
+	}
+
+	// The archive is built by the job queue.
+	message := waitForEmail(t, mailbox, "exportuser@example.com")
+
+	downloadLink := regexp.MustCompile(`http://\S+/data-exports/\S+`).FindString(message.Message.Text)
+	if downloadLink == "" {
+		t.Fatalf("expected a download link in %q", message.Message.Text)
+	}
+
+	resp, err := client.Get(downloadLink)
+	if err != nil {
+		t.Fatalf("could not download export: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected to download the export, got %d", resp.StatusCode)
+	}
+
+	// Another export cannot be requested right away.
+	resp = submitForm(t, client, server.URL, "/profile", "/profile/export", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected requesting an export to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(server.URL + "/profile")
+	if err != nil {
+		t.Fatalf("could not get profile: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not read profile: %d %v", resp.StatusCode, err)
+	}
+
+	if !strings.Contains(string(body), "You have requested a data export recently.") {
+		t.Errorf("expected a second export to be refused")
+	}
+
+	if count := len(mailbox.Messages()); count != 1 {
+		t.Errorf("expected 1 email, got %d", count)
+	}
+}
+
+func TestPostSlugHistory(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"renamer"},
+		"emailAddress":         {"renamer@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/posts/new", "/posts", url.Values{
+		"title":   {"First Title"},
+		"content": {"<p>Content</p>"},
+		"format":  {"html"},
+	})
+	if location := resp.Header.Get("Location"); location != "/posts/first-title" {
+		t.Fatalf("expected the post to be created at /posts/first-title, got %q", location)
+	}
+
+	rename := func(from, to string) {
+		t.Helper()
+
+		resp := submitForm(t, client, server.URL, "/posts/"+from+"/edit", "/posts/"+from+"/edit", url.Values{
+			"title":   {"First Title"},
+			"slug":    {to},
+			"content": {"<p>Content</p>"},
+			"format":  {"html"},
+		})
+		if location := resp.Header.Get("Location"); location != "/posts/"+to {
+			t.Fatalf("expected the post to move to /posts/%s, got %q", to, location)
+		}
+	}
+
+	assertRedirect := func(from, to string) {
+		t.Helper()
+
+		resp, err := client.Get(server.URL + "/posts/" + from + "?page=2")
+		if err != nil {
+			t.Fatalf("could not get post: %v", err)
+		}
+
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/posts/"+to+"?page=2" {
+			t.Errorf(
+				"expected /posts/%s to redirect to /posts/%s, got %d to %q",
+				from,
+				to,
+				resp.StatusCode,
+				resp.Header.Get("Location"),
+			)
+		}
+	}
+
+	rename("first-title", "second-title")
+	assertRedirect("first-title", "second-title")
+
+	// Other posts cannot take a former slug.
+	resp = submitForm(t, client, server.URL, "/posts/new", "/posts", url.Values{
+		"title":   {"Other"},
+		"slug":    {"first-title"},
+		"content": {"<p>Content</p>"},
+		"format":  {"html"},
+	})
+	if location := resp.Header.Get("Location"); location == "/posts/first-title" {
+		t.Errorf("expected another post not to take a former slug")
+	}
+
+	// The post can take its own former slug back.
+	rename("second-title", "first-title")
+	assertRedirect("second-title", "first-title")
+
+	resp, err := client.Get(server.URL + "/posts/first-title")
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the post at its former slug, got %d", resp.StatusCode)
+	}
+}
+
+func TestTrashPageShowsCommentText(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"trashuser"},
+		"emailAddress":         {"trashuser@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/posts/new", "/posts", url.Values{
+		"title":   {"Trash Page"},
+		"content": {"<p>Content</p>"},
+		"format":  {"html"},
+	})
+	postPath := resp.Header.Get("Location")
+
+	resp, err := client.Get(server.URL + postPath)
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	postID := regexp.MustCompile(`name="postId" value="([^"]+)"`).FindSubmatch(body)
+	if postID == nil {
+		t.Fatalf("no comment form on the post")
+	}
+
+	submitForm(t, client, server.URL, postPath, "/comments", url.Values{
+		"postId":  {string(postID[1])},
+		"content": {"<p>Some <strong>bold</strong> words</p>"},
+	})
+
+	resp, err = client.Get(server.URL + postPath)
+	if err != nil {
+		t.Fatalf("could not get post: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil {
+		t.Fatalf("could not read post: %v", err)
+	}
+
+	deletePath := regexp.MustCompile(`/comments/[^/"]+/delete`).Find(body)
+	if deletePath == nil {
+		t.Fatalf("no delete link for the comment")
+	}
+
+	resp = submitForm(t, client, server.URL, string(deletePath), string(deletePath), url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected deleting the comment to redirect, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(server.URL + "/trash")
+	if err != nil {
+		t.Fatalf("could not get trash: %v", err)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not read trash: %d %v", resp.StatusCode, err)
+	}
+
+	if !strings.Contains(string(body), "Some bold words") {
+		t.Errorf("expected the comment as plain text in trash")
+	}
+
+	if strings.Contains(string(body), "&lt;strong&gt;") {
+		t.Errorf("expected no escaped markup in trash")
+	}
+}
+
+func TestCommentReply(t *testing.T) {
+	server, _ := runServer(t)
+	defer server.Close()
+
+	client := newTestClient(t)
+
+	resp := submitForm(t, client, server.URL, "/register", "/register", url.Values{
+		"username":             {"replier"},
+		"emailAddress":         {"replier@example.com"},
+		"password":             {"password123"},
+		"passwordConfirmation": {"password123"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected registration to redirect, got %d", resp.StatusCode)
+	}
+
+	resp = submitForm(t, client, server.URL, "/posts/new", "/posts", url.Values{
+		"title":   {"Reply Thread"},
+		"content": {"<p>Content</p>"},
+		"format":  {"html"},
+	})
+	postPath := resp.Header.Get("Location")
+
+	getPost := func(query string) string {
+		t.Helper()
+
+		resp, err := client.Get(server.URL + postPath + query)
+		if err != nil {
+			t.Fatalf("could not get post: %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		if err != nil {
+			t.Fatalf("could not read post: %v", err)
+		}
+
+		return string(body)
+	}
+
+	postID := regexp.MustCompile(`name="postId" value="([^"]+)"`).FindStringSubmatch(getPost(""))
+	if postID == nil {
+		t.Fatalf("no comment form on the post")
+	}
+
+	submitForm(t, client, server.URL, postPath, "/comments", url.Values{
+		"postId":  {postID[1]},
+		"content": {"<p>First</p>"},
+	})
+
+	parentID := regexp.MustCompile(`replyTo=([^#"]+)#comment-form`).FindStringSubmatch(getPost(""))
+	if parentID == nil {
+		t.Fatalf("no reply link on the comment")
+	}
+
+	body := getPost("?replyTo=" + parentID[1])
+
+	if !strings.Contains(body, `name="parentId" value="`+parentID[1]+`"`) {
+		t.Fatalf("expected the comment form to reply to the comment")
+	}
+
+	submitForm(t, client, server.URL, postPath+"?replyTo="+parentID[1], "/comments", url.Values{
+		"postId":   {postID[1]},
+		"parentId": {parentID[1]},
+		"content":  {"<p>Second</p>"},
+	})
+
+	if !strings.Contains(getPost(""), `In reply to <a href="#comment-`+parentID[1]+`" class="as-link">replier</a>`) {
+		t.Errorf("expected the reply to link to the comment it replies to")
+	}
 }
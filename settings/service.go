@@ -0,0 +1,183 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service reads settings through an in-process cache, which is dropped whenever settings are written.
+type Service struct {
+	SettingRepo SettingRepository
+
+	mu    sync.RWMutex
+	cache map[string]string
+}
+
+func (svc *Service) values(ctx context.Context) (map[string]string, error) {
+	svc.mu.RLock()
+	cache := svc.cache
+	svc.mu.RUnlock()
+
+	if cache != nil {
+		return cache, nil
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if svc.cache != nil {
+		return svc.cache, nil
+	}
+
+	settings, err := svc.SettingRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settings: %w", err)
+	}
+
+	cache = make(map[string]string, len(Defaults))
+
+	for key, value := range Defaults {
+		cache[key] = value
+	}
+
+	for _, setting := range settings {
+		if _, ok := Defaults[setting.Key]; ok {
+			cache[setting.Key] = setting.Value
+		}
+	}
+
+	svc.cache = cache
+
+	return cache, nil
+}
+
+func (svc *Service) GetString(ctx context.Context, key string) (string, error) {
+	values, err := svc.values(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := values[key]
+	if !ok {
+		return "", UnknownSettingError{Key: key}
+	}
+
+	return value, nil
+}
+
+func (svc *Service) GetInt(ctx context.Context, key string) (int, error) {
+	value, err := svc.GetString(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse setting %q as int: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func (svc *Service) GetBool(ctx context.Context, key string) (bool, error) {
+	value, err := svc.GetString(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse setting %q as bool: %w", key, err)
+	}
+
+	return b, nil
+}
+
+func (svc *Service) GetCommentPolicy(ctx context.Context) (CommentPolicy, error) {
+	value, err := svc.GetString(ctx, KeyCommentPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	return CommentPolicy(value), nil
+}
+
+func (svc *Service) GetAvatarFallback(ctx context.Context) (AvatarFallback, error) {
+	value, err := svc.GetString(ctx, KeyAvatarFallback)
+	if err != nil {
+		return "", err
+	}
+
+	return AvatarFallback(value), nil
+}
+
+type UpdateSettingsRequest struct {
+	SiteTitle        string
+	SiteTagline      string
+	Language         string
+	PostsPerPage     int
+	ExcerptLength    int
+	RegistrationOpen bool
+	CommentPolicy    CommentPolicy
+	AvatarFallback   AvatarFallback
+	RemoteAvatars    bool
+}
+
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+func (req *UpdateSettingsRequest) validate() error {
+	switch {
+	case strings.TrimSpace(req.SiteTitle) == "":
+		return InvalidSettingValueError{Key: KeySiteTitle, Reason: "must not be empty"}
+	case !languageTagPattern.MatchString(req.Language):
+		return InvalidSettingValueError{Key: KeyLanguage, Reason: "must be a language tag such as en or pt-BR"}
+	case req.PostsPerPage < 1 || req.PostsPerPage > 100:
+		return InvalidSettingValueError{Key: KeyPostsPerPage, Reason: "must be between 1 and 100"}
+	case req.ExcerptLength < 20 || req.ExcerptLength > 1000:
+		return InvalidSettingValueError{Key: KeyExcerptLength, Reason: "must be between 20 and 1000"}
+	case !req.CommentPolicy.IsValid():
+		return InvalidSettingValueError{Key: KeyCommentPolicy, Reason: "must be open, moderated or closed"}
+	case !req.AvatarFallback.IsValid():
+		return InvalidSettingValueError{Key: KeyAvatarFallback, Reason: "must be initials or gravatar"}
+	default:
+		return nil
+	}
+}
+
+func (svc *Service) UpdateSettings(ctx context.Context, req *UpdateSettingsRequest) error {
+	err := req.validate()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	settings := []*Setting{
+		{Key: KeySiteTitle, Value: strings.TrimSpace(req.SiteTitle), UpdatedAt: now},
+		{Key: KeySiteTagline, Value: strings.TrimSpace(req.SiteTagline), UpdatedAt: now},
+		{Key: KeyLanguage, Value: req.Language, UpdatedAt: now},
+		{Key: KeyPostsPerPage, Value: strconv.Itoa(req.PostsPerPage), UpdatedAt: now},
+		{Key: KeyExcerptLength, Value: strconv.Itoa(req.ExcerptLength), UpdatedAt: now},
+		{Key: KeyRegistrationOpen, Value: strconv.FormatBool(req.RegistrationOpen), UpdatedAt: now},
+		{Key: KeyCommentPolicy, Value: string(req.CommentPolicy), UpdatedAt: now},
+		{Key: KeyAvatarFallback, Value: string(req.AvatarFallback), UpdatedAt: now},
+		{Key: KeyRemoteAvatars, Value: strconv.FormatBool(req.RemoteAvatars), UpdatedAt: now},
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	err = svc.SettingRepo.Upsert(ctx, settings)
+	if err != nil {
+		return fmt.Errorf("failed to upsert settings: %w", err)
+	}
+
+	svc.cache = nil
+
+	return nil
+}
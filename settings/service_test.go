@@ -0,0 +1,111 @@
+package settings_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+func newSettingRepo(t *testing.T) *sqlite3.SettingRepo {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return &sqlite3.SettingRepo{DB: db}
+}
+
+func updateRequest(siteTitle string) *settings.UpdateSettingsRequest {
+	return &settings.UpdateSettingsRequest{
+		SiteTitle:        siteTitle,
+		Language:         "en",
+		PostsPerPage:     10,
+		ExcerptLength:    160,
+		RegistrationOpen: true,
+		CommentPolicy:    settings.CommentPolicyModerated,
+		AvatarFallback:   settings.AvatarFallbackInitials,
+	}
+}
+
+func TestSettingsCache(t *testing.T) {
+	repo := newSettingRepo(t)
+	svc := &settings.Service{SettingRepo: repo}
+
+	title, err := svc.GetString(t.Context(), settings.KeySiteTitle)
+	if err != nil || title != settings.Defaults[settings.KeySiteTitle] {
+		t.Fatalf("expected the default site title, got %q %v", title, err)
+	}
+
+	// Writes that bypass the service are not seen until the cache is dropped.
+	err = repo.Upsert(t.Context(), []*settings.Setting{
+		{Key: settings.KeySiteTitle, Value: "Behind the cache", UpdatedAt: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to upsert setting: %v", err)
+	}
+
+	title, err = svc.GetString(t.Context(), settings.KeySiteTitle)
+	if err != nil || title != settings.Defaults[settings.KeySiteTitle] {
+		t.Errorf("expected the cached site title, got %q %v", title, err)
+	}
+
+	err = svc.UpdateSettings(t.Context(), updateRequest("  Updated  "))
+	if err != nil {
+		t.Fatalf("failed to update settings: %v", err)
+	}
+
+	title, err = svc.GetString(t.Context(), settings.KeySiteTitle)
+	if err != nil || title != "Updated" {
+		t.Errorf("expected the updated site title, got %q %v", title, err)
+	}
+
+	policy, err := svc.GetCommentPolicy(t.Context())
+	if err != nil || policy != settings.CommentPolicyModerated {
+		t.Errorf("expected the moderated comment policy, got %q %v", policy, err)
+	}
+
+	// Another process reads the saved settings.
+	title, err = (&settings.Service{SettingRepo: repo}).GetString(t.Context(), settings.KeySiteTitle)
+	if err != nil || title != "Updated" {
+		t.Errorf("expected the saved site title, got %q %v", title, err)
+	}
+}
+
+func TestUpdateSettingsValidates(t *testing.T) {
+	svc := &settings.Service{SettingRepo: newSettingRepo(t)}
+
+	err := svc.UpdateSettings(t.Context(), updateRequest(" "))
+	if !errors.As(err, &settings.InvalidSettingValueError{}) {
+		t.Fatalf("expected an invalid setting value error, got %v", err)
+	}
+
+	title, err := svc.GetString(t.Context(), settings.KeySiteTitle)
+	if err != nil || title != settings.Defaults[settings.KeySiteTitle] {
+		t.Errorf("expected the default site title to be kept, got %q %v", title, err)
+	}
+}
+
+func TestUnknownSetting(t *testing.T) {
+	svc := &settings.Service{SettingRepo: newSettingRepo(t)}
+
+	_, err := svc.GetString(t.Context(), "unknown")
+	if !errors.As(err, &settings.UnknownSettingError{}) {
+		t.Errorf("expected an unknown setting error, got %v", err)
+	}
+}
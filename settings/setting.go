@@ -0,0 +1,89 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type Setting struct {
+	Key       string
+	Value     string
+	UpdatedAt time.Time
+}
+
+type SettingRepository interface {
+	List(ctx context.Context) (settings []*Setting, err error)
+	Upsert(ctx context.Context, settings []*Setting) (err error)
+}
+
+const (
+	KeySiteTitle        = "site_title"
+	KeySiteTagline      = "site_tagline"
+	KeyLanguage         = "language"
+	KeyPostsPerPage     = "posts_per_page"
+	KeyExcerptLength    = "excerpt_length"
+	KeyRegistrationOpen = "registration_open"
+	KeyCommentPolicy    = "comment_policy"
+	KeyAvatarFallback   = "avatar_fallback"
+	KeyRemoteAvatars    = "remote_avatars"
+)
+
+type CommentPolicy string
+
+const (
+	// CommentPolicyOpen publishes comments of signed-in users right away.
+	CommentPolicyOpen CommentPolicy = "open"
+	// CommentPolicyModerated holds new comments until an administrator approves them.
+	CommentPolicyModerated CommentPolicy = "moderated"
+	// CommentPolicyClosed accepts no new comments.
+	CommentPolicyClosed CommentPolicy = "closed"
+)
+
+func (p CommentPolicy) IsValid() bool {
+	return p == CommentPolicyOpen || p == CommentPolicyModerated || p == CommentPolicyClosed
+}
+
+// AvatarFallback is the avatar shown for users who have not uploaded one.
+type AvatarFallback string
+
+const (
+	// AvatarFallbackInitials shows an image with the initials of the user generated by the site.
+	AvatarFallbackInitials AvatarFallback = "initials"
+	// AvatarFallbackGravatar shows the Gravatar of the email address of the user.
+	AvatarFallbackGravatar AvatarFallback = "gravatar"
+)
+
+func (f AvatarFallback) IsValid() bool {
+	return f == AvatarFallbackInitials || f == AvatarFallbackGravatar
+}
+
+// Defaults holds the value of every setting that has not been saved yet.
+var Defaults = map[string]string{
+	KeySiteTitle:        "My Awesome Blog",
+	KeySiteTagline:      "",
+	KeyLanguage:         "en",
+	KeyPostsPerPage:     "10",
+	KeyExcerptLength:    "160",
+	KeyRegistrationOpen: "true",
+	KeyCommentPolicy:    string(CommentPolicyOpen),
+	KeyAvatarFallback:   string(AvatarFallbackInitials),
+	KeyRemoteAvatars:    "false",
+}
+
+type UnknownSettingError struct {
+	Key string
+}
+
+func (err UnknownSettingError) Error() string {
+	return fmt.Sprintf("unknown setting %q", err.Key)
+}
+
+type InvalidSettingValueError struct {
+	Key    string
+	Reason string
+}
+
+func (err InvalidSettingValueError) Error() string {
+	return fmt.Sprintf("invalid value for setting %q: %s", err.Key, err.Reason)
+}
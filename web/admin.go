@@ -0,0 +1,857 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/audit"
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+)
+
+// AdminPageSize is the number of rows in each page of an admin table.
+const AdminPageSize = 20
+
+// AdminListQuery holds the search, filter, sorting and page of an admin table as read from the query string.
+type AdminListQuery struct {
+	Path       string
+	Search     string
+	Status     string
+	Action     string
+	TargetType string
+	From       string
+	To         string
+	Sort       string
+	Desc       bool
+	Page       int
+}
+
+func parseAdminListQuery(r *http.Request, sortColumns []string) (*AdminListQuery, error) {
+	query := r.URL.Query()
+
+	q := AdminListQuery{
+		Path:       r.URL.Path,
+		Search:     strings.TrimSpace(query.Get("q")),
+		Status:     query.Get("status"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+		Sort:       query.Get("sort"),
+		Desc:       query.Get("order") == "desc",
+		Page:       1,
+	}
+
+	if q.Sort != "" && !slices.Contains(sortColumns, q.Sort) {
+		return nil, errors.New("invalid sort column")
+	}
+
+	if page := query.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return nil, errors.New("invalid page number")
+		}
+
+		q.Page = n
+	}
+
+	return &q, nil
+}
+
+func (q *AdminListQuery) Offset() int {
+	return (q.Page - 1) * AdminPageSize
+}
+
+func (q *AdminListQuery) values() url.Values {
+	values := url.Values{}
+
+	if q.Search != "" {
+		values.Set("q", q.Search)
+	}
+
+	if q.Status != "" {
+		values.Set("status", q.Status)
+	}
+
+	if q.Action != "" {
+		values.Set("action", q.Action)
+	}
+
+	if q.TargetType != "" {
+		values.Set("targetType", q.TargetType)
+	}
+
+	if q.From != "" {
+		values.Set("from", q.From)
+	}
+
+	if q.To != "" {
+		values.Set("to", q.To)
+	}
+
+	if q.Sort != "" {
+		values.Set("sort", q.Sort)
+
+		if q.Desc {
+			values.Set("order", "desc")
+		}
+	}
+
+	if q.Page > 1 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+
+	return values
+}
+
+// URL returns the address of the table with its current search, sorting and page.
+func (q *AdminListQuery) URL() string {
+	if values := q.values(); len(values) > 0 {
+		return q.Path + "?" + values.Encode()
+	}
+
+	return q.Path
+}
+
+// SortURL returns the address of the first page sorted by column, reversing the order when the table is already
+// sorted by it.
+func (q *AdminListQuery) SortURL(column string) string {
+	next := *q
+	next.Desc = q.Sort == column && !q.Desc
+	next.Sort = column
+	next.Page = 1
+
+	return next.URL()
+}
+
+// SortIndicator returns an arrow when the table is sorted by column.
+func (q *AdminListQuery) SortIndicator(column string) string {
+	switch {
+	case q.Sort != column:
+		return ""
+	case q.Desc:
+		return "↓"
+	default:
+		return "↑"
+	}
+}
+
+// BulkURL returns the address bulk actions are posted to, which sends the browser back to the current table.
+func (q *AdminListQuery) BulkURL() string {
+	next := *q
+	next.Path = q.Path + "/bulk"
+
+	return next.URL()
+}
+
+func (q *AdminListQuery) PageURL(page int) string {
+	next := *q
+	next.Page = page
+
+	return next.URL()
+}
+
+func adminTotalPages(count int) int {
+	return max((count+AdminPageSize-1)/AdminPageSize, 1)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// adminBulkReturnURL returns the admin table a bulk action was submitted from.
+func adminBulkReturnURL(r *http.Request) string {
+	path := strings.TrimSuffix(r.URL.Path, "/bulk")
+
+	if r.URL.RawQuery != "" {
+		return path + "?" + r.URL.RawQuery
+	}
+
+	return path
+}
+
+func (h *Handler) HandleAdminDashboardPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		monthStart := startOfMonth(time.Now())
+
+		postsThisMonth, err := h.BlogSvc.CountPosts(r.Context(), blog.ListPostsParams{CreatedAfter: monthStart})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count posts", "error", err)
+			http.Error(w, "failed to count posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		newUsers, err := h.AuthSvc.CountUsers(r.Context(), auth.ListUsersParams{CreatedAfter: monthStart})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count users", "error", err)
+			http.Error(w, "failed to count users", http.StatusInternalServerError)
+
+			return
+		}
+
+		pendingComments, err := h.BlogSvc.CountComments(
+			r.Context(),
+			blog.ListCommentsParams{Status: blog.CommentStatusPending},
+		)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count comments", "error", err)
+			http.Error(w, "failed to count comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			"PostsThisMonth":  postsThisMonth,
+			"NewUsers":        newUsers,
+			"PendingComments": pendingComments,
+		}
+
+		h.renderTemplate(w, r, "admin-dashboard-page.gohtml", &Metadata{Title: "Admin", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminUsersPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, []string{"username", "name", "email_address", "created_at"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params := auth.ListUsersParams{
+			Search:   query.Search,
+			SortBy:   query.Sort,
+			SortDesc: query.Desc,
+			Limit:    AdminPageSize,
+			Offset:   query.Offset(),
+		}
+
+		users, err := h.AuthSvc.ListUsers(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list users", "error", err)
+			http.Error(w, "failed to list users", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.AuthSvc.CountUsers(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count users", "error", err)
+			http.Error(w, "failed to count users", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Query":          query,
+			"Users":          users,
+			"DeletedUserID":  auth.DeletedUserID,
+			"Now":            time.Now(),
+			"Count":          count,
+			"TotalPages":     adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-users-page.gohtml", &Metadata{Title: "Users", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminUsersBulk() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		returnURL := adminBulkReturnURL(r)
+		ids := r.PostForm["ids"]
+
+		if len(ids) == 0 {
+			h.addErrorMessage(w, r, "No users selected.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		if slices.Contains(ids, auth.DeletedUserID) {
+			h.addErrorMessage(w, r, "The deleted user placeholder cannot be changed.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		currentUser := userFromContext(r.Context())
+
+		var action func(id string) error
+
+		switch r.PostFormValue("action") {
+		case "disable":
+			if slices.Contains(ids, currentUser.ID) {
+				h.addErrorMessage(w, r, "You cannot disable your own account.")
+				http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+				return
+			}
+
+			action = func(id string) error { return h.AuthSvc.DisableUser(r.Context(), id) }
+		case "enable":
+			action = func(id string) error { return h.AuthSvc.EnableUser(r.Context(), id) }
+		case "ban":
+			banDays, err := strconv.Atoi(r.PostFormValue("banDays"))
+			if err != nil || banDays < 1 {
+				h.addErrorMessage(w, r, "Ban duration must be a positive number of days.")
+				http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+				return
+			}
+
+			if slices.Contains(ids, currentUser.ID) {
+				h.addErrorMessage(w, r, "You cannot ban your own account.")
+				http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+				return
+			}
+
+			until := time.Now().AddDate(0, 0, banDays)
+			action = func(id string) error { return h.AuthSvc.BanUser(r.Context(), id, until) }
+		case "unban":
+			action = func(id string) error { return h.AuthSvc.UnbanUser(r.Context(), id) }
+		case "make-admin":
+			action = func(id string) error { return h.AuthSvc.SetAdmin(r.Context(), id, true) }
+		case "revoke-admin":
+			if slices.Contains(ids, currentUser.ID) {
+				h.addErrorMessage(w, r, "You cannot revoke your own administrator role.")
+				http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+				return
+			}
+
+			action = func(id string) error { return h.AuthSvc.SetAdmin(r.Context(), id, false) }
+		default:
+			h.addErrorMessage(w, r, "Choose an action.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		for _, id := range ids {
+			err = action(id)
+			if err != nil {
+				if errors.As(err, &auth.UserByIDNotFoundError{}) {
+					continue
+				}
+
+				slog.ErrorContext(r.Context(), "error on update user", "error", err, "userId", id)
+				http.Error(w, "error on update user", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		h.addSuccessMessage(w, r, "Users have been updated successfully.")
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminPostsPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, []string{"title", "created_at", "updated_at"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params := blog.ListPostsParams{
+			Search:   query.Search,
+			SortBy:   query.Sort,
+			SortDesc: query.Desc,
+			Limit:    AdminPageSize,
+			Offset:   query.Offset(),
+		}
+
+		posts, err := h.BlogSvc.ListPosts(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list posts", "error", err)
+			http.Error(w, "failed to list posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.BlogSvc.CountPosts(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count posts", "error", err)
+			http.Error(w, "failed to count posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		authors := make(map[string]*auth.User)
+
+		for _, post := range posts {
+			if _, ok := authors[post.AuthorID]; ok {
+				continue
+			}
+
+			author, err := h.AuthSvc.GetUserByID(r.Context(), post.AuthorID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to get post author", "error", err, "authorId", post.AuthorID)
+				http.Error(w, "failed to get post author", http.StatusInternalServerError)
+
+				return
+			}
+
+			authors[post.AuthorID] = author
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Query":          query,
+			"Posts":          posts,
+			"Authors":        authors,
+			"Count":          count,
+			"TotalPages":     adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-posts-page.gohtml", &Metadata{Title: "Posts", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminPostsBulk() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		returnURL := adminBulkReturnURL(r)
+		ids := r.PostForm["ids"]
+
+		if len(ids) == 0 {
+			h.addErrorMessage(w, r, "No posts selected.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		var action func(id string) error
+
+		switch r.PostFormValue("action") {
+		case "delete":
+			action = func(id string) error { return h.BlogSvc.DeletePost(r.Context(), id) }
+		case "change-author":
+			username := strings.TrimSpace(r.PostFormValue("author"))
+
+			author, err := h.AuthSvc.GetUserByUsername(r.Context(), username)
+			if err != nil {
+				if errors.As(err, &auth.UserByUsernameNotFoundError{}) {
+					h.addErrorMessage(w, r, "No user with that username.")
+					http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+					return
+				}
+
+				slog.ErrorContext(r.Context(), "error retrieving user", "error", err)
+				http.Error(w, "error on retrieving user", http.StatusInternalServerError)
+
+				return
+			}
+
+			if author.ID == auth.DeletedUserID {
+				h.addErrorMessage(w, r, "Posts cannot be given to the deleted user placeholder.")
+				http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+				return
+			}
+
+			action = func(id string) error { return h.BlogSvc.ChangePostAuthor(r.Context(), id, author.ID) }
+		default:
+			h.addErrorMessage(w, r, "Choose an action.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		for _, id := range ids {
+			err = action(id)
+			if err != nil {
+				if errors.As(err, &blog.PostByIDNotFoundError{}) {
+					continue
+				}
+
+				slog.ErrorContext(r.Context(), "error on update post", "error", err, "postId", id)
+				http.Error(w, "error on update post", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		h.addSuccessMessage(w, r, "Posts have been updated successfully.")
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminCommentsPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, []string{"created_at"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		status := blog.CommentStatus(query.Status)
+		if status != "" && status != blog.CommentStatusApproved && status != blog.CommentStatusPending {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+
+			return
+		}
+
+		params := blog.ListCommentsParams{
+			Status:         status,
+			IncludePending: true,
+			Search:         query.Search,
+			SortBy:         query.Sort,
+			SortDesc:       query.Desc,
+			Limit:          AdminPageSize,
+			Offset:         query.Offset(),
+		}
+
+		// Newest comments are the ones that need attention, so they come first unless sorted otherwise.
+		if params.SortBy == "" {
+			params.SortBy = "created_at"
+			params.SortDesc = true
+		}
+
+		comments, err := h.BlogSvc.ListComments(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list comments", "error", err)
+			http.Error(w, "failed to list comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.BlogSvc.CountComments(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count comments", "error", err)
+			http.Error(w, "failed to count comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		posts := make(map[string]*blog.Post)
+
+		for _, comment := range comments {
+			if _, ok := posts[comment.PostID]; ok {
+				continue
+			}
+
+			post, err := h.BlogSvc.GetPostByID(r.Context(), comment.PostID)
+			if err != nil {
+				// Comments of trashed posts are listed without a link.
+				if errors.As(err, &blog.PostByIDNotFoundError{}) {
+					continue
+				}
+
+				slog.ErrorContext(r.Context(), "failed to get post", "error", err, "postId", comment.PostID)
+				http.Error(w, "failed to get post", http.StatusInternalServerError)
+
+				return
+			}
+
+			posts[comment.PostID] = post
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Query":          query,
+			"Comments":       comments,
+			"Posts":          posts,
+			"Count":          count,
+			"TotalPages":     adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-comments-page.gohtml", &Metadata{Title: "Comments", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminCommentsBulk() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		returnURL := adminBulkReturnURL(r)
+		ids := r.PostForm["ids"]
+
+		if len(ids) == 0 {
+			h.addErrorMessage(w, r, "No comments selected.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		user := userFromContext(r.Context())
+
+		var action func(id string) error
+
+		switch r.PostFormValue("action") {
+		case "approve":
+			action = func(id string) error { return h.BlogSvc.ApproveComment(r.Context(), id, user.ID) }
+		case "delete":
+			action = func(id string) error { return h.BlogSvc.DeleteComment(r.Context(), id, user.ID) }
+		default:
+			h.addErrorMessage(w, r, "Choose an action.")
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+			return
+		}
+
+		for _, id := range ids {
+			err = action(id)
+			if err != nil {
+				if errors.As(err, &blog.CommentByIDNotFoundError{}) {
+					continue
+				}
+
+				slog.ErrorContext(r.Context(), "error on update comment", "error", err, "commentId", id)
+				http.Error(w, "error on update comment", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		h.addSuccessMessage(w, r, "Comments have been updated successfully.")
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+// auditEntriesParams turns the filters of the audit log into list params. The to date is inclusive.
+func auditEntriesParams(query *AdminListQuery) (audit.ListEntriesParams, error) {
+	params := audit.ListEntriesParams{
+		Action:     audit.Action(query.Action),
+		TargetType: query.TargetType,
+		Search:     query.Search,
+	}
+
+	if params.Action != "" && !slices.Contains(audit.Actions, params.Action) {
+		return params, errors.New("invalid action")
+	}
+
+	switch params.TargetType {
+	case "", audit.TargetTypeUser, audit.TargetTypePost, audit.TargetTypeComment:
+	default:
+		return params, errors.New("invalid target type")
+	}
+
+	if query.From != "" {
+		from, err := time.ParseInLocation(time.DateOnly, query.From, time.Local)
+		if err != nil {
+			return params, errors.New("invalid from date")
+		}
+
+		params.From = from
+	}
+
+	if query.To != "" {
+		to, err := time.ParseInLocation(time.DateOnly, query.To, time.Local)
+		if err != nil {
+			return params, errors.New("invalid to date")
+		}
+
+		params.To = to.AddDate(0, 0, 1)
+	}
+
+	return params, nil
+}
+
+func (h *Handler) HandleAdminAuditPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params, err := auditEntriesParams(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params.Limit = AdminPageSize
+		params.Offset = query.Offset()
+
+		entries, err := h.AuditSvc.ListEntries(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list audit entries", "error", err)
+			http.Error(w, "failed to list audit entries", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.AuditSvc.CountEntries(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count audit entries", "error", err)
+			http.Error(w, "failed to count audit entries", http.StatusInternalServerError)
+
+			return
+		}
+
+		csvQuery := *query
+		csvQuery.Path = "/admin/audit.csv"
+		csvQuery.Page = 1
+
+		data := map[string]any{
+			"Query":       query,
+			"Entries":     entries,
+			"Actions":     audit.Actions,
+			"TargetTypes": []string{audit.TargetTypeUser, audit.TargetTypePost, audit.TargetTypeComment},
+			"CSVURL":      csvQuery.URL(),
+			"Count":       count,
+			"TotalPages":  adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-audit-page.gohtml", &Metadata{Title: "Audit log", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+// HandleAdminAuditCSV exports every audit entry that matches the filters of the audit log.
+func (h *Handler) HandleAdminAuditCSV() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params, err := auditEntriesParams(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		entries, err := h.AuditSvc.ListEntries(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list audit entries", "error", err)
+			http.Error(w, "failed to list audit entries", http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+
+		cw := csv.NewWriter(w)
+
+		err = cw.Write([]string{
+			"time",
+			"action",
+			"actor_id",
+			"actor_username",
+			"target_type",
+			"target_id",
+			"ip_address",
+			"user_agent",
+			"changes",
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to write audit log header", "error", err)
+
+			return
+		}
+
+		for _, entry := range entries {
+			changes, err := json.Marshal(entry.Changes)
+			if err != nil {
+				slog.ErrorContext(
+					r.Context(),
+					"failed to marshal audit entry changes",
+					"error",
+					err,
+					"entryId",
+					entry.ID,
+				)
+
+				return
+			}
+
+			err = cw.Write([]string{
+				entry.CreatedAt.Format(time.RFC3339),
+				csvCell(string(entry.Action)),
+				csvCell(entry.ActorID),
+				csvCell(entry.ActorUsername),
+				csvCell(entry.TargetType),
+				csvCell(entry.TargetID),
+				csvCell(entry.IPAddress),
+				csvCell(entry.UserAgent),
+				csvCell(string(changes)),
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to write audit entry", "error", err, "entryId", entry.ID)
+
+				return
+			}
+		}
+
+		cw.Flush()
+
+		err = cw.Error()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to flush audit log", "error", err)
+		}
+	})
+
+	return h.AdminOnly(hf)
+}
+
+// csvCell keeps spreadsheets from running a value as a formula by prefixing the characters that start one with a
+// quote. Usernames, user agents and target IDs come from users.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
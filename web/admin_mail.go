@@ -0,0 +1,89 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+func (h *Handler) HandleAdminMailPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseAdminListQuery(r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		status := mailer.OutboxStatus(query.Status)
+		if status != "" && !slices.Contains(mailer.OutboxStatuses, status) {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+
+			return
+		}
+
+		params := mailer.ListOutboxMessagesParams{
+			Status: status,
+			Limit:  AdminPageSize,
+			Offset: query.Offset(),
+		}
+
+		messages, err := h.Outbox.ListMessages(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list outbox messages", "error", err)
+			http.Error(w, "failed to list outbox messages", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.Outbox.CountMessages(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count outbox messages", "error", err)
+			http.Error(w, "failed to count outbox messages", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Query":          query,
+			"Messages":       messages,
+			"Statuses":       mailer.OutboxStatuses,
+			"Count":          count,
+			"TotalPages":     adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-mail-page.gohtml", &Metadata{Title: "Mail", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminRetryMail() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.Outbox.Retry(r.Context(), r.PathValue("messageId"))
+		if err != nil {
+			switch {
+			case errors.As(err, &mailer.OutboxMessageByIDNotFoundError{}):
+				http.Error(w, "outbox message not found", http.StatusNotFound)
+			case errors.Is(err, mailer.ErrOutboxMessageNotFailed):
+				http.Error(w, "only failed messages can be retried", http.StatusBadRequest)
+			default:
+				slog.ErrorContext(r.Context(), "error on retry outbox message", "error", err)
+				http.Error(w, "error on retry outbox message", http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Message has been queued again.")
+
+		http.Redirect(w, r, "/admin/mail", http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
@@ -0,0 +1,23 @@
+package web
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"alice":                 "alice",
+		"=HYPERLINK(\"x\")":     "'=HYPERLINK(\"x\")",
+		"+1":                    "'+1",
+		"-1+1":                  "'-1+1",
+		"@SUM(A1)":              "'@SUM(A1)",
+		"\t=1":                  "'\t=1",
+		"Mozilla/5.0 (=1)":      "Mozilla/5.0 (=1)",
+		"b6a6ad8e-0d5e-4c3a-9f": "b6a6ad8e-0d5e-4c3a-9f",
+	}
+
+	for value, want := range tests {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, expected %q", value, got, want)
+		}
+	}
+}
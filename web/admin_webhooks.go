@@ -0,0 +1,241 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
+)
+
+func (h *Handler) HandleAdminWebhooksPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subs, err := h.WebhookSvc.ListSubscriptions(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list webhook subscriptions", "error", err)
+			http.Error(w, "failed to list webhook subscriptions", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Subscriptions":  subs,
+			"EventTypes":     webhook.EventTypes,
+		}
+
+		h.renderTemplate(w, r, "admin-webhooks-page.gohtml", &Metadata{Title: "Webhooks", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func webhookSubscriptionRequestFromForm(r *http.Request) *webhook.SubscriptionRequest {
+	req := &webhook.SubscriptionRequest{
+		URL:    strings.TrimSpace(r.PostFormValue("url")),
+		Secret: strings.TrimSpace(r.PostFormValue("secret")),
+		Active: r.PostFormValue("active") == "on",
+	}
+
+	for _, eventType := range r.PostForm["eventTypes"] {
+		req.EventTypes = append(req.EventTypes, webhook.EventType(eventType))
+	}
+
+	return req
+}
+
+// webhookFormError reports an invalid subscription as form errors and sends the browser back to the form.
+func (h *Handler) webhookFormError(w http.ResponseWriter, r *http.Request, err error, returnURL string) bool {
+	var invalidErr webhook.InvalidSubscriptionError
+	if !errors.As(err, &invalidErr) {
+		return false
+	}
+
+	h.addErrorMessage(w, r, "Invalid form submission.")
+
+	err = h.addFormErrorsToSession(w, r, "WebhookForm", map[string]any{
+		invalidErr.Field: invalidErr.Field + " " + invalidErr.Reason,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error adding form errors to session", "error", err)
+		h.addErrorMessage(w, r, "Error adding form errors.")
+	}
+
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+
+	return true
+}
+
+func (h *Handler) HandleAdminCreateWebhook() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		sub, err := h.WebhookSvc.CreateSubscription(r.Context(), webhookSubscriptionRequestFromForm(r))
+		if err != nil {
+			if h.webhookFormError(w, r, err, "/admin/webhooks") {
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on create webhook subscription", "error", err)
+			http.Error(w, "error on create webhook subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Webhook has been created successfully.")
+		http.Redirect(w, r, "/admin/webhooks/"+sub.ID, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminWebhookPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, err := h.WebhookSvc.GetSubscription(r.Context(), r.PathValue("webhookId"))
+		if err != nil {
+			if errors.As(err, &webhook.SubscriptionByIDNotFoundError{}) {
+				http.Error(w, "webhook not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get webhook subscription", "error", err)
+			http.Error(w, "failed to get webhook subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		query, err := parseAdminListQuery(r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		params := webhook.ListDeliveriesParams{
+			SubscriptionID: sub.ID,
+			Limit:          AdminPageSize,
+			Offset:         query.Offset(),
+		}
+
+		deliveries, err := h.WebhookSvc.ListDeliveries(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list webhook deliveries", "error", err)
+			http.Error(w, "failed to list webhook deliveries", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.WebhookSvc.CountDeliveries(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count webhook deliveries", "error", err)
+			http.Error(w, "failed to count webhook deliveries", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Subscription":   sub,
+			"EventTypes":     webhook.EventTypes,
+			"Query":          query,
+			"Deliveries":     deliveries,
+			"Count":          count,
+			"TotalPages":     adminTotalPages(count),
+		}
+
+		h.renderTemplate(w, r, "admin-webhook-page.gohtml", &Metadata{Title: "Webhook", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminUpdateWebhook() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		id := r.PathValue("webhookId")
+
+		err = h.WebhookSvc.UpdateSubscription(r.Context(), id, webhookSubscriptionRequestFromForm(r))
+		if err != nil {
+			if h.webhookFormError(w, r, err, "/admin/webhooks/"+id) {
+				return
+			}
+
+			if errors.As(err, &webhook.SubscriptionByIDNotFoundError{}) {
+				http.Error(w, "webhook not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on update webhook subscription", "error", err)
+			http.Error(w, "error on update webhook subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Webhook has been updated successfully.")
+		http.Redirect(w, r, "/admin/webhooks/"+id, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminDeleteWebhook() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.WebhookSvc.DeleteSubscription(r.Context(), r.PathValue("webhookId"))
+		if err != nil && !errors.As(err, &webhook.SubscriptionByIDNotFoundError{}) {
+			slog.ErrorContext(r.Context(), "error on delete webhook subscription", "error", err)
+			http.Error(w, "error on delete webhook subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Webhook has been deleted successfully.")
+		http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminRedeliverWebhook() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivery, err := h.WebhookSvc.Redeliver(r.Context(), r.PathValue("deliveryId"))
+		if err != nil {
+			if errors.As(err, &webhook.DeliveryByIDNotFoundError{}) {
+				http.Error(w, "webhook delivery not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on redeliver webhook", "error", err)
+			http.Error(w, "error on redeliver webhook", http.StatusInternalServerError)
+
+			return
+		}
+
+		if delivery.Status == webhook.DeliveryStatusSucceeded {
+			h.addSuccessMessage(w, r, "Webhook has been redelivered successfully.")
+		} else {
+			h.addErrorMessage(w, r, "Redelivery failed: "+delivery.LastError)
+		}
+
+		http.Redirect(w, r, "/admin/webhooks/"+delivery.SubscriptionID, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
@@ -0,0 +1,247 @@
+package web
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+// avatarUploadPath is the only path that accepts files.
+const avatarUploadPath = "/profile/avatar"
+
+// maxAvatarRequestSize leaves room for the other fields of the avatar upload form.
+const maxAvatarRequestSize = auth.MaxAvatarFileSize + 64<<10
+
+// AvatarUploadLimitMiddleware limits the size of avatar uploads. It must wrap the CSRF middleware, which reads
+// the whole form to find the token.
+func (h *Handler) AvatarUploadLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == avatarUploadPath {
+			r.Body = http.MaxBytesReader(w, r.Body, maxAvatarRequestSize)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleAvatar serves the avatar of a user in the size query parameter. Uploaded avatars are served from the
+// database. Users without one get their avatar URL when remote avatars are allowed, and the fallback avatar
+// chosen in the settings otherwise.
+func (h *Handler) HandleAvatar() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := auth.AvatarSizeMedium
+
+		if s := r.URL.Query().Get("size"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+
+				return
+			}
+
+			size = auth.AvatarSize(n)
+		}
+
+		user, err := h.AuthSvc.GetUserByID(r.Context(), r.PathValue("userId"))
+		if err != nil {
+			if errors.As(err, &auth.UserByIDNotFoundError{}) {
+				http.Error(w, "user not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get user by id", "error", err)
+			http.Error(w, "failed to get user by id", http.StatusInternalServerError)
+
+			return
+		}
+
+		// Avatars change under the same URL, so browsers have to check for a new one every time.
+		w.Header().Set("Cache-Control", "no-cache")
+
+		avatar, err := h.AuthSvc.GetAvatar(r.Context(), user.ID, size)
+		if err == nil {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("ETag", fmt.Sprintf(`"%d-%d"`, avatar.Size, avatar.CreatedAt.UnixNano()))
+			http.ServeContent(w, r, "", avatar.CreatedAt, bytes.NewReader(avatar.Image))
+
+			return
+		}
+
+		if !errors.As(err, &auth.AvatarNotFoundError{}) {
+			slog.ErrorContext(r.Context(), "failed to get avatar", "error", err)
+			http.Error(w, "failed to get avatar", http.StatusInternalServerError)
+
+			return
+		}
+
+		remoteAvatars, err := h.SettingsSvc.GetBool(r.Context(), settings.KeyRemoteAvatars)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get remote avatars setting", "error", err)
+			http.Error(w, "failed to get remote avatars setting", http.StatusInternalServerError)
+
+			return
+		}
+
+		if remoteAvatars && user.AvatarURL != "" {
+			http.Redirect(w, r, user.AvatarURL, http.StatusFound)
+
+			return
+		}
+
+		fallback, err := h.SettingsSvc.GetAvatarFallback(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get avatar fallback", "error", err)
+			http.Error(w, "failed to get avatar fallback", http.StatusInternalServerError)
+
+			return
+		}
+
+		if fallback == settings.AvatarFallbackGravatar && user.ID != auth.DeletedUserID {
+			http.Redirect(w, r, auth.GravatarURL(user.EmailAddress, size), http.StatusFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'")
+
+		_, err = w.Write(initialsAvatar(cmp.Or(user.Name, user.Username), user.ID))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to write avatar", "error", err)
+		}
+	})
+}
+
+// initialsAvatar draws the initials of name on a background color picked by seed.
+func initialsAvatar(name, seed string) []byte {
+	var initials []rune
+
+	for word := range strings.FieldsSeq(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				initials = append(initials, unicode.ToUpper(r))
+
+				break
+			}
+		}
+
+		if len(initials) == 2 {
+			break
+		}
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(seed))
+	hue := hash.Sum32() % 360
+
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">`+
+		`<rect width="100" height="100" fill="hsl(%d, 45%%, 45%%)"/>`+
+		`<text x="50" y="50" dy=".35em" text-anchor="middle" font-family="sans-serif" font-size="40" fill="#fff">`+
+		`%s</text></svg>`, hue, html.EscapeString(string(initials)))
+}
+
+func (h *Handler) HandleProfileAvatarUpload() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+
+		formErrors := map[string]any{}
+
+		file, _, err := r.FormFile("avatar")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+
+			switch {
+			case errors.Is(err, http.ErrMissingFile):
+				formErrors["Avatar"] = "Choose an image to upload"
+			case errors.As(err, &maxBytesErr):
+				formErrors["Avatar"] = fmt.Sprintf("The image must be at most %d MB", auth.MaxAvatarFileSize>>20)
+			default:
+				slog.ErrorContext(r.Context(), "error on read avatar", "error", err)
+				http.Error(w, "error on read avatar", http.StatusInternalServerError)
+
+				return
+			}
+		} else {
+			defer func() {
+				_ = file.Close()
+			}()
+
+			err = h.AuthSvc.SetAvatar(r.Context(), user.ID, file)
+
+			switch {
+			case err == nil:
+			case errors.Is(err, auth.ErrUnsupportedAvatar):
+				formErrors["Avatar"] = "The avatar must be a PNG, JPEG or GIF image"
+			case errors.Is(err, auth.ErrAvatarTooLarge):
+				formErrors["Avatar"] = fmt.Sprintf("The image must be at most %d MB", auth.MaxAvatarFileSize>>20)
+			default:
+				slog.ErrorContext(r.Context(), "error on set avatar", "error", err)
+				http.Error(w, "error on set avatar", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		if len(formErrors) > 0 {
+			h.addErrorMessage(w, r, "Invalid form submission.")
+
+			err := h.addFormErrorsToSession(w, r, "ProfileAvatarForm", formErrors)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error adding form errors to session", "error", err)
+				h.addErrorMessage(w, r, "Error adding form errors.")
+			}
+
+			http.Redirect(w, r, "/profile", http.StatusSeeOther)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Avatar has been updated successfully.")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleProfileAvatarDelete() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.AuthSvc.RemoveAvatar(r.Context(), userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on remove avatar", "error", err)
+			http.Error(w, "error on remove avatar", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Avatar has been removed.")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// hasAvatar tells whether a user has uploaded an avatar.
+func (h *Handler) hasAvatar(r *http.Request, userID string) (bool, error) {
+	_, err := h.AuthSvc.GetAvatar(r.Context(), userID, auth.AvatarSizeSmall)
+	if err != nil {
+		if errors.As(err, &auth.AvatarNotFoundError{}) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
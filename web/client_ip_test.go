@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	h := &Handler{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  string
+		wantIPAddress string
+	}{
+		{
+			name:          "direct request",
+			remoteAddr:    "203.0.113.5:1234",
+			wantIPAddress: "203.0.113.5",
+		},
+		{
+			name:          "forwarded for from an untrusted client",
+			remoteAddr:    "203.0.113.5:1234",
+			forwardedFor:  "198.51.100.7",
+			wantIPAddress: "203.0.113.5",
+		},
+		{
+			name:          "forwarded for from a trusted proxy",
+			remoteAddr:    "10.0.0.1:1234",
+			forwardedFor:  "198.51.100.7",
+			wantIPAddress: "198.51.100.7",
+		},
+		{
+			name:          "spoofed address before the client",
+			remoteAddr:    "10.0.0.1:1234",
+			forwardedFor:  "1.2.3.4, 198.51.100.7",
+			wantIPAddress: "198.51.100.7",
+		},
+		{
+			name:          "chain of trusted proxies",
+			remoteAddr:    "10.0.0.1:1234",
+			forwardedFor:  "198.51.100.7, 192.168.1.2",
+			wantIPAddress: "198.51.100.7",
+		},
+		{
+			name:          "trusted proxy without forwarded for",
+			remoteAddr:    "10.0.0.1:1234",
+			wantIPAddress: "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			got := h.clientIP(r)
+			if got != tt.wantIPAddress {
+				t.Errorf("expected %q, got %q", tt.wantIPAddress, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"errors"
+	"html"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/mailer"
+)
+
+var linkRegexp = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// messageLinks returns the URLs in a message, so links such as password reset links can be followed from the inbox.
+func messageLinks(msg *mailer.Message) []string {
+	body := msg.Text
+	if body == "" {
+		body = html.UnescapeString(msg.HTML)
+	}
+
+	links := linkRegexp.FindAllString(body, -1)
+	slices.Sort(links)
+
+	return slices.Compact(links)
+}
+
+func (h *Handler) HandleDevMailPage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Messages":       h.DevMailbox.Messages(),
+		}
+
+		h.renderTemplate(w, r, "dev-mail-page.gohtml", &Metadata{Title: "Dev Mail", NoIndex: true}, data)
+	})
+}
+
+func (h *Handler) HandleDevMailMessagePage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message, err := h.DevMailbox.Message(r.PathValue("messageId"))
+		if err != nil {
+			if errors.As(err, &mailer.CapturedMessageByIDNotFoundError{}) {
+				http.Error(w, "message not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get captured message", "error", err)
+			http.Error(w, "failed to get captured message", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			"Message": message,
+			"Links":   messageLinks(message.Message),
+		}
+
+		h.renderTemplate(
+			w,
+			r,
+			"dev-mail-message-page.gohtml",
+			&Metadata{Title: message.Message.Subject, NoIndex: true},
+			data,
+		)
+	})
+}
+
+func (h *Handler) HandleDevMailClear() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.DevMailbox.Clear()
+
+		http.Redirect(w, r, "/_dev/mail", http.StatusSeeOther)
+	})
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"cmp"
+	"fmt"
 	"html/template"
 	"time"
 )
@@ -22,4 +23,17 @@ var Funcs = template.FuncMap{
 	"add": func(a, b int) int {
 		return a + b
 	},
+	"auditValue": formatAuditValue,
+}
+
+// formatAuditValue renders a field value from the changes of an audit entry.
+func formatAuditValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
 }
@@ -1,18 +1,26 @@
 package web
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"embed"
 	"encoding/gob"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log/slog"
 	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -20,9 +28,16 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/sessions"
+	"github.com/nasermirzaei89/fullstackgo/audit"
 	"github.com/nasermirzaei89/fullstackgo/auth"
 	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/dataexport"
+	"github.com/nasermirzaei89/fullstackgo/jobqueue"
 	"github.com/nasermirzaei89/fullstackgo/mailer"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -35,17 +50,32 @@ var (
 )
 
 type Handler struct {
-	handler            http.Handler
-	static             fs.FS
-	CookieStore        *sessions.CookieStore
-	SessionName        string
-	template           *template.Template
-	AuthSvc            *auth.Service
-	BlogSvc            *blog.Service
-	Mailer             mailer.Mailer
+	handler      http.Handler
+	static       fs.FS
+	highlightCSS []byte
+	startedAt    time.Time
+	CookieStore  *sessions.CookieStore
+	SessionName  string
+	template     *template.Template
+	AuthSvc      *auth.Service
+	BlogSvc      *blog.Service
+	JobQueue     *jobqueue.Queue
+	Outbox       *mailer.Outbox
+	// DevMailbox enables the /_dev/mail inbox. Set it only in development.
+	DevMailbox         *mailer.MemoryMailer
 	CSRFAuthKeys       []byte
 	CSRFTrustedOrigins []string
-	isShuttingDown     atomic.Bool
+	// TrustedProxies lists the addresses and CIDR ranges of the reverse proxies whose X-Forwarded-For is believed.
+	TrustedProxies  []string
+	SettingsSvc     *settings.Service
+	DataExportSvc   *dataexport.Service
+	AuditSvc        *audit.Service
+	WebhookSvc      *webhook.Service
+	NewsletterSvc   *newsletter.Service
+	NotificationSvc *notification.Service
+	BaseURL         string
+	RobotsDisallow  []string
+	isShuttingDown  atomic.Bool
 }
 
 type contextKeyUserType struct{}
@@ -78,6 +108,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if h.handler == nil {
 		h.static, _ = fs.Sub(embeddedStaticFS, "static")
 
+		highlightCSS, err := blog.HighlightCSS()
+		if err != nil {
+			panic(fmt.Errorf("failed to generate highlight css: %w", err))
+		}
+
+		h.highlightCSS = highlightCSS
+		h.startedAt = time.Now()
+
 		h.template = template.Must(
 			template.New("").Funcs(Funcs).ParseFS(embeddedTemplatesFS, "templates/*.gohtml", "templates/icons/*.svg"),
 		)
@@ -101,21 +139,84 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		mux.Handle("GET /profile", h.HandleProfilePage())
 		mux.Handle("POST /profile", h.HandleProfileUpdate())
 		mux.Handle("POST /profile/password", h.HandleProfilePasswordUpdate())
+		mux.Handle("POST /profile/avatar", h.HandleProfileAvatarUpload())
+		mux.Handle("POST /profile/avatar/delete", h.HandleProfileAvatarDelete())
+		mux.Handle("POST /profile/notifications", h.HandleProfileNotificationsUpdate())
+		mux.Handle("POST /profile/export", h.HandleProfileExport())
+		mux.Handle("GET /data-exports/{exportId}", h.HandleDownloadDataExport())
+		mux.Handle("POST /profile/delete", h.HandleProfileDelete())
 
 		mux.Handle("GET /posts/{postSlug}", h.HandleViewPostPage())
 		mux.Handle("GET /posts/new", h.HandleNewPostPage())
 		mux.Handle("POST /posts", h.HandleCreatePost())
+		mux.Handle("POST /posts/preview", h.HandlePreviewPost())
 		mux.Handle("GET /posts/{postSlug}/edit", h.HandleEditPostPage())
 		mux.Handle("POST /posts/{postSlug}/edit", h.HandleEditPost())
 		mux.Handle("GET /posts/{postSlug}/delete", h.HandleDeletePostPage())
 		mux.Handle("POST /posts/{postSlug}/delete", h.HandleDeletePost())
 
+		mux.Handle("GET /newsletter", h.HandleNewsletterPage())
+		mux.Handle("POST /newsletter", h.HandleNewsletterSubscribe())
+		mux.Handle("GET /newsletter/confirm", h.HandleNewsletterConfirm())
+		mux.Handle("GET /newsletter/unsubscribe", h.HandleNewsletterUnsubscribePage())
+		mux.Handle("POST /newsletter/unsubscribe", h.HandleNewsletterUnsubscribe())
+		mux.Handle("POST /newsletter/frequency", h.HandleNewsletterFrequencyUpdate())
+		mux.Handle("POST /newsletter/cancel", h.HandleNewsletterCancel())
+
+		mux.Handle("GET /notifications", h.HandleNotificationsPage())
+		mux.Handle("GET /notifications/unread-count", h.HandleNotificationsUnreadCount())
+		mux.Handle("POST /notifications/read-all", h.HandleNotificationsReadAll())
+		mux.Handle("POST /notifications/{notificationId}/read", h.HandleNotificationRead())
+		mux.Handle("GET /notifications/unsubscribe", h.HandleNotificationsUnsubscribePage())
+		mux.Handle("POST /notifications/unsubscribe", h.HandleNotificationsUnsubscribe())
+
+		mux.Handle("GET /users/autocomplete", h.HandleUserAutocomplete())
+		mux.Handle("GET /users/{username}", h.HandleUserProfilePage())
+		mux.Handle("GET /avatars/{userId}", h.HandleAvatar())
+
 		mux.Handle("POST /comments", h.HandleSubmitComment())
 		mux.Handle("GET /comments/{commentId}/edit", h.HandleEditCommentPage())
 		mux.Handle("POST /comments/{commentId}/edit", h.HandleEditComment())
 		mux.Handle("GET /comments/{commentId}/delete", h.HandleDeleteCommentPage())
 		mux.Handle("POST /comments/{commentId}/delete", h.HandleDeleteComment())
 
+		mux.Handle("POST /comments/{commentId}/approve", h.HandleApproveComment())
+
+		mux.Handle("GET /trash", h.HandleTrashPage())
+		mux.Handle("POST /trash/posts/{postId}/restore", h.HandleRestorePost())
+		mux.Handle("POST /trash/posts/{postId}/delete", h.HandlePurgePost())
+		mux.Handle("POST /trash/comments/{commentId}/restore", h.HandleRestoreComment())
+		mux.Handle("POST /trash/comments/{commentId}/delete", h.HandlePurgeComment())
+
+		mux.Handle("GET /admin", h.HandleAdminDashboardPage())
+		mux.Handle("GET /admin/users", h.HandleAdminUsersPage())
+		mux.Handle("POST /admin/users/bulk", h.HandleAdminUsersBulk())
+		mux.Handle("GET /admin/posts", h.HandleAdminPostsPage())
+		mux.Handle("POST /admin/posts/bulk", h.HandleAdminPostsBulk())
+		mux.Handle("GET /admin/comments", h.HandleAdminCommentsPage())
+		mux.Handle("POST /admin/comments/bulk", h.HandleAdminCommentsBulk())
+		mux.Handle("GET /admin/audit", h.HandleAdminAuditPage())
+		mux.Handle("GET /admin/audit.csv", h.HandleAdminAuditCSV())
+		mux.Handle("GET /admin/webhooks", h.HandleAdminWebhooksPage())
+		mux.Handle("POST /admin/webhooks", h.HandleAdminCreateWebhook())
+		mux.Handle("GET /admin/webhooks/{webhookId}", h.HandleAdminWebhookPage())
+		mux.Handle("POST /admin/webhooks/{webhookId}", h.HandleAdminUpdateWebhook())
+		mux.Handle("POST /admin/webhooks/{webhookId}/delete", h.HandleAdminDeleteWebhook())
+		mux.Handle("POST /admin/webhook-deliveries/{deliveryId}/redeliver", h.HandleAdminRedeliverWebhook())
+		mux.Handle("GET /admin/mail", h.HandleAdminMailPage())
+		mux.Handle("POST /admin/mail/{messageId}/retry", h.HandleAdminRetryMail())
+		mux.Handle("GET /admin/settings", h.HandleAdminSettingsPage())
+		mux.Handle("POST /admin/settings", h.HandleAdminSettingsUpdate())
+
+		if h.DevMailbox != nil {
+			mux.Handle("GET /_dev/mail", h.HandleDevMailPage())
+			mux.Handle("GET /_dev/mail/{messageId}", h.HandleDevMailMessagePage())
+			mux.Handle("POST /_dev/mail/clear", h.HandleDevMailClear())
+		}
+
+		mux.HandleFunc("GET /robots.txt", h.HandleRobotsTxt)
+		mux.HandleFunc("GET /sitemap.xml", h.HandleSitemap)
+
 		mux.HandleFunc("GET /", h.HandleIndex)
 
 		// CSRF Middleware
@@ -127,7 +228,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Auth middleware
 		authMW := h.AuthMiddleware()
 
-		h.handler = gzipMW(csrfMW(h.RecoverMiddleware(authMW(mux))))
+		// Audit middleware
+		auditMW := h.AuditMiddleware()
+
+		h.handler = gzipMW(
+			h.AvatarUploadLimitMiddleware(
+				h.OneClickUnsubscribeMiddleware(csrfMW(h.RecoverMiddleware(authMW(auditMW(mux))))),
+			),
+		)
 	}
 
 	h.handler.ServeHTTP(w, r)
@@ -237,6 +345,22 @@ func (h *Handler) addSessionFlash(
 	return nil
 }
 
+// oneClickUnsubscribePaths accept the one-click unsubscribe POST of mail clients (RFC 8058), which carries no CSRF
+// token. The links themselves are signed.
+var oneClickUnsubscribePaths = []string{"/newsletter/unsubscribe", "/notifications/unsubscribe"}
+
+// OneClickUnsubscribeMiddleware skips the CSRF check for one-click unsubscribe requests. It must wrap the CSRF
+// middleware.
+func (h *Handler) OneClickUnsubscribeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && slices.Contains(oneClickUnsubscribePaths, r.URL.Path) {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handler) RecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func(ctx context.Context) {
@@ -309,6 +433,28 @@ func (h *Handler) AuthMiddleware() func(http.Handler) http.Handler {
 					return
 				}
 
+				// Disabled and banned users are signed out on their next request.
+				if user.IsDisabled() || user.IsBanned(time.Now()) {
+					err = h.deleteSessionValue(w, r, "username")
+					if err != nil {
+						slog.ErrorContext(
+							r.Context(),
+							"error on deleting session value",
+							"key",
+							"username",
+							"error",
+							err,
+						)
+						http.Error(w, "error on deleting session value", http.StatusInternalServerError)
+
+						return
+					}
+
+					next.ServeHTTP(w, r)
+
+					return
+				}
+
 				r = r.WithContext(context.WithValue(r.Context(), contextKeyUser, user))
 			}
 
@@ -317,6 +463,26 @@ func (h *Handler) AuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// AuditMiddleware makes the signed-in user, client IP and user agent the actor of the events recorded while serving a
+// request.
+func (h *Handler) AuditMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := audit.Actor{
+				IPAddress: h.clientIP(r),
+				UserAgent: r.UserAgent(),
+			}
+
+			if user := userFromContext(r.Context()); user != nil {
+				actor.UserID = user.ID
+				actor.Username = user.Username
+			}
+
+			next.ServeHTTP(w, r.WithContext(audit.ContextWithActor(r.Context(), actor)))
+		})
+	}
+}
+
 func userFromContext(ctx context.Context) *auth.User {
 	user, ok := ctx.Value(contextKeyUser).(*auth.User)
 	if !ok {
@@ -421,6 +587,41 @@ func (h *Handler) AuthenticatedOnly(next http.Handler) http.Handler {
 	})
 }
 
+// AdminOnly lets authenticated administrators through and answers everyone else with 403 Forbidden.
+func (h *Handler) AdminOnly(next http.Handler) http.Handler {
+	return h.AuthenticatedOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !userFromContext(r.Context()).IsAdmin {
+			http.Error(w, "administrators only", http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// RegistrationOpenOnly sends visitors back to the login page while registration is closed.
+func (h *Handler) RegistrationOpenOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registrationOpen, err := h.SettingsSvc.GetBool(r.Context(), settings.KeyRegistrationOpen)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get registration setting", "error", err)
+			http.Error(w, "failed to get registration setting", http.StatusInternalServerError)
+
+			return
+		}
+
+		if !registrationOpen {
+			h.addErrorMessage(w, r, "Registration is closed.")
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handler) GuestOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if userFromContext(r.Context()) != nil {
@@ -437,26 +638,36 @@ func (h *Handler) renderTemplate(
 	w http.ResponseWriter,
 	r *http.Request,
 	name string,
+	meta *Metadata,
 	extraData map[string]any,
 ) {
+	site, err := h.siteSettings(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get site settings", "error", err)
+		http.Error(w, "failed to get site settings", http.StatusInternalServerError)
+
+		return
+	}
+
+	meta.SiteName = site.Title
+	meta.Path = cmp.Or(meta.Path, r.URL.Path)
+	meta.CanonicalURL = h.absoluteURL(r, meta.Path)
+
 	data := map[string]any{
+		"Meta":          meta,
+		"Site":          site,
 		"CurrentUser":   userFromContext(r.Context()),
 		"CurrentPath":   r.URL.Path,
 		"Notifications": h.notificationsFromSession(w, r),
 		"FormErrors":    h.formErrorsFromSession(w, r),
-		"Lang":          "en",
-		"Dir":           "ltr",
+		"UnreadCount":   h.unreadNotificationCount(r),
+		"Lang":          site.Language,
+		"Dir":           languageDirection(site.Language),
 	}
 
 	maps.Copy(data, extraData)
 
-	data["Title"] = "My Awesome Blog"
-
-	if extraData["Title"] != nil {
-		data["Title"] = fmt.Sprintf("%s | %s", extraData["Title"], data["Title"])
-	}
-
-	err := h.template.ExecuteTemplate(w, name, data)
+	err = h.template.ExecuteTemplate(w, name, data)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "failed to execute template", "error", err)
 		http.Error(w, "failed to execute template", http.StatusInternalServerError)
@@ -465,6 +676,12 @@ func (h *Handler) renderTemplate(
 
 func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 	// w.Header().Set("Cache-Control", "public, max-age=3600")
+	if r.URL.Path == "/highlight.css" {
+		http.ServeContent(w, r, "highlight.css", h.startedAt, bytes.NewReader(h.highlightCSS))
+
+		return
+	}
+
 	http.FileServer(http.FS(h.static)).ServeHTTP(w, r)
 }
 
@@ -489,8 +706,16 @@ func (h *Handler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleHomePage(w http.ResponseWriter, r *http.Request) {
+	postsPerPage, err := h.SettingsSvc.GetInt(r.Context(), settings.KeyPostsPerPage)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get posts per page", "error", err)
+		http.Error(w, "failed to get posts per page", http.StatusInternalServerError)
+
+		return
+	}
+
 	listPostsParams := blog.ListPostsParams{
-		Limit:  10,
+		Limit:  postsPerPage,
 		Offset: 0,
 	}
 
@@ -498,8 +723,6 @@ func (h *Handler) HandleHomePage(w http.ResponseWriter, r *http.Request) {
 
 	page := r.URL.Query().Get("page")
 	if page != "" {
-		var err error
-
 		pageNum, err = strconv.Atoi(page)
 		if err != nil {
 			slog.ErrorContext(r.Context(), "error on parse page number", "error", err, "page", page)
@@ -526,7 +749,7 @@ func (h *Handler) HandleHomePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	totalPosts, err := h.BlogSvc.CountPosts(r.Context())
+	totalPosts, err := h.BlogSvc.CountPosts(r.Context(), blog.ListPostsParams{})
 	if err != nil {
 		slog.ErrorContext(r.Context(), "failed to count posts", "error", err)
 		http.Error(w, "failed to count posts", http.StatusInternalServerError)
@@ -542,17 +765,21 @@ func (h *Handler) HandleHomePage(w http.ResponseWriter, r *http.Request) {
 		"TotalPages":  totalPages,
 	}
 
-	h.renderTemplate(w, r, "home-page.gohtml", data)
+	meta := &Metadata{Path: "/"}
+	if pageNum > 1 {
+		meta.Path = "/?page=" + strconv.Itoa(pageNum)
+	}
+
+	h.renderTemplate(w, r, "home-page.gohtml", meta, data)
 }
 
 func (h *Handler) HandleLoginPage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "Login",
 		}
 
-		h.renderTemplate(w, r, "login-page.gohtml", data)
+		h.renderTemplate(w, r, "login-page.gohtml", &Metadata{Title: "Login", NoIndex: true}, data)
 	})
 
 	return h.GuestOnly(hf)
@@ -571,26 +798,31 @@ func (h *Handler) HandleLogin() http.Handler {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
-		user, err := h.AuthSvc.GetUserByUsername(r.Context(), username)
+		user, err := h.AuthSvc.Authenticate(r.Context(), username, password)
 		if err != nil {
-			if errors.As(err, &auth.UserByUsernameNotFoundError{}) {
+			var bannedErr auth.UserBannedError
+
+			switch {
+			case errors.Is(err, auth.ErrInvalidCredentials):
 				h.addErrorMessage(w, r, "Invalid username or password.")
 				w.WriteHeader(http.StatusUnauthorized)
-				h.HandleLoginPage().ServeHTTP(w, r)
+			case errors.As(err, &auth.UserDisabledError{}):
+				h.addErrorMessage(w, r, "This account has been disabled.")
+				w.WriteHeader(http.StatusForbidden)
+			case errors.As(err, &bannedErr):
+				h.addErrorMessage(
+					w,
+					r,
+					"This account is banned until "+bannedErr.Until.Format("Jan _2, 2006 15:04")+".",
+				)
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				slog.ErrorContext(r.Context(), "error on authenticate user", "error", err)
+				http.Error(w, "error on authenticate user", http.StatusInternalServerError)
 
 				return
 			}
 
-			slog.ErrorContext(r.Context(), "error retrieving user", "error", err)
-			http.Error(w, "error on retrieving user", http.StatusInternalServerError)
-
-			return
-		}
-
-		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-		if err != nil {
-			h.addErrorMessage(w, r, "Invalid username or password.")
-			w.WriteHeader(http.StatusUnauthorized)
 			h.HandleLoginPage().ServeHTTP(w, r)
 
 			return
@@ -623,13 +855,12 @@ func (h *Handler) HandleRegisterPage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "Register",
 		}
 
-		h.renderTemplate(w, r, "register-page.gohtml", data)
+		h.renderTemplate(w, r, "register-page.gohtml", &Metadata{Title: "Register", NoIndex: true}, data)
 	})
 
-	return h.GuestOnly(hf)
+	return h.GuestOnly(h.RegistrationOpenOnly(hf))
 }
 
 func (h *Handler) HandleRegister() http.Handler {
@@ -653,6 +884,17 @@ func (h *Handler) HandleRegister() http.Handler {
 			return
 		}
 
+		err = auth.ValidateUsername(username)
+		if err != nil {
+			http.Error(
+				w,
+				"username can only have letters, digits, dots, hyphens and underscores and must not end with a dot or hyphen",
+				http.StatusBadRequest,
+			)
+
+			return
+		}
+
 		// FIXME: what to do on security?
 		usernameExists, err := h.AuthSvc.UserExistsByUsername(r.Context(), username)
 		if err != nil {
@@ -745,17 +987,16 @@ func (h *Handler) HandleRegister() http.Handler {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 
-	return h.GuestOnly(hf)
+	return h.GuestOnly(h.RegistrationOpenOnly(hf))
 }
 
 func (h *Handler) HandleLogoutPage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "Logout",
 		}
 
-		h.renderTemplate(w, r, "logout-page.gohtml", data)
+		h.renderTemplate(w, r, "logout-page.gohtml", &Metadata{Title: "Logout", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -789,10 +1030,9 @@ func (h *Handler) HandleForgotPasswordPage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "Forgot Password",
 		}
 
-		h.renderTemplate(w, r, "forgot-password-page.gohtml", data)
+		h.renderTemplate(w, r, "forgot-password-page.gohtml", &Metadata{Title: "Forgot Password", NoIndex: true}, data)
 	})
 
 	return h.GuestOnly(hf)
@@ -849,16 +1089,21 @@ func (h *Handler) HandleForgotPassword() http.Handler {
 		}
 
 		// Send reset email
-		resetLink := fmt.Sprintf("%s/reset-password?token=%s", getHostURL(r), resetToken)
-		subject := "Password Reset Request"
-		body := fmt.Sprintf(
-			"To reset your password, click the following link:\n\n%s\n\nIf you did not request a password reset, you can ignore this email.",
-			resetLink,
-		)
+		msg, err := mailer.Render("password-reset", map[string]any{
+			"ResetLink": fmt.Sprintf("%s/reset-password?token=%s", h.baseURL(r), resetToken),
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to render reset email", "error", err)
+			http.Error(w, "failed to render reset email", http.StatusInternalServerError)
+
+			return
+		}
+
+		msg.To = []string{emailAddress}
 
-		err = h.Mailer.SendEmail(r.Context(), emailAddress, subject, body)
+		_, err = h.JobQueue.Enqueue(r.Context(), mailer.SendEmailJobType, msg)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to send reset email", "error", err)
+			slog.ErrorContext(r.Context(), "failed to enqueue reset email", "error", err)
 			// Do not reveal error to user
 		}
 
@@ -869,6 +1114,87 @@ func (h *Handler) HandleForgotPassword() http.Handler {
 	return h.GuestOnly(hf)
 }
 
+// baseURL is the configured public URL of the site, falling back to the host of the request.
+func (h *Handler) baseURL(r *http.Request) string {
+	if h.BaseURL != "" {
+		return strings.TrimSuffix(h.BaseURL, "/")
+	}
+
+	return getHostURL(r)
+}
+
+// absoluteURL resolves ref, which may be a path or an absolute URL, against the base URL of the site.
+func (h *Handler) absoluteURL(r *http.Request, ref string) string {
+	base, err := url.Parse(h.baseURL(r) + "/")
+	if err != nil {
+		return ref
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only read when the request comes from one of
+// TrustedProxies, and then the last address in it that is not a trusted proxy is taken, since clients can put
+// anything at the start of the header.
+func (h *Handler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !h.isTrustedProxy(host) {
+		return host
+	}
+
+	addresses := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+		if address == "" {
+			continue
+		}
+
+		if !h.isTrustedProxy(address) {
+			return address
+		}
+
+		host = address
+	}
+
+	return host
+}
+
+// isTrustedProxy tells whether address matches one of TrustedProxies, which are IP addresses or CIDR ranges.
+func (h *Handler) isTrustedProxy(address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, proxy := range h.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+
+			continue
+		}
+
+		if proxyAddr, err := netip.ParseAddr(proxy); err == nil && proxyAddr.Unmap() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
 func getHostURL(r *http.Request) string {
 	scheme := "http"
 	if r.TLS != nil {
@@ -892,10 +1218,9 @@ func (h *Handler) HandleResetPasswordPage() http.Handler {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
 			"Token":          token,
-			"Title":          "Reset Password",
 		}
 
-		h.renderTemplate(w, r, "reset-password-page.gohtml", data)
+		h.renderTemplate(w, r, "reset-password-page.gohtml", &Metadata{Title: "Reset Password", NoIndex: true}, data)
 	})
 
 	return h.GuestOnly(hf)
@@ -932,14 +1257,6 @@ func (h *Handler) HandleResetPassword() http.Handler {
 			return
 		}
 
-		user, err := h.AuthSvc.GetUserByID(r.Context(), resetToken.UserID)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "user not found for reset token", "error", err)
-			http.Error(w, "user not found", http.StatusInternalServerError)
-
-			return
-		}
-
 		newPassword := r.FormValue("newPassword")
 		newPasswordConfirmation := r.FormValue("newPasswordConfirmation")
 
@@ -963,22 +1280,14 @@ func (h *Handler) HandleResetPassword() http.Handler {
 			return
 		}
 
-		user.PasswordHash = string(newPasswordHash)
-		user.UpdatedAt = time.Now()
-
-		err = h.AuthSvc.UpdateUser(r.Context(), user)
+		err = h.AuthSvc.ResetPassword(r.Context(), resetToken, string(newPasswordHash))
 		if err != nil {
-			slog.ErrorContext(r.Context(), "error updating user password", "error", err)
-			http.Error(w, "error updating password", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error resetting password", "error", err)
+			http.Error(w, "error resetting password", http.StatusInternalServerError)
 
 			return
 		}
 
-		err = h.AuthSvc.DeletePasswordResetToken(r.Context(), resetToken.ID)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "error deleting used reset token", "error", err)
-		}
-
 		h.addSuccessMessage(w, r, "Password has been reset successfully.")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
@@ -988,12 +1297,42 @@ func (h *Handler) HandleResetPassword() http.Handler {
 
 func (h *Handler) HandleProfilePage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefs, err := h.NotificationSvc.GetPreferences(r.Context(), userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get notification preferences", "error", err)
+			http.Error(w, "failed to get notification preferences", http.StatusInternalServerError)
+
+			return
+		}
+
+		hasAvatar, err := h.hasAvatar(r, userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get avatar", "error", err)
+			http.Error(w, "failed to get avatar", http.StatusInternalServerError)
+
+			return
+		}
+
+		remoteAvatars, err := h.SettingsSvc.GetBool(r.Context(), settings.KeyRemoteAvatars)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get remote avatars setting", "error", err)
+			http.Error(w, "failed to get remote avatars setting", http.StatusInternalServerError)
+
+			return
+		}
+
 		data := map[string]any{
-			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "Profile",
+			csrf.TemplateTag:          csrf.TemplateField(r),
+			"NotificationPreferences": prefs,
+			"MaxBioLength":            auth.MaxBioLength,
+			"MaxLinks":                auth.MaxLinks,
+			"HasAvatar":               hasAvatar,
+			"RemoteAvatars":           remoteAvatars,
+			"MaxAvatarFileSize":       auth.MaxAvatarFileSize,
+			"MaxAvatarFileSizeMB":     auth.MaxAvatarFileSize >> 20,
 		}
 
-		h.renderTemplate(w, r, "profile-page.gohtml", data)
+		h.renderTemplate(w, r, "profile-page.gohtml", &Metadata{Title: "Profile", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -1013,14 +1352,58 @@ func (h *Handler) HandleProfileUpdate() http.Handler {
 
 		name := r.FormValue("name")
 		emailAddress := r.FormValue("emailAddress")
-		avatarURL := r.FormValue("avatarUrl")
+		bio := r.FormValue("bio")
+		links := auth.ParseLinks(r.FormValue("links"))
 
 		user.Name = name
 		user.EmailAddress = emailAddress
-		user.AvatarURL = avatarURL
+		user.Bio = bio
+		user.Links = links
+
+		remoteAvatars, err := h.SettingsSvc.GetBool(r.Context(), settings.KeyRemoteAvatars)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get remote avatars setting", "error", err)
+			http.Error(w, "failed to get remote avatars setting", http.StatusInternalServerError)
+
+			return
+		}
+
+		// The avatar URL field is only shown while remote avatars are allowed.
+		if remoteAvatars {
+			user.AvatarURL = r.FormValue("avatarUrl")
+		}
 
 		err = h.AuthSvc.UpdateUser(r.Context(), user)
 		if err != nil {
+			formErrors := map[string]any{}
+
+			var invalidLinkErr auth.InvalidLinkError
+
+			switch {
+			case errors.Is(err, auth.ErrBioTooLong):
+				formErrors["Bio"] = fmt.Sprintf("Bio must be at most %d characters", auth.MaxBioLength)
+			case errors.Is(err, auth.ErrTooManyLinks):
+				formErrors["Links"] = fmt.Sprintf("At most %d links are allowed", auth.MaxLinks)
+			case errors.As(err, &invalidLinkErr):
+				formErrors["Links"] = fmt.Sprintf("'%s' is not a valid http or https link", invalidLinkErr.Link)
+			case errors.Is(err, auth.ErrInvalidAvatarURL):
+				formErrors["AvatarURL"] = "Avatar URL must be an http or https link"
+			}
+
+			if len(formErrors) > 0 {
+				h.addErrorMessage(w, r, "Invalid form submission.")
+
+				err := h.addFormErrorsToSession(w, r, "ProfileInfoForm", formErrors)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "error adding form errors to session", "error", err)
+					h.addErrorMessage(w, r, "Error adding form errors.")
+				}
+
+				http.Redirect(w, r, "/profile", http.StatusSeeOther)
+
+				return
+			}
+
 			slog.ErrorContext(r.Context(), "error on update user", "error", err)
 			http.Error(w, "error on update user", http.StatusInternalServerError)
 
@@ -1095,12 +1478,10 @@ func (h *Handler) HandleProfilePasswordUpdate() http.Handler {
 			return
 		}
 
-		user.PasswordHash = string(newPasswordHash)
-
-		err = h.AuthSvc.UpdateUser(r.Context(), user)
+		err = h.AuthSvc.ChangePassword(r.Context(), user, string(newPasswordHash))
 		if err != nil {
-			slog.ErrorContext(r.Context(), "error on update user", "error", err)
-			h.addErrorMessage(w, r, "Error on update user.")
+			slog.ErrorContext(r.Context(), "error on change password", "error", err)
+			h.addErrorMessage(w, r, "Error on change password.")
 			http.Redirect(w, r, "/profile", http.StatusSeeOther)
 
 			return
@@ -1113,71 +1494,286 @@ func (h *Handler) HandleProfilePasswordUpdate() http.Handler {
 	return h.AuthenticatedOnly(hf)
 }
 
-func (h *Handler) HandleViewPostPage() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		postSlug := r.PathValue("postSlug")
+func (h *Handler) HandleProfileDelete() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
 
-		post, err := h.BlogSvc.GetPostBySlug(r.Context(), postSlug)
+			return
+		}
+
+		user := userFromContext(r.Context())
+
+		content := auth.AuthoredContent(r.FormValue("content"))
+
+		formErrors := map[string]any{}
+
+		if !content.IsValid() {
+			formErrors["Content"] = "Choose what happens to your posts and comments"
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("password")))
 		if err != nil {
-			if errors.As(err, &blog.PostBySlugNotFoundError{}) {
-				http.Error(w, "post not found", http.StatusNotFound)
+			formErrors["Password"] = "Password is incorrect"
+		}
 
-				return
+		if len(formErrors) > 0 {
+			h.addErrorMessage(w, r, "Invalid form submission.")
+
+			err := h.addFormErrorsToSession(w, r, "ProfileDeleteForm", formErrors)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error adding form errors to session", "error", err)
+				h.addErrorMessage(w, r, "Error adding form errors.")
 			}
 
-			slog.ErrorContext(r.Context(), "failed to get post by slug", "error", err)
-			http.Error(w, "failed to get post by slug", http.StatusInternalServerError)
+			http.Redirect(w, r, "/profile", http.StatusSeeOther)
 
 			return
 		}
 
-		comments, err := h.BlogSvc.ListComments(r.Context(), blog.ListCommentsParams{PostID: post.ID})
+		err = h.AuthSvc.DeleteUser(r.Context(), user.ID, content)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to list post comments", "error", err)
-			http.Error(w, "failed to list post comments", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error on delete user", "error", err)
+			http.Error(w, "error on delete user", http.StatusInternalServerError)
 
 			return
 		}
 
-		data := map[string]any{
-			csrf.TemplateTag: csrf.TemplateField(r),
-			"Post":           post,
-			"PostComments":   comments,
-			"Title":          post.Title,
-			"Description":    post.Excerpt,
-		}
-
-		h.renderTemplate(w, r, "single-post-page.gohtml", data)
-	})
-}
+		err = h.deleteSessionValue(w, r, "username")
+		if err != nil {
+			slog.ErrorContext(
+				r.Context(),
+				"error on deleting session value",
+				"key",
+				"username",
+				"error",
+				err,
+			)
+			http.Error(w, "error on deleting session value", http.StatusInternalServerError)
 
-func (h *Handler) HandleNewPostPage() http.Handler {
-	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		data := map[string]any{
-			csrf.TemplateTag: csrf.TemplateField(r),
-			"Title":          "New Post",
+			return
 		}
 
-		h.renderTemplate(w, r, "new-post-page.gohtml", data)
+		h.addSuccessMessage(w, r, "Your account has been deleted.")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 
 	return h.AuthenticatedOnly(hf)
 }
 
-func (h *Handler) HandleCreatePost() http.Handler {
+func (h *Handler) HandleProfileExport() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		err := r.ParseForm()
+		user := userFromContext(r.Context())
+
+		err := h.DataExportSvc.RequestExport(r.Context(), user, h.baseURL(r))
 		if err != nil {
-			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
-			http.Error(w, "error on parse form", http.StatusInternalServerError)
+			if errors.Is(err, dataexport.ErrRequestedRecently) {
+				h.addErrorMessage(w, r, "You have requested a data export recently. Please try again later.")
+				http.Redirect(w, r, "/profile", http.StatusSeeOther)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on request data export", "error", err)
+			http.Error(w, "error on request data export", http.StatusInternalServerError)
 
 			return
 		}
 
-		title := r.FormValue("title")
+		h.addSuccessMessage(w, r, "We are preparing your data. A download link will be emailed to you.")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleDownloadDataExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exportID := r.PathValue("exportId")
+		query := r.URL.Query()
+
+		archive, err := h.DataExportSvc.Download(r.Context(), exportID, query.Get("expires"), query.Get("signature"))
+		if err != nil {
+			switch {
+			case errors.Is(err, dataexport.ErrInvalidSignature):
+				http.Error(w, "invalid download link", http.StatusForbidden)
+			case errors.Is(err, dataexport.ErrLinkExpired), errors.As(err, &dataexport.ExportByIDNotFoundError{}):
+				http.Error(w, "download link has expired or has already been used", http.StatusGone)
+			default:
+				slog.ErrorContext(r.Context(), "error on download data export", "error", err, "exportId", exportID)
+				http.Error(w, "error on download data export", http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="personal-data.zip"`)
+		w.Header().Set("Cache-Control", "no-store")
+
+		_, err = w.Write(archive)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to write data export", "error", err)
+		}
+	})
+}
+
+func (h *Handler) HandleViewPostPage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		postSlug := r.PathValue("postSlug")
+
+		post, err := h.BlogSvc.GetPostBySlug(r.Context(), postSlug)
+		if err != nil {
+			if errors.As(err, &blog.PostBySlugNotFoundError{}) {
+				h.redirectFormerPostSlug(w, r, postSlug)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get post by slug", "error", err)
+			http.Error(w, "failed to get post by slug", http.StatusInternalServerError)
+
+			return
+		}
+
+		// Pending comments are only shown to their authors and to administrators.
+		currentUser := userFromContext(r.Context())
+
+		comments, err := h.BlogSvc.ListComments(r.Context(), blog.ListCommentsParams{
+			PostID:         post.ID,
+			IncludePending: currentUser != nil,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list post comments", "error", err)
+			http.Error(w, "failed to list post comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		comments = slices.DeleteFunc(comments, func(comment *blog.Comment) bool {
+			return comment.Status == blog.CommentStatusPending &&
+				(currentUser == nil || (currentUser.ID != comment.UserID && !currentUser.IsAdmin))
+		})
+
+		// Replies name the commenter they reply to, and the comment form replies to the comment in replyTo.
+		commenterNames := make(map[string]string, len(comments))
+
+		var replyTo *blog.Comment
+
+		for _, comment := range comments {
+			commenterNames[comment.ID] = cmp.Or(comment.UserName, comment.UserUsername)
+
+			if comment.ID == r.URL.Query().Get("replyTo") && comment.Status == blog.CommentStatusApproved {
+				replyTo = comment
+			}
+		}
+
+		commentPolicy, err := h.SettingsSvc.GetCommentPolicy(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get comment policy", "error", err)
+			http.Error(w, "failed to get comment policy", http.StatusInternalServerError)
+
+			return
+		}
+
+		author, err := h.AuthSvc.GetUserByID(r.Context(), post.AuthorID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get post author", "error", err)
+			http.Error(w, "failed to get post author", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Post":           post,
+			"Author":         author,
+			"PostComments":   comments,
+			"CommenterNames": commenterNames,
+			"ReplyTo":        replyTo,
+			"DeletedUserID":  auth.DeletedUserID,
+			"CommentsClosed": commentPolicy == settings.CommentPolicyClosed,
+			"ShowTOC":        post.TOC.Len() > blog.MinTOCHeadings,
+		}
+
+		meta := &Metadata{
+			Title:       post.Title,
+			Description: post.Excerpt,
+			Path:        "/posts/" + post.Slug,
+			Article: &ArticleMetadata{
+				AuthorName:  cmp.Or(author.Name, author.Username),
+				PublishedAt: post.CreatedAt,
+				ModifiedAt:  post.UpdatedAt,
+			},
+		}
+
+		if imageURL := blog.FeaturedImageURL(post.Content); imageURL != "" {
+			meta.ImageURL = h.absoluteURL(r, imageURL)
+		}
+
+		h.renderTemplate(w, r, "single-post-page.gohtml", meta, data)
+	})
+}
+
+func (h *Handler) redirectFormerPostSlug(w http.ResponseWriter, r *http.Request, formerSlug string) {
+	post, err := h.BlogSvc.GetPostByFormerSlug(r.Context(), formerSlug)
+	if err != nil {
+		if errors.As(err, &blog.PostSlugHistoryBySlugNotFoundError{}) ||
+			errors.As(err, &blog.PostByIDNotFoundError{}) {
+			http.Error(w, "post not found", http.StatusNotFound)
+
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "failed to get post by former slug", "error", err, "postSlug", formerSlug)
+		http.Error(w, "failed to get post by former slug", http.StatusInternalServerError)
+
+		return
+	}
+
+	target := "/posts/" + post.Slug
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+func (h *Handler) HandleNewPostPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := blog.PostFormat(r.URL.Query().Get("format"))
+		if !format.IsValid() {
+			format = blog.PostFormatHTML
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Format":         format,
+		}
+
+		h.renderTemplate(w, r, "new-post-page.gohtml", &Metadata{Title: "New Post", NoIndex: true}, data)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleCreatePost() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		title := r.FormValue("title")
 		slug := r.FormValue("slug")
 		excerpt := r.FormValue("excerpt")
 		content := r.FormValue("content")
+		format := r.FormValue("format")
 
 		user := userFromContext(r.Context())
 
@@ -1186,11 +1782,18 @@ func (h *Handler) HandleCreatePost() http.Handler {
 			Slug:     slug,
 			Excerpt:  excerpt,
 			Content:  content,
+			Format:   blog.PostFormat(format),
 			AuthorID: user.ID,
 		}
 
 		post, err := h.BlogSvc.CreatePost(r.Context(), req)
 		if err != nil {
+			if errors.Is(err, blog.ErrInvalidPostFormat) {
+				http.Error(w, "invalid post format", http.StatusBadRequest)
+
+				return
+			}
+
 			slog.ErrorContext(r.Context(), "error on create post", "error", err)
 			http.Error(w, "error on create post", http.StatusInternalServerError)
 
@@ -1204,6 +1807,43 @@ func (h *Handler) HandleCreatePost() http.Handler {
 	return h.AuthenticatedOnly(hf)
 }
 
+func (h *Handler) HandlePreviewPost() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		content := r.FormValue("content")
+		format := blog.PostFormat(r.FormValue("format"))
+
+		preview, err := h.BlogSvc.PreviewPostContent(r.Context(), content, format)
+		if err != nil {
+			if errors.Is(err, blog.ErrInvalidPostFormat) {
+				http.Error(w, "invalid post format", http.StatusBadRequest)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on preview post", "error", err)
+			http.Error(w, "error on preview post", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			"Preview": preview,
+		}
+
+		h.renderTemplate(w, r, "preview-post-page.gohtml", &Metadata{Title: "Preview", NoIndex: true}, data)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
 func (h *Handler) HandleEditPostPage() http.Handler {
 	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		postSlug := r.PathValue("postSlug")
@@ -1233,10 +1873,9 @@ func (h *Handler) HandleEditPostPage() http.Handler {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
 			"Post":           post,
-			"Title":          "Edit Post",
 		}
 
-		h.renderTemplate(w, r, "edit-post-page.gohtml", data)
+		h.renderTemplate(w, r, "edit-post-page.gohtml", &Metadata{Title: "Edit Post", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -1286,16 +1925,24 @@ func (h *Handler) HandleEditPost() http.Handler {
 		slug := r.FormValue("slug")
 		excerpt := r.FormValue("excerpt")
 		content := r.FormValue("content")
+		format := r.FormValue("format")
 
 		req := &blog.UpdatePostRequest{
 			Title:   title,
 			Slug:    slug,
 			Excerpt: excerpt,
 			Content: content,
+			Format:  blog.PostFormat(format),
 		}
 
 		post, err = h.BlogSvc.UpdatePost(r.Context(), post.ID, req)
 		if err != nil {
+			if errors.Is(err, blog.ErrInvalidPostFormat) {
+				http.Error(w, "invalid post format", http.StatusBadRequest)
+
+				return
+			}
+
 			slog.ErrorContext(r.Context(), "error on update post", "error", err)
 			http.Error(w, "error on update post", http.StatusInternalServerError)
 
@@ -1344,10 +1991,9 @@ func (h *Handler) HandleDeletePostPage() http.Handler {
 		data := map[string]any{
 			csrf.TemplateTag: csrf.TemplateField(r),
 			"Post":           post,
-			"Title":          "Delete Post",
 		}
 
-		h.renderTemplate(w, r, "delete-post-page.gohtml", data)
+		h.renderTemplate(w, r, "delete-post-page.gohtml", &Metadata{Title: "Delete Post", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -1393,7 +2039,7 @@ func (h *Handler) HandleDeletePost() http.Handler {
 			return
 		}
 
-		h.addSuccessMessage(w, r, "Post has been deleted successfully.")
+		h.addSuccessMessage(w, r, "Post has been moved to trash.")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 
@@ -1431,20 +2077,38 @@ func (h *Handler) HandleSubmitComment() http.Handler {
 		}
 
 		req := &blog.CreateCommentRequest{
-			PostID:  postID,
-			UserID:  user.ID,
-			Content: content,
+			PostID:   postID,
+			ParentID: r.FormValue("parentId"),
+			UserID:   user.ID,
+			Content:  content,
 		}
 
-		_, err = h.BlogSvc.CreateComment(r.Context(), req)
+		comment, err := h.BlogSvc.CreateComment(r.Context(), req)
 		if err != nil {
+			if errors.Is(err, blog.ErrCommentsClosed) {
+				http.Error(w, "comments are closed", http.StatusForbidden)
+
+				return
+			}
+
+			if errors.Is(err, blog.ErrInvalidParentComment) {
+				http.Error(w, "the comment to reply to is not available", http.StatusBadRequest)
+
+				return
+			}
+
 			slog.ErrorContext(r.Context(), "error on create comment", "error", err)
 			http.Error(w, "error on create comment", http.StatusInternalServerError)
 
 			return
 		}
 
-		h.addSuccessMessage(w, r, "Comment has been created successfully.")
+		if comment.Status == blog.CommentStatusPending {
+			h.addSuccessMessage(w, r, "Comment has been submitted and is awaiting moderation.")
+		} else {
+			h.addSuccessMessage(w, r, "Comment has been created successfully.")
+		}
+
 		http.Redirect(w, r, "/posts/"+post.Slug, http.StatusSeeOther)
 	})
 
@@ -1497,10 +2161,9 @@ func (h *Handler) HandleEditCommentPage() http.Handler {
 			csrf.TemplateTag: csrf.TemplateField(r),
 			"Comment":        comment,
 			"Post":           post,
-			"Title":          "Edit Comment",
 		}
 
-		h.renderTemplate(w, r, "edit-comment-page.gohtml", data)
+		h.renderTemplate(w, r, "edit-comment-page.gohtml", &Metadata{Title: "Edit Comment", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -1624,10 +2287,9 @@ func (h *Handler) HandleDeleteCommentPage() http.Handler {
 			csrf.TemplateTag: csrf.TemplateField(r),
 			"Comment":        comment,
 			"Post":           post,
-			"Title":          "Delete Comment",
 		}
 
-		h.renderTemplate(w, r, "delete-comment-page.gohtml", data)
+		h.renderTemplate(w, r, "delete-comment-page.gohtml", &Metadata{Title: "Delete Comment", NoIndex: true}, data)
 	})
 
 	return h.AuthenticatedOnly(hf)
@@ -1660,7 +2322,7 @@ func (h *Handler) HandleDeleteComment() http.Handler {
 			return
 		}
 
-		err = h.BlogSvc.DeleteComment(r.Context(), commentID)
+		err = h.BlogSvc.DeleteComment(r.Context(), commentID, user.ID)
 		if err != nil {
 			slog.ErrorContext(r.Context(), "error on delete comment", "error", err)
 			http.Error(w, "error on delete comment", http.StatusInternalServerError)
@@ -1668,7 +2330,7 @@ func (h *Handler) HandleDeleteComment() http.Handler {
 			return
 		}
 
-		h.addSuccessMessage(w, r, "Comment has been deleted successfully.")
+		h.addSuccessMessage(w, r, "Comment has been moved to trash.")
 
 		post, err := h.BlogSvc.GetPostByID(r.Context(), comment.PostID)
 		if err != nil {
@@ -1690,3 +2352,533 @@ func (h *Handler) HandleDeleteComment() http.Handler {
 
 	return h.AuthenticatedOnly(hf)
 }
+
+func (h *Handler) HandleApproveComment() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID := r.PathValue("commentId")
+
+		comment, err := h.BlogSvc.GetCommentByID(r.Context(), commentID)
+		if err != nil {
+			if errors.As(err, &blog.CommentByIDNotFoundError{}) {
+				http.Error(w, "comment not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "error on get comment by id", "error", err, "commentId", commentID)
+			http.Error(w, "error on get comment by id", http.StatusInternalServerError)
+
+			return
+		}
+
+		user := userFromContext(r.Context())
+
+		err = h.BlogSvc.ApproveComment(r.Context(), comment.ID, user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on approve comment", "error", err)
+			http.Error(w, "error on approve comment", http.StatusInternalServerError)
+
+			return
+		}
+
+		post, err := h.BlogSvc.GetPostByID(r.Context(), comment.PostID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on get post by id", "error", err, "postId", comment.PostID)
+			http.Error(w, "error on get post by id", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Comment has been approved.")
+		http.Redirect(w, r, "/posts/"+post.Slug, http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+// trashedComment is a comment in trash with its content as plain text.
+type trashedComment struct {
+	*blog.Comment
+	Text string
+}
+
+func (h *Handler) HandleTrashPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+
+		posts, err := h.BlogSvc.ListPosts(r.Context(), blog.ListPostsParams{AuthorID: user.ID, Trashed: true})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list trashed posts", "error", err)
+			http.Error(w, "failed to list trashed posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		comments, err := h.BlogSvc.ListComments(r.Context(), blog.ListCommentsParams{
+			UserID:         user.ID,
+			IncludePending: true,
+			Trashed:        true,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list trashed comments", "error", err)
+			http.Error(w, "failed to list trashed comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		trashedComments := make([]trashedComment, 0, len(comments))
+
+		for _, comment := range comments {
+			trashedComments = append(trashedComments, trashedComment{
+				Comment: comment,
+				Text:    h.BlogSvc.PlainText(comment.Content),
+			})
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag:     csrf.TemplateField(r),
+			"TrashedPosts":       posts,
+			"TrashedComments":    trashedComments,
+			"TrashRetentionDays": int(h.BlogSvc.TrashRetention.Hours() / 24),
+		}
+
+		h.renderTemplate(w, r, "trash-page.gohtml", &Metadata{Title: "Trash", NoIndex: true}, data)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) trashedPostOfCurrentUser(w http.ResponseWriter, r *http.Request) (*blog.Post, bool) {
+	postID := r.PathValue("postId")
+
+	post, err := h.BlogSvc.GetTrashedPostByID(r.Context(), postID)
+	if err != nil {
+		if errors.As(err, &blog.PostByIDNotFoundError{}) {
+			http.Error(w, "post not found", http.StatusNotFound)
+
+			return nil, false
+		}
+
+		slog.ErrorContext(r.Context(), "error on get trashed post by id", "error", err, "postId", postID)
+		http.Error(w, "error on get trashed post by id", http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	user := userFromContext(r.Context())
+	if post.AuthorID != user.ID {
+		http.Error(w, "cannot manage post", http.StatusForbidden)
+
+		return nil, false
+	}
+
+	return post, true
+}
+
+func (h *Handler) HandleRestorePost() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post, ok := h.trashedPostOfCurrentUser(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.BlogSvc.RestorePost(r.Context(), post.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on restore post", "error", err)
+			http.Error(w, "error on restore post", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Post has been restored successfully.")
+		http.Redirect(w, r, "/posts/"+post.Slug, http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandlePurgePost() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post, ok := h.trashedPostOfCurrentUser(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.BlogSvc.PurgePost(r.Context(), post.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on purge post", "error", err)
+			http.Error(w, "error on purge post", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Post has been deleted permanently.")
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) trashedCommentOfCurrentUser(w http.ResponseWriter, r *http.Request) (*blog.Comment, bool) {
+	commentID := r.PathValue("commentId")
+
+	comment, err := h.BlogSvc.GetTrashedCommentByID(r.Context(), commentID)
+	if err != nil {
+		if errors.As(err, &blog.CommentByIDNotFoundError{}) {
+			http.Error(w, "comment not found", http.StatusNotFound)
+
+			return nil, false
+		}
+
+		slog.ErrorContext(r.Context(), "error on get trashed comment by id", "error", err, "commentId", commentID)
+		http.Error(w, "error on get trashed comment by id", http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	user := userFromContext(r.Context())
+	if comment.UserID != user.ID {
+		http.Error(w, "cannot manage comment", http.StatusForbidden)
+
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func (h *Handler) HandleRestoreComment() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comment, ok := h.trashedCommentOfCurrentUser(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.BlogSvc.RestoreComment(r.Context(), comment.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on restore comment", "error", err)
+			http.Error(w, "error on restore comment", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Comment has been restored successfully.")
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandlePurgeComment() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comment, ok := h.trashedCommentOfCurrentUser(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.BlogSvc.PurgeComment(r.Context(), comment.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on purge comment", "error", err)
+			http.Error(w, "error on purge comment", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Comment has been deleted permanently.")
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleRobotsTxt(w http.ResponseWriter, r *http.Request) {
+	var sb strings.Builder
+
+	sb.WriteString("User-agent: *\n")
+
+	if len(h.RobotsDisallow) == 0 {
+		sb.WriteString("Disallow:\n")
+	}
+
+	for _, path := range h.RobotsDisallow {
+		sb.WriteString("Disallow: " + strings.TrimSpace(path) + "\n")
+	}
+
+	sb.WriteString("\nSitemap: " + h.absoluteURL(r, "/sitemap.xml") + "\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	_, err := w.Write([]byte(sb.String()))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write robots.txt", "error", err)
+	}
+}
+
+// SitemapMaxURLs is the most URLs a single sitemap may list.
+// Larger sites are served as a sitemap index pointing at numbered sitemaps.
+const SitemapMaxURLs = 50000
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// HandleSitemap lists the home page and every post. Once there are more than SitemapMaxURLs of them,
+// /sitemap.xml becomes an index and the URLs are listed by /sitemap.xml?page=N.
+func (h *Handler) HandleSitemap(w http.ResponseWriter, r *http.Request) {
+	totalPosts, err := h.BlogSvc.CountPosts(r.Context(), blog.ListPostsParams{})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to count posts", "error", err)
+		http.Error(w, "failed to count posts", http.StatusInternalServerError)
+
+		return
+	}
+
+	// The home page comes first, followed by the posts.
+	totalURLs := totalPosts + 1
+	totalPages := (totalURLs + SitemapMaxURLs - 1) / SitemapMaxURLs
+
+	page := r.URL.Query().Get("page")
+
+	if page == "" && totalPages > 1 {
+		index := sitemapIndex{}
+
+		for pageNum := 1; pageNum <= totalPages; pageNum++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{
+				Loc: h.absoluteURL(r, "/sitemap.xml?page="+strconv.Itoa(pageNum)),
+			})
+		}
+
+		h.writeXML(w, r, index)
+
+		return
+	}
+
+	pageNum := 1
+
+	if page != "" {
+		pageNum, err = strconv.Atoi(page)
+		if err != nil || pageNum < 1 || pageNum > totalPages {
+			http.Error(w, "sitemap not found", http.StatusNotFound)
+
+			return
+		}
+	}
+
+	start := (pageNum - 1) * SitemapMaxURLs
+	end := min(start+SitemapMaxURLs, totalURLs)
+
+	urlSet := sitemapURLSet{}
+
+	if start == 0 {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: h.absoluteURL(r, "/")})
+		start = 1
+	}
+
+	if end > start {
+		posts, err := h.BlogSvc.ListPosts(r.Context(), blog.ListPostsParams{Limit: end - start, Offset: start - 1})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list posts", "error", err)
+			http.Error(w, "failed to list posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		for _, post := range posts {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{
+				Loc:     h.absoluteURL(r, "/posts/"+post.Slug),
+				LastMod: post.UpdatedAt.Format(time.RFC3339),
+			})
+		}
+	}
+
+	h.writeXML(w, r, urlSet)
+}
+
+func (h *Handler) writeXML(w http.ResponseWriter, r *http.Request, v any) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to marshal xml", "error", err)
+		http.Error(w, "failed to marshal xml", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+	_, err = w.Write(append([]byte(xml.Header), out...))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write xml", "error", err)
+	}
+}
+
+func (h *Handler) HandleAdminSettingsPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site, err := h.siteSettings(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get site settings", "error", err)
+			http.Error(w, "failed to get site settings", http.StatusInternalServerError)
+
+			return
+		}
+
+		postsPerPage, err := h.SettingsSvc.GetInt(r.Context(), settings.KeyPostsPerPage)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get posts per page", "error", err)
+			http.Error(w, "failed to get posts per page", http.StatusInternalServerError)
+
+			return
+		}
+
+		excerptLength, err := h.SettingsSvc.GetInt(r.Context(), settings.KeyExcerptLength)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get excerpt length", "error", err)
+			http.Error(w, "failed to get excerpt length", http.StatusInternalServerError)
+
+			return
+		}
+
+		commentPolicy, err := h.SettingsSvc.GetCommentPolicy(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get comment policy", "error", err)
+			http.Error(w, "failed to get comment policy", http.StatusInternalServerError)
+
+			return
+		}
+
+		avatarFallback, err := h.SettingsSvc.GetAvatarFallback(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get avatar fallback", "error", err)
+			http.Error(w, "failed to get avatar fallback", http.StatusInternalServerError)
+
+			return
+		}
+
+		remoteAvatars, err := h.SettingsSvc.GetBool(r.Context(), settings.KeyRemoteAvatars)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get remote avatars setting", "error", err)
+			http.Error(w, "failed to get remote avatars setting", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Settings": settings.UpdateSettingsRequest{
+				SiteTitle:        site.Title,
+				SiteTagline:      site.Tagline,
+				Language:         site.Language,
+				PostsPerPage:     postsPerPage,
+				ExcerptLength:    excerptLength,
+				RegistrationOpen: site.RegistrationOpen,
+				CommentPolicy:    commentPolicy,
+				AvatarFallback:   avatarFallback,
+				RemoteAvatars:    remoteAvatars,
+			},
+			"CommentPolicies": []settings.CommentPolicy{
+				settings.CommentPolicyOpen,
+				settings.CommentPolicyModerated,
+				settings.CommentPolicyClosed,
+			},
+			"AvatarFallbacks": []settings.AvatarFallback{
+				settings.AvatarFallbackInitials,
+				settings.AvatarFallbackGravatar,
+			},
+		}
+
+		h.renderTemplate(w, r, "admin-settings-page.gohtml", &Metadata{Title: "Settings", NoIndex: true}, data)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+func (h *Handler) HandleAdminSettingsUpdate() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		formErrors := map[string]any{}
+
+		postsPerPage, err := strconv.Atoi(r.FormValue("postsPerPage"))
+		if err != nil {
+			formErrors["PostsPerPage"] = "Posts per page must be a number"
+		}
+
+		excerptLength, err := strconv.Atoi(r.FormValue("excerptLength"))
+		if err != nil {
+			formErrors["ExcerptLength"] = "Excerpt length must be a number"
+		}
+
+		if len(formErrors) == 0 {
+			err = h.SettingsSvc.UpdateSettings(r.Context(), &settings.UpdateSettingsRequest{
+				SiteTitle:        r.FormValue("siteTitle"),
+				SiteTagline:      r.FormValue("siteTagline"),
+				Language:         r.FormValue("language"),
+				PostsPerPage:     postsPerPage,
+				ExcerptLength:    excerptLength,
+				RegistrationOpen: r.FormValue("registrationOpen") == "on",
+				CommentPolicy:    settings.CommentPolicy(r.FormValue("commentPolicy")),
+				AvatarFallback:   settings.AvatarFallback(r.FormValue("avatarFallback")),
+				RemoteAvatars:    r.FormValue("remoteAvatars") == "on",
+			})
+			if err != nil {
+				var invalidErr settings.InvalidSettingValueError
+				if !errors.As(err, &invalidErr) {
+					slog.ErrorContext(r.Context(), "error on update settings", "error", err)
+					http.Error(w, "error on update settings", http.StatusInternalServerError)
+
+					return
+				}
+
+				field := settingFormFields[invalidErr.Key]
+				formErrors[field.Name] = field.Label + " " + invalidErr.Reason
+			}
+		}
+
+		if len(formErrors) > 0 {
+			h.addErrorMessage(w, r, "Invalid form submission.")
+
+			err := h.addFormErrorsToSession(w, r, "SettingsForm", formErrors)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error adding form errors to session", "error", err)
+				h.addErrorMessage(w, r, "Error adding form errors.")
+			}
+
+			http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Settings have been updated successfully.")
+		http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+	})
+
+	return h.AdminOnly(hf)
+}
+
+// settingFormFields maps setting keys to their fields in the settings form.
+var settingFormFields = map[string]struct{ Name, Label string }{
+	settings.KeySiteTitle:        {Name: "SiteTitle", Label: "Site title"},
+	settings.KeySiteTagline:      {Name: "SiteTagline", Label: "Tagline"},
+	settings.KeyLanguage:         {Name: "Language", Label: "Language"},
+	settings.KeyPostsPerPage:     {Name: "PostsPerPage", Label: "Posts per page"},
+	settings.KeyExcerptLength:    {Name: "ExcerptLength", Label: "Excerpt length"},
+	settings.KeyRegistrationOpen: {Name: "RegistrationOpen", Label: "Registration"},
+	settings.KeyCommentPolicy:    {Name: "CommentPolicy", Label: "Comment policy"},
+	settings.KeyAvatarFallback:   {Name: "AvatarFallback", Label: "Default avatar"},
+	settings.KeyRemoteAvatars:    {Name: "RemoteAvatars", Label: "Remote avatars"},
+}
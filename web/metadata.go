@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// Metadata describes a page to browsers, search engines and link previews.
+// Handlers fill it in for every rendered page and page-header.gohtml turns it into tags.
+type Metadata struct {
+	// SiteName is the title of the site, set by renderTemplate.
+	SiteName    string
+	Title       string
+	Description string
+	// Path is the canonical path of the page. It defaults to the request path.
+	Path string
+	// CanonicalURL is the absolute form of Path, set by renderTemplate.
+	CanonicalURL string
+	// ImageURL is an absolute URL of the image shown in link previews.
+	ImageURL string
+	NoIndex  bool
+	Article  *ArticleMetadata
+}
+
+// ArticleMetadata is set on pages of a single post.
+type ArticleMetadata struct {
+	AuthorName  string
+	PublishedAt time.Time
+	ModifiedAt  time.Time
+}
+
+// FullTitle is the title shown in the browser, suffixed with the site name.
+func (m *Metadata) FullTitle() string {
+	if m.Title == "" {
+		return m.SiteName
+	}
+
+	return m.Title + " | " + m.SiteName
+}
+
+// OpenGraphType is "article" for posts and "website" for everything else.
+func (m *Metadata) OpenGraphType() string {
+	if m.Article != nil {
+		return "article"
+	}
+
+	return "website"
+}
+
+// TwitterCard uses the large image card when there is an image to show.
+func (m *Metadata) TwitterCard() string {
+	if m.ImageURL != "" {
+		return "summary_large_image"
+	}
+
+	return "summary"
+}
+
+// JSONLD returns the schema.org BlogPosting of an article page, or nothing for other pages.
+// json.Marshal escapes <, > and &, so the result is safe inside a script element.
+func (m *Metadata) JSONLD() (template.JS, error) {
+	if m.Article == nil {
+		return "", nil
+	}
+
+	type person struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+	}
+
+	posting := struct {
+		Context          string   `json:"@context"`
+		Type             string   `json:"@type"`
+		Headline         string   `json:"headline"`
+		Description      string   `json:"description,omitempty"`
+		URL              string   `json:"url"`
+		MainEntityOfPage string   `json:"mainEntityOfPage"`
+		Image            []string `json:"image,omitempty"`
+		Author           person   `json:"author"`
+		DatePublished    string   `json:"datePublished"`
+		DateModified     string   `json:"dateModified"`
+	}{
+		Context:          "https://schema.org",
+		Type:             "BlogPosting",
+		Headline:         m.Title,
+		Description:      m.Description,
+		URL:              m.CanonicalURL,
+		MainEntityOfPage: m.CanonicalURL,
+		Author:           person{Type: "Person", Name: m.Article.AuthorName},
+		DatePublished:    m.Article.PublishedAt.Format(time.RFC3339),
+		DateModified:     m.Article.ModifiedAt.Format(time.RFC3339),
+	}
+
+	if m.ImageURL != "" {
+		posting.Image = []string{m.ImageURL}
+	}
+
+	b, err := json.Marshal(posting)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json-ld: %w", err)
+	}
+
+	return template.JS(b), nil //nolint:gosec
+}
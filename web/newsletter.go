@@ -0,0 +1,241 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/newsletter"
+)
+
+func (h *Handler) HandleNewsletterPage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"Frequencies":    newsletter.Frequencies,
+		}
+
+		currentUser := userFromContext(r.Context())
+		if currentUser != nil {
+			sub, err := h.NewsletterSvc.GetSubscriptionByUserID(r.Context(), currentUser.ID)
+			if err != nil && !errors.As(err, &newsletter.SubscriptionByUserIDNotFoundError{}) {
+				slog.ErrorContext(r.Context(), "failed to get newsletter subscription", "error", err)
+				http.Error(w, "failed to get newsletter subscription", http.StatusInternalServerError)
+
+				return
+			}
+
+			data["Subscription"] = sub
+		}
+
+		h.renderTemplate(w, r, "newsletter-page.gohtml", &Metadata{Title: "Newsletter"}, data)
+	})
+}
+
+func (h *Handler) HandleNewsletterSubscribe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		req := &newsletter.CreateSubscriptionRequest{
+			EmailAddress: r.FormValue("emailAddress"),
+			Frequency:    newsletter.Frequency(r.FormValue("frequency")),
+		}
+
+		currentUser := userFromContext(r.Context())
+		if currentUser != nil {
+			req.UserID = currentUser.ID
+		}
+
+		err = h.NewsletterSvc.CreateSubscription(r.Context(), req, h.baseURL(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, newsletter.ErrInvalidEmailAddress):
+				h.addErrorMessage(w, r, "Please enter a valid email address.")
+				http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+
+				return
+			case errors.Is(err, newsletter.ErrInvalidFrequency):
+				h.addErrorMessage(w, r, "Please choose a valid frequency.")
+				http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to create newsletter subscription", "error", err)
+			http.Error(w, "failed to create newsletter subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		// The same message whether or not the address was already subscribed.
+		h.addSuccessMessage(w, r, "Check your inbox for a link to confirm your subscription.")
+		http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+	})
+}
+
+func (h *Handler) HandleNewsletterConfirm() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.NewsletterSvc.Confirm(r.Context(), r.URL.Query().Get("id"), r.URL.Query().Get("signature"))
+		if err != nil {
+			if errors.Is(err, newsletter.ErrInvalidSignature) {
+				http.Error(w, "invalid confirmation link", http.StatusForbidden)
+
+				return
+			}
+
+			if errors.As(err, &newsletter.SubscriptionByIDNotFoundError{}) {
+				h.addErrorMessage(w, r, "This confirmation link has expired. Please subscribe again.")
+				http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to confirm newsletter subscription", "error", err)
+			http.Error(w, "failed to confirm newsletter subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Your subscription has been confirmed.")
+		http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+	})
+}
+
+func (h *Handler) HandleNewsletterUnsubscribePage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"ID":             r.URL.Query().Get("id"),
+			"Signature":      r.URL.Query().Get("signature"),
+		}
+
+		h.renderTemplate(
+			w,
+			r,
+			"newsletter-unsubscribe-page.gohtml",
+			&Metadata{Title: "Unsubscribe", NoIndex: true},
+			data,
+		)
+	})
+}
+
+func (h *Handler) HandleNewsletterUnsubscribe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The id and signature are in the query of one-click requests and in the form of the unsubscribe page.
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		err = h.NewsletterSvc.Unsubscribe(r.Context(), r.FormValue("id"), r.FormValue("signature"))
+		if err != nil {
+			if errors.Is(err, newsletter.ErrInvalidSignature) {
+				http.Error(w, "invalid unsubscribe link", http.StatusForbidden)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to unsubscribe from newsletter", "error", err)
+			http.Error(w, "failed to unsubscribe from newsletter", http.StatusInternalServerError)
+
+			return
+		}
+
+		if r.PostForm.Get("List-Unsubscribe") == "One-Click" {
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "You have been unsubscribed.")
+		http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+	})
+}
+
+func (h *Handler) HandleNewsletterFrequencyUpdate() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		sub, ok := h.currentSubscription(w, r)
+		if !ok {
+			return
+		}
+
+		err = h.NewsletterSvc.UpdateFrequency(r.Context(), sub.ID, newsletter.Frequency(r.FormValue("frequency")))
+		if err != nil {
+			if errors.Is(err, newsletter.ErrInvalidFrequency) {
+				h.addErrorMessage(w, r, "Invalid frequency.")
+				http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to update newsletter frequency", "error", err)
+			http.Error(w, "failed to update newsletter frequency", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Your newsletter frequency has been updated.")
+		http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleNewsletterCancel() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := h.currentSubscription(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.NewsletterSvc.DeleteSubscription(r.Context(), sub.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete newsletter subscription", "error", err)
+			http.Error(w, "failed to delete newsletter subscription", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "You have been unsubscribed.")
+		http.Redirect(w, r, "/newsletter", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// currentSubscription gets the subscription of the current user and writes the error response if there is none.
+func (h *Handler) currentSubscription(w http.ResponseWriter, r *http.Request) (*newsletter.Subscription, bool) {
+	sub, err := h.NewsletterSvc.GetSubscriptionByUserID(r.Context(), userFromContext(r.Context()).ID)
+	if err != nil {
+		if errors.As(err, &newsletter.SubscriptionByUserIDNotFoundError{}) {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+
+			return nil, false
+		}
+
+		slog.ErrorContext(r.Context(), "failed to get newsletter subscription", "error", err)
+		http.Error(w, "failed to get newsletter subscription", http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	return sub, true
+}
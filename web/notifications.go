@@ -0,0 +1,259 @@
+package web
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/csrf"
+	"github.com/nasermirzaei89/fullstackgo/notification"
+)
+
+func (h *Handler) HandleProfileNotificationsUpdate() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		prefs, err := h.NotificationSvc.GetPreferences(r.Context(), userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get notification preferences", "error", err)
+			http.Error(w, "failed to get notification preferences", http.StatusInternalServerError)
+
+			return
+		}
+
+		// Unchecked checkboxes are not submitted.
+		prefs.EmailComments = r.PostForm.Has("emailComments")
+		prefs.EmailReplies = r.PostForm.Has("emailReplies")
+		prefs.EmailMentions = r.PostForm.Has("emailMentions")
+
+		err = h.NotificationSvc.UpdatePreferences(r.Context(), prefs)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to update notification preferences", "error", err)
+			http.Error(w, "failed to update notification preferences", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "Notification preferences updated successfully.")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleNotificationsUnsubscribePage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			"UserID":         r.URL.Query().Get("user"),
+			"Type":           r.URL.Query().Get("type"),
+			"Signature":      r.URL.Query().Get("signature"),
+		}
+
+		h.renderTemplate(
+			w,
+			r,
+			"notifications-unsubscribe-page.gohtml",
+			&Metadata{Title: "Unsubscribe", NoIndex: true},
+			data,
+		)
+	})
+}
+
+func (h *Handler) HandleNotificationsUnsubscribe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The parameters are in the query of one-click requests and in the form of the unsubscribe page.
+		err := r.ParseForm()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error on parse form", "error", err)
+			http.Error(w, "error on parse form", http.StatusInternalServerError)
+
+			return
+		}
+
+		err = h.NotificationSvc.Unsubscribe(
+			r.Context(),
+			r.FormValue("user"),
+			notification.Type(r.FormValue("type")),
+			r.FormValue("signature"),
+		)
+		if err != nil {
+			if errors.Is(err, notification.ErrInvalidSignature) || errors.Is(err, notification.ErrInvalidType) {
+				http.Error(w, "invalid unsubscribe link", http.StatusForbidden)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to unsubscribe from notifications", "error", err)
+			http.Error(w, "failed to unsubscribe from notifications", http.StatusInternalServerError)
+
+			return
+		}
+
+		if r.PostForm.Get("List-Unsubscribe") == "One-Click" {
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "You will no longer get these emails.")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+}
+
+// NotificationsPageSize is the number of notifications in each page of the notification center.
+const NotificationsPageSize = 20
+
+func (h *Handler) HandleNotificationsPage() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+
+		pageNum := 1
+
+		page := r.URL.Query().Get("page")
+		if page != "" {
+			var err error
+
+			pageNum, err = strconv.Atoi(page)
+			if err != nil || pageNum < 1 {
+				http.Error(w, "invalid page number", http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		notifications, err := h.NotificationSvc.ListNotifications(r.Context(), notification.ListNotificationsParams{
+			UserID: user.ID,
+			Limit:  NotificationsPageSize,
+			Offset: (pageNum - 1) * NotificationsPageSize,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list notifications", "error", err)
+			http.Error(w, "failed to list notifications", http.StatusInternalServerError)
+
+			return
+		}
+
+		count, err := h.NotificationSvc.CountNotifications(
+			r.Context(),
+			notification.ListNotificationsParams{UserID: user.ID},
+		)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count notifications", "error", err)
+			http.Error(w, "failed to count notifications", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			csrf.TemplateTag: csrf.TemplateField(r),
+			// Notifications is taken by the flash messages.
+			"NotificationList": notifications,
+			"CurrentPage":      pageNum,
+			"TotalPages":       (count + NotificationsPageSize - 1) / NotificationsPageSize,
+		}
+
+		h.renderTemplate(w, r, "notifications-page.gohtml", &Metadata{Title: "Notifications", NoIndex: true}, data)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// HandleNotificationRead marks a notification as read and redirects to what it is about.
+func (h *Handler) HandleNotificationRead() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+
+		n, err := h.NotificationSvc.GetNotification(r.Context(), user.ID, r.PathValue("notificationId"))
+		if err != nil {
+			if errors.As(err, &notification.NotificationByIDNotFoundError{}) {
+				http.Error(w, "notification not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get notification", "error", err)
+			http.Error(w, "failed to get notification", http.StatusInternalServerError)
+
+			return
+		}
+
+		err = h.NotificationSvc.MarkRead(r.Context(), user.ID, n.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to mark notification as read", "error", err)
+			http.Error(w, "failed to mark notification as read", http.StatusInternalServerError)
+
+			return
+		}
+
+		http.Redirect(w, r, cmp.Or(n.URL, "/notifications"), http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+func (h *Handler) HandleNotificationsReadAll() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.NotificationSvc.MarkAllRead(r.Context(), userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to mark notifications as read", "error", err)
+			http.Error(w, "failed to mark notifications as read", http.StatusInternalServerError)
+
+			return
+		}
+
+		h.addSuccessMessage(w, r, "All notifications marked as read.")
+		http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// HandleNotificationsUnreadCount responds with the unread count the bell in the header polls.
+func (h *Handler) HandleNotificationsUnreadCount() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, err := h.NotificationSvc.CountUnread(r.Context(), userFromContext(r.Context()).ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count unread notifications", "error", err)
+			http.Error(w, "failed to count unread notifications", http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		err = json.NewEncoder(w).Encode(map[string]int{"count": count})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to encode unread count", "error", err)
+		}
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// unreadNotificationCount returns the unread count of the current user for the bell in the header.
+func (h *Handler) unreadNotificationCount(r *http.Request) int {
+	user := userFromContext(r.Context())
+	if user == nil {
+		return 0
+	}
+
+	count, err := h.NotificationSvc.CountUnread(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to count unread notifications", "error", err)
+
+		return 0
+	}
+
+	return count
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+// Site holds the settings every page is rendered with.
+type Site struct {
+	Title            string
+	Tagline          string
+	Language         string
+	RegistrationOpen bool
+}
+
+func (h *Handler) siteSettings(ctx context.Context) (*Site, error) {
+	var (
+		site Site
+		err  error
+	)
+
+	site.Title, err = h.SettingsSvc.GetString(ctx, settings.KeySiteTitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site title: %w", err)
+	}
+
+	site.Tagline, err = h.SettingsSvc.GetString(ctx, settings.KeySiteTagline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site tagline: %w", err)
+	}
+
+	site.Language, err = h.SettingsSvc.GetString(ctx, settings.KeyLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site language: %w", err)
+	}
+
+	site.RegistrationOpen, err = h.SettingsSvc.GetBool(ctx, settings.KeyRegistrationOpen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration setting: %w", err)
+	}
+
+	return &site, nil
+}
+
+var rtlLanguages = []string{"ar", "dv", "fa", "he", "ku", "ps", "sd", "ug", "ur", "yi"}
+
+// languageDirection returns the text direction of a language tag, "rtl" or "ltr".
+func languageDirection(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+
+	if slices.Contains(rtlLanguages, base) {
+		return "rtl"
+	}
+
+	return "ltr"
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/nasermirzaei89/fullstackgo/auth"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/settings"
+)
+
+// UsernameSuggestionLimit is the number of usernames suggested while typing a mention.
+const UsernameSuggestionLimit = 8
+
+// ProfileRecentCommentsLimit is the number of recent comments shown on the public profile of a user.
+const ProfileRecentCommentsLimit = 5
+
+// HandleUserAutocomplete responds with the usernames that start with the q query parameter, for completing mentions.
+func (h *Handler) HandleUserAutocomplete() http.Handler {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usernames := []string{}
+
+		if prefix := r.URL.Query().Get("q"); prefix != "" {
+			var err error
+
+			usernames, err = h.AuthSvc.SuggestUsernames(r.Context(), prefix, UsernameSuggestionLimit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to suggest usernames", "error", err)
+				http.Error(w, "failed to suggest usernames", http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		err := json.NewEncoder(w).Encode(usernames)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to encode usernames", "error", err)
+		}
+	})
+
+	return h.AuthenticatedOnly(hf)
+}
+
+// profileComment is a comment shown on a public profile along with the post it was left on.
+type profileComment struct {
+	Comment *blog.Comment
+	Post    *blog.Post
+}
+
+// HandleUserProfilePage shows the public profile of a user with their posts and recent comments.
+func (h *Handler) HandleUserProfilePage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := h.AuthSvc.GetUserByUsername(r.Context(), r.PathValue("username"))
+		if err != nil {
+			if errors.As(err, &auth.UserByUsernameNotFoundError{}) {
+				http.Error(w, "user not found", http.StatusNotFound)
+
+				return
+			}
+
+			slog.ErrorContext(r.Context(), "failed to get user by username", "error", err)
+			http.Error(w, "failed to get user by username", http.StatusInternalServerError)
+
+			return
+		}
+
+		if !user.HasPublicProfile() {
+			http.Error(w, "user not found", http.StatusNotFound)
+
+			return
+		}
+
+		postsPerPage, err := h.SettingsSvc.GetInt(r.Context(), settings.KeyPostsPerPage)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get posts per page", "error", err)
+			http.Error(w, "failed to get posts per page", http.StatusInternalServerError)
+
+			return
+		}
+
+		pageNum := 1
+
+		page := r.URL.Query().Get("page")
+		if page != "" {
+			pageNum, err = strconv.Atoi(page)
+			if err != nil || pageNum < 1 {
+				http.Error(w, "invalid page number", http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		posts, err := h.BlogSvc.ListPosts(r.Context(), blog.ListPostsParams{
+			AuthorID: user.ID,
+			Limit:    postsPerPage,
+			Offset:   (pageNum - 1) * postsPerPage,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list user posts", "error", err)
+			http.Error(w, "failed to list user posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		totalPosts, err := h.BlogSvc.CountPosts(r.Context(), blog.ListPostsParams{AuthorID: user.ID})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to count user posts", "error", err)
+			http.Error(w, "failed to count user posts", http.StatusInternalServerError)
+
+			return
+		}
+
+		comments, err := h.recentProfileComments(r, user)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list user comments", "error", err)
+			http.Error(w, "failed to list user comments", http.StatusInternalServerError)
+
+			return
+		}
+
+		data := map[string]any{
+			"User":           user,
+			"Posts":          posts,
+			"RecentComments": comments,
+			"CurrentPage":    pageNum,
+			"TotalPages":     (totalPosts + postsPerPage - 1) / postsPerPage,
+		}
+
+		meta := &Metadata{
+			Title:       cmp.Or(user.Name, user.Username),
+			Description: user.Bio,
+			Path:        "/users/" + user.Username,
+		}
+		if pageNum > 1 {
+			meta.Path += "?page=" + strconv.Itoa(pageNum)
+		}
+
+		h.renderTemplate(w, r, "user-profile-page.gohtml", meta, data)
+	})
+}
+
+// recentProfileComments lists the latest approved comments of a user on posts that are not in trash.
+func (h *Handler) recentProfileComments(r *http.Request, user *auth.User) ([]profileComment, error) {
+	comments, err := h.BlogSvc.ListComments(r.Context(), blog.ListCommentsParams{
+		UserID:         user.ID,
+		Status:         blog.CommentStatusApproved,
+		PostNotTrashed: true,
+		SortBy:         "created_at",
+		SortDesc:       true,
+		Limit:          ProfileRecentCommentsLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(map[string]*blog.Post)
+	result := make([]profileComment, 0, len(comments))
+
+	for _, comment := range comments {
+		post, ok := posts[comment.PostID]
+		if !ok {
+			post, err = h.BlogSvc.GetPostByID(r.Context(), comment.PostID)
+			if err != nil && !errors.As(err, &blog.PostByIDNotFoundError{}) {
+				return nil, err
+			}
+
+			posts[comment.PostID] = post
+		}
+
+		// The post can be trashed between the two queries.
+		if post != nil {
+			result = append(result, profileComment{Comment: comment, Post: post})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is one event sent to one subscription. A pending delivery is attempted again at NextAttemptAt until it
+// succeeds or runs out of attempts and fails.
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	EventType      EventType
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	NextAttemptAt  *time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type ListDeliveriesParams struct {
+	SubscriptionID string
+	Limit          int
+	Offset         int
+}
+
+type DeliveryRepository interface {
+	Create(ctx context.Context, delivery *Delivery) (err error)
+	Update(ctx context.Context, delivery *Delivery) (err error)
+	GetByID(ctx context.Context, id string) (delivery *Delivery, err error)
+	// List returns deliveries newest first.
+	List(ctx context.Context, params ListDeliveriesParams) (deliveries []*Delivery, err error)
+	Count(ctx context.Context, params ListDeliveriesParams) (count int, err error)
+	// ListDue returns pending deliveries whose next attempt is at or before now, oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) (deliveries []*Delivery, err error)
+}
+
+type DeliveryByIDNotFoundError struct {
+	ID string
+}
+
+func (err DeliveryByIDNotFoundError) Error() string {
+	return fmt.Sprintf("webhook delivery with ID %q not found", err.ID)
+}
@@ -0,0 +1,496 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+)
+
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Minute
+	retryBatchSize     = 100
+)
+
+// Service sends events to webhook subscriptions and retries failed deliveries with exponential backoff.
+type Service struct {
+	SubscriptionRepo SubscriptionRepository
+	DeliveryRepo     DeliveryRepository
+	Client           *http.Client
+	// BaseURL is used to build links to posts in payloads.
+	BaseURL string
+	// MaxAttempts is the number of attempts before a delivery fails for good.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles on every retry.
+	Backoff time.Duration
+}
+
+// Sign returns the value of the signature header of a payload: the hex encoded HMAC-SHA256 of the body keyed with
+// the secret of the subscription.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribe delivers the events of bus that subscriptions can ask for.
+func (svc *Service) Subscribe(bus *eventbus.Bus) {
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.PostPublished) error {
+		return svc.Dispatch(ctx, EventPostPublished, svc.postData(&event.Post))
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.PostUpdated) error {
+		return svc.Dispatch(ctx, EventPostUpdated, svc.postData(&event.Post))
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.PostAuthorChanged) error {
+		return svc.Dispatch(ctx, EventPostUpdated, svc.postData(&event.Post))
+	})
+
+	// Comments held for moderation are not public yet. They are delivered when they are approved.
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.CommentCreated) error {
+		if event.Comment.Status != blog.CommentStatusApproved {
+			return nil
+		}
+
+		return svc.Dispatch(ctx, EventCommentCreated, commentData(&event.Comment))
+	})
+
+	eventbus.OnAsync(bus, func(ctx context.Context, event blog.CommentApproved) error {
+		return svc.Dispatch(ctx, EventCommentCreated, commentData(&event.Comment))
+	})
+}
+
+type payload struct {
+	Event     EventType `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+type postPayload struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Excerpt   string    `json:"excerpt"`
+	URL       string    `json:"url"`
+	AuthorID  string    `json:"authorId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type commentPayload struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"postId"`
+	ParentID  string    `json:"parentId,omitempty"`
+	UserID    string    `json:"userId"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (svc *Service) postData(post *blog.Post) postPayload {
+	return postPayload{
+		ID:        post.ID,
+		Title:     post.Title,
+		Slug:      post.Slug,
+		Excerpt:   post.Excerpt,
+		URL:       svc.BaseURL + "/posts/" + post.Slug,
+		AuthorID:  post.AuthorID,
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+}
+
+func commentData(comment *blog.Comment) commentPayload {
+	return commentPayload{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		UserID:    comment.UserID,
+		Content:   comment.Content,
+		Status:    string(comment.Status),
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+// Dispatch records a delivery of the event for every active subscription that wants it and makes the first attempt
+// right away.
+func (svc *Service) Dispatch(ctx context.Context, eventType EventType, data any) error {
+	subs, err := svc.SubscriptionRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	now := time.Now()
+
+	body, err := json.Marshal(payload{Event: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	for _, sub := range subs {
+		if !sub.Wants(eventType) {
+			continue
+		}
+
+		delivery, err := svc.createDelivery(ctx, sub.ID, eventType, body)
+		if err != nil {
+			return err
+		}
+
+		svc.attempt(ctx, sub, delivery)
+	}
+
+	return nil
+}
+
+func (svc *Service) createDelivery(
+	ctx context.Context,
+	subscriptionID string,
+	eventType EventType,
+	body []byte,
+) (*Delivery, error) {
+	now := time.Now()
+	// Until the first attempt is recorded, the retrier leaves the delivery alone.
+	nextAttemptAt := now.Add(svc.backoff(1))
+
+	delivery := &Delivery{
+		ID:             uuid.NewString(),
+		SubscriptionID: subscriptionID,
+		EventType:      eventType,
+		Payload:        body,
+		Status:         DeliveryStatusPending,
+		NextAttemptAt:  &nextAttemptAt,
+		CreatedAt:      now,
+	}
+
+	err := svc.DeliveryRepo.Create(ctx, delivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// attempt sends a delivery once and records the outcome.
+func (svc *Service) attempt(ctx context.Context, sub *Subscription, delivery *Delivery) {
+	delivery.Attempts++
+
+	statusCode, err := svc.send(ctx, sub, delivery)
+	delivery.LastStatusCode = statusCode
+	now := time.Now()
+
+	switch {
+	case err == nil:
+		delivery.Status = DeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= svc.maxAttempts():
+		delivery.Status = DeliveryStatusFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		nextAttemptAt := now.Add(svc.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+
+	err = svc.DeliveryRepo.Update(ctx, delivery)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update webhook delivery", "error", err, "deliveryId", delivery.ID)
+	}
+}
+
+func (svc *Service) send(ctx context.Context, sub *Subscription, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, delivery.Payload))
+
+	client := svc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (svc *Service) maxAttempts() int {
+	if svc.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+
+	return svc.MaxAttempts
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (svc *Service) backoff(attempts int) time.Duration {
+	base := svc.Backoff
+	if base <= 0 {
+		base = DefaultBackoff
+	}
+
+	return base << (attempts - 1)
+}
+
+// RetryDue attempts the pending deliveries that are due again.
+func (svc *Service) RetryDue(ctx context.Context) error {
+	deliveries, err := svc.DeliveryRepo.ListDue(ctx, time.Now(), retryBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		sub, err := svc.SubscriptionRepo.GetByID(ctx, delivery.SubscriptionID)
+		if err != nil {
+			return fmt.Errorf("failed to get webhook subscription: %w", err)
+		}
+
+		if !sub.Active {
+			delivery.Status = DeliveryStatusFailed
+			delivery.LastError = "subscription is inactive"
+			delivery.NextAttemptAt = nil
+
+			err = svc.DeliveryRepo.Update(ctx, delivery)
+			if err != nil {
+				return fmt.Errorf("failed to update webhook delivery: %w", err)
+			}
+
+			continue
+		}
+
+		svc.attempt(ctx, sub, delivery)
+	}
+
+	return nil
+}
+
+// RunRetrier calls RetryDue on every tick until ctx is done.
+func (svc *Service) RunRetrier(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := svc.RetryDue(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to retry webhook deliveries", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Redeliver sends the payload of a delivery again as a new delivery and returns it.
+func (svc *Service) Redeliver(ctx context.Context, id string) (*Delivery, error) {
+	original, err := svc.DeliveryRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByID(ctx, original.SubscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	delivery, err := svc.createDelivery(ctx, sub.ID, original.EventType, original.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.attempt(ctx, sub, delivery)
+
+	return delivery, nil
+}
+
+type SubscriptionRequest struct {
+	URL        string
+	Secret     string
+	EventTypes []EventType
+	Active     bool
+}
+
+func (req *SubscriptionRequest) validate() error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return InvalidSubscriptionError{Field: "URL", Reason: "must be an http or https address"}
+	}
+
+	if len(req.EventTypes) == 0 {
+		return InvalidSubscriptionError{Field: "EventTypes", Reason: "must include at least one event"}
+	}
+
+	for _, eventType := range req.EventTypes {
+		if !eventType.IsValid() {
+			return InvalidSubscriptionError{Field: "EventTypes", Reason: "contains an unknown event"}
+		}
+	}
+
+	return nil
+}
+
+// generateSecret returns a random secret for subscriptions created without one.
+func generateSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func (svc *Service) CreateSubscription(ctx context.Context, req *SubscriptionRequest) (*Subscription, error) {
+	err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Secret == "" {
+		req.Secret = generateSecret()
+	}
+
+	now := time.Now()
+
+	sub := &Subscription{
+		ID:         uuid.NewString(),
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		Active:     req.Active,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err = svc.SubscriptionRepo.Create(ctx, sub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+// UpdateSubscription changes a subscription. An empty secret keeps the current one.
+func (svc *Service) UpdateSubscription(ctx context.Context, id string, req *SubscriptionRequest) error {
+	err := req.validate()
+	if err != nil {
+		return err
+	}
+
+	sub, err := svc.SubscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	sub.URL = req.URL
+	sub.EventTypes = req.EventTypes
+	sub.Active = req.Active
+	sub.UpdatedAt = time.Now()
+
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+
+	err = svc.SubscriptionRepo.Update(ctx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) DeleteSubscription(ctx context.Context, id string) error {
+	err := svc.SubscriptionRepo.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	sub, err := svc.SubscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (svc *Service) ListSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	subs, err := svc.SubscriptionRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (svc *Service) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	delivery, err := svc.DeliveryRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+func (svc *Service) ListDeliveries(ctx context.Context, params ListDeliveriesParams) ([]*Delivery, error) {
+	deliveries, err := svc.DeliveryRepo.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (svc *Service) CountDeliveries(ctx context.Context, params ListDeliveriesParams) (int, error) {
+	count, err := svc.DeliveryRepo.Count(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	return count, nil
+}
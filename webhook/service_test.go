@@ -0,0 +1,384 @@
+package webhook_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/blog"
+	"github.com/nasermirzaei89/fullstackgo/db/sqlite3"
+	"github.com/nasermirzaei89/fullstackgo/eventbus"
+	"github.com/nasermirzaei89/fullstackgo/webhook"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local webhook endpoint that answers with the queued status codes and 200 after them.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+func (rec *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.requests = append(rec.requests, receivedRequest{header: r.Header.Clone(), body: body})
+
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status = rec.statuses[0]
+		rec.statuses = rec.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+}
+
+func (rec *receiver) received() []receivedRequest {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]receivedRequest(nil), rec.requests...)
+}
+
+func newService(t *testing.T) *webhook.Service {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = sqlite3.RunMigrations(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return &webhook.Service{
+		SubscriptionRepo: &sqlite3.WebhookSubscriptionRepo{DB: db},
+		DeliveryRepo:     &sqlite3.WebhookDeliveryRepo{DB: db},
+		BaseURL:          "https://blog.example.com",
+		MaxAttempts:      3,
+		Backoff:          time.Millisecond,
+	}
+}
+
+func subscribe(t *testing.T, svc *webhook.Service, url string, eventTypes ...webhook.EventType) *webhook.Subscription {
+	t.Helper()
+
+	sub, err := svc.CreateSubscription(t.Context(), &webhook.SubscriptionRequest{
+		URL:        url,
+		Secret:     "s3cret",
+		EventTypes: eventTypes,
+		Active:     true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	return sub
+}
+
+func deliveries(t *testing.T, svc *webhook.Service, sub *webhook.Subscription) []*webhook.Delivery {
+	t.Helper()
+
+	list, err := svc.ListDeliveries(t.Context(), webhook.ListDeliveriesParams{SubscriptionID: sub.ID})
+	if err != nil {
+		t.Fatalf("failed to list deliveries: %v", err)
+	}
+
+	return list
+}
+
+func TestPublishedEventIsSignedAndDelivered(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	sub := subscribe(t, svc, server.URL, webhook.EventPostPublished)
+	subscribe(t, svc, server.URL+"/comments", webhook.EventCommentCreated)
+
+	bus := &eventbus.Bus{}
+	svc.Subscribe(bus)
+
+	bus.Publish(context.Background(), blog.PostPublished{Post: blog.Post{ID: "p1", Title: "Hello", Slug: "hello"}})
+	bus.Close()
+
+	requests := rec.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+
+	req := requests[0]
+
+	if got := req.header.Get(webhook.SignatureHeader); got != webhook.Sign("s3cret", req.body) {
+		t.Errorf("signature %q does not match the body", got)
+	}
+
+	if got := req.header.Get(webhook.EventHeader); got != string(webhook.EventPostPublished) {
+		t.Errorf("expected event header %q, got %q", webhook.EventPostPublished, got)
+	}
+
+	var payload struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID  string `json:"id"`
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+
+	err := json.Unmarshal(req.body, &payload)
+	if err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+
+	if payload.Event != "post.published" || payload.Data.ID != "p1" ||
+		payload.Data.URL != "https://blog.example.com/posts/hello" {
+		t.Errorf("unexpected payload %s", req.body)
+	}
+
+	list := deliveries(t, svc, sub)
+	if len(list) != 1 || list[0].Status != webhook.DeliveryStatusSucceeded || list[0].LastStatusCode != http.StatusOK {
+		t.Fatalf("expected one succeeded delivery, got %+v", list)
+	}
+
+	if list[0].ID != req.header.Get(webhook.DeliveryHeader) {
+		t.Errorf("delivery header %q does not match delivery %q", req.header.Get(webhook.DeliveryHeader), list[0].ID)
+	}
+}
+
+func TestFailedDeliveryIsRetriedWithBackoff(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	sub := subscribe(t, svc, server.URL, webhook.EventPostUpdated)
+
+	err := svc.Dispatch(t.Context(), webhook.EventPostUpdated, map[string]string{"id": "p1"})
+	if err != nil {
+		t.Fatalf("failed to dispatch: %v", err)
+	}
+
+	delivery := deliveries(t, svc, sub)[0]
+	if delivery.Status != webhook.DeliveryStatusPending || delivery.Attempts != 1 ||
+		delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected a pending delivery after one failed attempt, got %+v", delivery)
+	}
+
+	firstRetryAt := *delivery.NextAttemptAt
+
+	time.Sleep(5 * time.Millisecond)
+
+	err = svc.RetryDue(t.Context())
+	if err != nil {
+		t.Fatalf("failed to retry: %v", err)
+	}
+
+	delivery = deliveries(t, svc, sub)[0]
+	if delivery.Status != webhook.DeliveryStatusPending || delivery.Attempts != 2 {
+		t.Fatalf("expected a pending delivery after two failed attempts, got %+v", delivery)
+	}
+
+	if !delivery.NextAttemptAt.After(firstRetryAt) {
+		t.Errorf("expected the next attempt to be later than %v, got %v", firstRetryAt, delivery.NextAttemptAt)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	err = svc.RetryDue(t.Context())
+	if err != nil {
+		t.Fatalf("failed to retry: %v", err)
+	}
+
+	delivery = deliveries(t, svc, sub)[0]
+	if delivery.Status != webhook.DeliveryStatusSucceeded || delivery.Attempts != 3 || delivery.DeliveredAt == nil {
+		t.Fatalf("expected the third attempt to succeed, got %+v", delivery)
+	}
+
+	requests := rec.received()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+
+	if string(requests[0].body) != string(requests[2].body) {
+		t.Errorf("expected retries to send the same payload")
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{statuses: []int{
+		http.StatusInternalServerError,
+		http.StatusInternalServerError,
+		http.StatusInternalServerError,
+		http.StatusInternalServerError,
+	}}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	sub := subscribe(t, svc, server.URL, webhook.EventCommentCreated)
+
+	err := svc.Dispatch(t.Context(), webhook.EventCommentCreated, map[string]string{"id": "c1"})
+	if err != nil {
+		t.Fatalf("failed to dispatch: %v", err)
+	}
+
+	for range 4 {
+		time.Sleep(5 * time.Millisecond)
+
+		err = svc.RetryDue(t.Context())
+		if err != nil {
+			t.Fatalf("failed to retry: %v", err)
+		}
+	}
+
+	delivery := deliveries(t, svc, sub)[0]
+	if delivery.Status != webhook.DeliveryStatusFailed || delivery.Attempts != 3 || delivery.NextAttemptAt != nil {
+		t.Fatalf("expected a failed delivery after 3 attempts, got %+v", delivery)
+	}
+
+	if got := len(rec.received()); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestRedeliverSendsPayloadAgain(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	svc.MaxAttempts = 1
+	sub := subscribe(t, svc, server.URL, webhook.EventPostPublished)
+
+	err := svc.Dispatch(t.Context(), webhook.EventPostPublished, map[string]string{"id": "p1"})
+	if err != nil {
+		t.Fatalf("failed to dispatch: %v", err)
+	}
+
+	failed := deliveries(t, svc, sub)[0]
+	if failed.Status != webhook.DeliveryStatusFailed {
+		t.Fatalf("expected a failed delivery, got %+v", failed)
+	}
+
+	rec.statuses = nil
+
+	redelivered, err := svc.Redeliver(t.Context(), failed.ID)
+	if err != nil {
+		t.Fatalf("failed to redeliver: %v", err)
+	}
+
+	if redelivered.ID == failed.ID || redelivered.Status != webhook.DeliveryStatusSucceeded {
+		t.Fatalf("expected a new succeeded delivery, got %+v", redelivered)
+	}
+
+	requests := rec.received()
+	if len(requests) != 2 || string(requests[0].body) != string(requests[1].body) {
+		t.Fatalf("expected the same payload to be sent twice, got %d requests", len(requests))
+	}
+}
+
+func TestInactiveSubscriptionIsSkipped(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	sub := subscribe(t, svc, server.URL, webhook.EventPostPublished)
+
+	err := svc.UpdateSubscription(t.Context(), sub.ID, &webhook.SubscriptionRequest{
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		Active:     false,
+	})
+	if err != nil {
+		t.Fatalf("failed to update subscription: %v", err)
+	}
+
+	err = svc.Dispatch(t.Context(), webhook.EventPostPublished, map[string]string{"id": "p1"})
+	if err != nil {
+		t.Fatalf("failed to dispatch: %v", err)
+	}
+
+	if got := len(rec.received()); got != 0 {
+		t.Errorf("expected no requests, got %d", got)
+	}
+
+	if got := len(deliveries(t, svc, sub)); got != 0 {
+		t.Errorf("expected no deliveries, got %d", got)
+	}
+}
+
+func TestPendingCommentIsDeliveredWhenApproved(t *testing.T) {
+	svc := newService(t)
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+
+	defer server.Close()
+
+	subscribe(t, svc, server.URL, webhook.EventCommentCreated)
+
+	bus := &eventbus.Bus{Workers: 1}
+	svc.Subscribe(bus)
+
+	pending := blog.Comment{ID: "c1", PostID: "p1", Content: "spam?", Status: blog.CommentStatusPending}
+	approved := blog.Comment{ID: "c2", PostID: "p1", Content: "hello", Status: blog.CommentStatusApproved}
+
+	bus.Publish(context.Background(), blog.CommentCreated{Comment: pending})
+	bus.Publish(context.Background(), blog.CommentCreated{Comment: approved})
+
+	pending.Status = blog.CommentStatusApproved
+	bus.Publish(context.Background(), blog.CommentApproved{Comment: pending})
+	bus.Close()
+
+	ids := map[string]bool{}
+
+	for _, req := range rec.received() {
+		var payload struct {
+			Event string `json:"event"`
+			Data  struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"data"`
+		}
+
+		err := json.Unmarshal(req.body, &payload)
+		if err != nil {
+			t.Fatalf("failed to unmarshal payload: %v", err)
+		}
+
+		if payload.Event != "comment.created" || payload.Data.Status != "approved" {
+			t.Errorf("unexpected payload %s", req.body)
+		}
+
+		ids[payload.Data.ID] = true
+	}
+
+	if len(rec.received()) != 2 || !ids["c1"] || !ids["c2"] {
+		t.Errorf("expected c1 and c2 to be delivered once each, got %v in %d requests", ids, len(rec.received()))
+	}
+}
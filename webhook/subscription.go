@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventPostPublished  EventType = "post.published"
+	EventPostUpdated    EventType = "post.updated"
+	EventCommentCreated EventType = "comment.created"
+)
+
+// EventTypes lists the events a subscription can ask for.
+var EventTypes = []EventType{
+	EventPostPublished,
+	EventPostUpdated,
+	EventCommentCreated,
+}
+
+func (t EventType) IsValid() bool {
+	return slices.Contains(EventTypes, t)
+}
+
+// Subscription is an endpoint that receives the events it asks for, signed with its secret.
+type Subscription struct {
+	ID         string
+	URL        string
+	Secret     string
+	EventTypes []EventType
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (sub *Subscription) HasEventType(eventType EventType) bool {
+	return slices.Contains(sub.EventTypes, eventType)
+}
+
+// Wants reports whether the subscription should receive events of the given type.
+func (sub *Subscription) Wants(eventType EventType) bool {
+	return sub.Active && sub.HasEventType(eventType)
+}
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, sub *Subscription) (err error)
+	Update(ctx context.Context, sub *Subscription) (err error)
+	// Delete removes a subscription together with its deliveries.
+	Delete(ctx context.Context, id string) (err error)
+	GetByID(ctx context.Context, id string) (sub *Subscription, err error)
+	List(ctx context.Context) (subs []*Subscription, err error)
+}
+
+type SubscriptionByIDNotFoundError struct {
+	ID string
+}
+
+func (err SubscriptionByIDNotFoundError) Error() string {
+	return fmt.Sprintf("webhook subscription with ID %q not found", err.ID)
+}
+
+type InvalidSubscriptionError struct {
+	Field  string
+	Reason string
+}
+
+func (err InvalidSubscriptionError) Error() string {
+	return fmt.Sprintf("invalid webhook subscription %s: %s", err.Field, err.Reason)
+}